@@ -1,12 +1,16 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"github.com/rodolphocastro/golanghello/books"
+	"github.com/rodolphocastro/golanghello/books/reporting"
+	"github.com/rodolphocastro/golanghello/storage"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 	"go.uber.org/zap"
 	"strconv"
@@ -16,32 +20,28 @@ import (
 // mongoLogger provides a single Logger instance for all Mongo Tests
 var mongoLogger = InitializeLogger().With(zap.String("testSubject", "mongo"))
 
-// mongoClient provides a single mongodb client for all Mongo Tests
-var mongoClient *mongo.Client
-
 const mongoDbCredentials = "root:notsafe"
 const databaseName = "integration-tests"
 const collectionName = "awesomeThings"
 const pathToMongoK8s = "./environments/development/mongo.yaml"
 
-// Creates a mongodb mqttClient for the integration environment.
+// Creates a mongodb client for the integration environment, failing the test if mongodb is unreachable.
+// Every test gets its own ClientManager, closed once the test is over, as minikube's IP is only known at runtime.
 func createMongoClient(t *testing.T) *mongo.Client {
-	if mongoClient != nil {
-		return mongoClient
-	}
-
-	mongoLogger.Debug("a client isn't available, creating a new one")
-	newClient, err := mongo.Connect(
-		context.TODO(),
-		options.Client().
-			ApplyURI(fmt.Sprintf("mongodb://%v@%v:27017", mongoDbCredentials, getMinikubeIp())),
-	)
+	manager := storage.NewClientManager(storage.ClientOptions{
+		URI:    fmt.Sprintf("mongodb://%v@%v:27017", mongoDbCredentials, getMinikubeIp()),
+		Logger: mongoLogger,
+	})
+	t.Cleanup(func() {
+		if err := manager.Close(context.TODO()); err != nil {
+			t.Errorf("Unable to disconnect from MongoDB: %v", err)
+		}
+	})
+	client, err := manager.Client(context.TODO())
 	if err != nil {
-		t.Errorf("Unable to connect to MongoDB: %v", err)
+		t.Fatalf("Unable to connect to MongoDB: %v", err)
 	}
-
-	mongoClient = newClient
-	return mongoClient
+	return client
 }
 
 // Attempt to connect to a mongodb instance
@@ -68,18 +68,10 @@ func givenAClientWhenACollectionIsFetchedThenNoErrorsShouldHappen(t *testing.T)
 	}
 }
 
-// A book!
-type Book struct {
-	ID     primitive.ObjectID `bson:"_id,omitempty"`
-	Title  string             `bson:"title,omitempty"`
-	Author string             `bson:"author,omitempty"`
-	Tags   []string           `bson:"tags,omitempty"`
-}
-
 // Creating and deleting a document within a MongoDB Collection
 // Arrange
 func givenACollectionWhenADocumentIsInsertedAndQueriedThenDataShouldBeRecovereable(t *testing.T) {
-	anEntity := Book{
+	anEntity := books.Book{
 		Title:  "At the Mountains of Madness",
 		Author: "H.P. Lovecraft",
 		Tags:   []string{"Horror", "Lovecraftian"},
@@ -108,6 +100,297 @@ func givenACollectionWhenADocumentIsInsertedAndQueriedThenDataShouldBeRecovereab
 	}
 }
 
+// Listing a collection page by page should visit every document exactly once
+func givenACollectionWithManyBooksWhenListedInPagesThenEveryBookShouldBeVisitedOnce(t *testing.T) {
+	// Arrange
+	collection := createMongoClient(t).Database(databaseName).Collection(collectionName)
+	store := books.NewMongoStore(collection)
+	inserted := map[primitive.ObjectID]bool{}
+	for i := 0; i < 7; i++ {
+		book, err := store.Insert(context.TODO(), books.Book{Title: fmt.Sprintf("Necronomicon vol. %v", i%2)})
+		if err != nil {
+			t.Fatalf("Expected no errors but found: %v", err)
+		}
+		inserted[book.ID] = true
+	}
+	defer func() {
+		_, err := collection.DeleteMany(context.TODO(), bson.M{"title": bson.M{"$regex": "^Necronomicon"}})
+		if err != nil {
+			t.Errorf("Expected no errors but found: %v", err)
+		}
+	}()
+
+	// Act
+	visited := map[primitive.ObjectID]int{}
+	request := storage.PageRequest{Size: 3, SortBy: "title", IncludeTotal: true}
+	for {
+		page, err := store.List(context.TODO(), request)
+		if err != nil {
+			t.Fatalf("Expected no errors but found: %v", err)
+		}
+		if *page.Total < int64(len(inserted)) {
+			t.Errorf("Expected at least %v books but the total was %v", len(inserted), *page.Total)
+		}
+		for _, book := range page.Items {
+			visited[book.ID]++
+		}
+		if page.NextToken == "" {
+			break
+		}
+		request.Token = page.NextToken
+	}
+
+	// Assert
+	for id := range inserted {
+		if visited[id] != 1 {
+			t.Errorf("Expected %v to be visited once but it was visited %v times", id.Hex(), visited[id])
+		}
+	}
+}
+
+// Listing by a field some books don't have should still visit every book exactly once, either way
+func givenBooksWithoutTitlesWhenListedByTitleThenEveryBookShouldBeVisitedOnce(t *testing.T) {
+	// Arrange
+	collection := createMongoClient(t).Database(databaseName).Collection(collectionName)
+	store := books.NewMongoStore(collection)
+	inserted := map[primitive.ObjectID]bool{}
+	for i := 0; i < 7; i++ {
+		book := books.Book{Author: "Anonymous pager"}
+		if i%3 == 0 {
+			book.Title = fmt.Sprintf("Pnakotic Manuscripts vol. %v", i)
+		}
+		book, err := store.Insert(context.TODO(), book)
+		if err != nil {
+			t.Fatalf("Expected no errors but found: %v", err)
+		}
+		inserted[book.ID] = true
+	}
+	defer func() {
+		_, err := collection.DeleteMany(context.TODO(), bson.M{"author": "Anonymous pager"})
+		if err != nil {
+			t.Errorf("Expected no errors but found: %v", err)
+		}
+	}()
+
+	for _, descending := range []bool{false, true} {
+		// Act
+		visited := map[primitive.ObjectID]int{}
+		request := storage.PageRequest{Size: 2, SortBy: "title", Descending: descending}
+		query := books.Query{Conditions: []books.Condition{{Field: "author", Operator: books.OpEq, Value: "Anonymous pager"}}}
+		for {
+			page, err := store.Search(context.TODO(), query, request)
+			if err != nil {
+				t.Fatalf("Expected no errors but found: %v", err)
+			}
+			for _, book := range page.Items {
+				visited[book.ID]++
+			}
+			if page.NextToken == "" {
+				break
+			}
+			request.Token = page.NextToken
+		}
+
+		// Assert
+		for id := range inserted {
+			if visited[id] != 1 {
+				t.Errorf("Expected %v to be visited once descending=%v but it was visited %v times", id.Hex(), descending, visited[id])
+			}
+		}
+	}
+}
+
+// Two writers holding the same version of a book should not overwrite each other
+func givenABookWhenTwoWritersUpdateItThenTheSecondOneShouldConflict(t *testing.T) {
+	// Arrange
+	collection := createMongoClient(t).Database(databaseName).Collection(collectionName)
+	store := books.NewMongoStore(collection)
+	book, err := store.Insert(context.TODO(), books.Book{Title: "The Shadow over Innsmouth", Author: "H.P. Lovecraft"})
+	if err != nil {
+		t.Fatalf("Expected no errors but found: %v", err)
+	}
+	firstWriter, secondWriter := book, book
+	firstWriter.Tags = []string{"Horror"}
+	secondWriter.Tags = []string{"Mystery"}
+
+	// Act
+	updated, firstErr := store.Update(books.WithActor(context.TODO(), "first"), firstWriter)
+	_, secondErr := store.Update(books.WithActor(context.TODO(), "second"), secondWriter)
+	deleteErr := store.Delete(context.TODO(), updated.ID, updated.Version)
+	deleted, deletedErr := store.GetDeleted(context.TODO(), book.ID)
+	history, historyErr := store.History(context.TODO(), book.ID)
+
+	// Assert
+	if firstErr != nil {
+		t.Errorf("Expected no errors but found: %v", firstErr)
+	}
+
+	var conflict *books.ConflictError
+	if !errors.As(secondErr, &conflict) {
+		t.Errorf("Expected a conflict but found: %v", secondErr)
+	}
+
+	if deleteErr != nil || deletedErr != nil || deleted.DeletedAt == nil {
+		t.Errorf("Expected the book to be soft-deleted but found: %v, %v", deleteErr, deletedErr)
+	}
+
+	if historyErr != nil || len(history) != 3 {
+		t.Errorf("Expected 3 audit entries but found %v: %v", len(history), historyErr)
+	}
+
+	_, err = collection.DeleteOne(context.TODO(), bson.M{"_id": book.ID})
+	if err != nil {
+		t.Errorf("Expected no errors but found: %v", err)
+	}
+}
+
+// Moving a book into an archive should be atomic, or fail clearly on standalone servers
+func givenABookWhenItIsArchivedThenItShouldMoveAtomically(t *testing.T) {
+	// Arrange
+	database := createMongoClient(t).Database(databaseName)
+	archive := database.Collection(collectionName + "Archive")
+	store := books.NewMongoStore(database.Collection(collectionName), books.Options{Logger: mongoLogger})
+	book, err := store.Insert(context.TODO(), books.Book{Title: "The Colour Out of Space", Author: "H.P. Lovecraft"})
+	if err != nil {
+		t.Fatalf("Expected no errors but found: %v", err)
+	}
+	defer func() {
+		_, _ = database.Collection(collectionName).DeleteOne(context.TODO(), bson.M{"_id": book.ID})
+		_, _ = archive.DeleteOne(context.TODO(), bson.M{"_id": book.ID})
+	}()
+
+	// Act
+	err = store.Archive(context.TODO(), book.ID, archive)
+
+	// Assert
+	if errors.Is(err, storage.ErrTransactionsUnsupported) {
+		t.Skipf("The integration environment doesn't support transactions: %v", err)
+	}
+
+	if err != nil {
+		t.Fatalf("Expected no errors but found: %v", err)
+	}
+
+	archived := archive.FindOne(context.TODO(), bson.M{"_id": book.ID})
+	if archived.Err() != nil {
+		t.Errorf("Expected the book to be in the archive but found: %v", archived.Err())
+	}
+
+	_, err = store.GetDeleted(context.TODO(), book.ID)
+	if _, getErr := store.Get(context.TODO(), book.ID); !errors.Is(getErr, books.ErrNotFound) || !errors.Is(err, books.ErrNotFound) {
+		t.Errorf("Expected the book to be gone from its collection but found: %v, %v", getErr, err)
+	}
+}
+
+// Aggregation reports should count the books stored in the collection
+func givenSomeBooksWhenReportedPerAuthorThenTheAuthorShouldBeCounted(t *testing.T) {
+	// Arrange
+	collection := createMongoClient(t).Database(databaseName).Collection(collectionName)
+	store := books.NewMongoStore(collection)
+	const author = "Clark Ashton Smith"
+	for _, title := range []string{"The Return of the Sorcerer", "The Seven Geases"} {
+		book, err := store.Insert(context.TODO(), books.Book{Title: title, Author: author, Tags: []string{"Weird"}})
+		if err != nil {
+			t.Fatalf("Expected no errors but found: %v", err)
+		}
+		defer collection.DeleteOne(context.TODO(), bson.M{"_id": book.ID})
+	}
+
+	// Act
+	got, err := reporting.NewMongoReporter(collection).BooksPerAuthor(context.TODO())
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no errors but found: %v", err)
+	}
+
+	found := false
+	for _, row := range got {
+		if row.Author == author {
+			found = row.Books == 2
+		}
+	}
+	if !found {
+		t.Errorf("Expected %v to have 2 books but the report was %v", author, got)
+	}
+}
+
+// Exports should be resumable and restorable into another collection
+func givenSomeBooksWhenExportedInTwoStepsThenTheyShouldBeRestored(t *testing.T) {
+	// Arrange
+	database := createMongoClient(t).Database(databaseName)
+	collection := database.Collection(collectionName)
+	restored := database.Collection(collectionName + "Restored")
+	store := books.NewMongoStore(collection)
+	const author = "Robert E. Howard"
+	for _, title := range []string{"The Black Stone", "The Fire of Asshurbanipal", "The Thing on the Roof"} {
+		book, err := store.Insert(context.TODO(), books.Book{Title: title, Author: author})
+		if err != nil {
+			t.Fatalf("Expected no errors but found: %v", err)
+		}
+		defer collection.DeleteOne(context.TODO(), bson.M{"_id": book.ID})
+	}
+	defer restored.Drop(context.TODO())
+	filter := bson.M{"author": author}
+
+	// Act
+	var snapshot bytes.Buffer
+	first, err := storage.Export(context.TODO(), collection, &snapshot, storage.ExportOptions{Format: storage.FormatBSON, Filter: filter, BatchSize: 1})
+	if err != nil {
+		t.Fatalf("Expected no errors but found: %v", err)
+	}
+	resumed, err := storage.Export(context.TODO(), collection, &snapshot, storage.ExportOptions{Format: storage.FormatBSON, Filter: filter, After: first.LastID})
+	if err != nil {
+		t.Fatalf("Expected no errors but found: %v", err)
+	}
+	reader, _ := storage.NewDocumentReader(&snapshot, storage.FormatBSON)
+	imported, err := storage.Import(context.TODO(), restored, reader)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no errors but found: %v", err)
+	}
+
+	if first.Exported != 3 || resumed.Exported != 0 || imported != 3 {
+		t.Errorf("Expected 3 books exported once and restored but found %v, %v and %v", first.Exported, resumed.Exported, imported)
+	}
+}
+
+// Queries should filter and sort books within mongodb just like they do in memory
+func givenSomeBooksWhenSearchedWithAQueryThenOnlyMatchesShouldBeFound(t *testing.T) {
+	// Arrange
+	collection := createMongoClient(t).Database(databaseName).Collection(collectionName)
+	store := books.NewMongoStore(collection)
+	const author = "Algernon Blackwood"
+	for _, book := range []books.Book{
+		{Title: "The Willows", Author: author, Tags: []string{"Weird", "Nature"}},
+		{Title: "The Wendigo", Author: author, Tags: []string{"Weird", "Nature"}},
+		{Title: "Ancient Sorceries", Author: author, Tags: []string{"Weird"}},
+	} {
+		inserted, err := store.Insert(context.TODO(), book)
+		if err != nil {
+			t.Fatalf("Expected no errors but found: %v", err)
+		}
+		defer collection.DeleteOne(context.TODO(), bson.M{"_id": inserted.ID})
+	}
+	query, err := books.ParseQuery("author=" + author + "&tags=Weird,Nature&title[prefix]=The W&sort=-title")
+	if err != nil {
+		t.Fatalf("Expected no errors but found: %v", err)
+	}
+
+	// Act
+	page, err := store.Search(context.TODO(), query, storage.PageRequest{})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no errors but found: %v", err)
+	}
+
+	if len(page.Items) != 2 || page.Items[0].Title != "The Willows" || page.Items[1].Title != "The Wendigo" {
+		t.Errorf("Expected The Willows and The Wendigo but found %v", page.Items)
+	}
+}
+
 func TestMongoDbScenarios(t *testing.T) {
 	// Arrange
 	SkipTestIfMinikubeIsUnavailable(t)
@@ -115,6 +398,13 @@ func TestMongoDbScenarios(t *testing.T) {
 		givenAnEnvironmentWhenAClientIsCreatedThenAPingShouldBePossible,
 		givenAClientWhenACollectionIsFetchedThenNoErrorsShouldHappen,
 		givenACollectionWhenADocumentIsInsertedAndQueriedThenDataShouldBeRecovereable,
+		givenACollectionWithManyBooksWhenListedInPagesThenEveryBookShouldBeVisitedOnce,
+		givenBooksWithoutTitlesWhenListedByTitleThenEveryBookShouldBeVisitedOnce,
+		givenABookWhenTwoWritersUpdateItThenTheSecondOneShouldConflict,
+		givenABookWhenItIsArchivedThenItShouldMoveAtomically,
+		givenSomeBooksWhenReportedPerAuthorThenTheAuthorShouldBeCounted,
+		givenSomeBooksWhenExportedInTwoStepsThenTheyShouldBeRestored,
+		givenSomeBooksWhenSearchedWithAQueryThenOnlyMatchesShouldBeFound,
 	}
 
 	scenarioLogger := mongoLogger.
@@ -125,11 +415,6 @@ func TestMongoDbScenarios(t *testing.T) {
 	scenarioLogger.Info("initializing mongo environment")
 	SpinUpK8s(t, pathToMongoK8s)
 	defer func() {
-		scenarioLogger.Info("disconnecting the client")
-		err := mongoClient.Disconnect(context.TODO())
-		if err != nil {
-			scenarioLogger.Error("unexpected error disconnecting", zap.Error(err))
-		}
 		scenarioLogger.Info("deleting the mongo environment")
 		CleanUpK8s(t, pathToMongoK8s)
 		scenarioLogger.Info("environment deleted")
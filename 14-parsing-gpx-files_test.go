@@ -1,6 +1,7 @@
 package main
 
 import (
+	"github.com/rodolphocastro/golanghello/activity"
 	"github.com/tkrajina/gpxgo/gpx"
 	"os"
 	"testing"
@@ -76,3 +77,23 @@ func TestGetDataForATimeStamp(t *testing.T) {
 		}
 	}
 }
+
+func TestSplitPerKilometer(t *testing.T) {
+	// Arrange
+	gpxData := readGuineaPigFile(t)
+
+	// Act
+	splits, err := activity.Splits(gpxData, activity.Kilometer)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no errors, but got: %v", err)
+	}
+
+	for _, split := range splits {
+		t.Logf("km %2d: %v (%v/km), %+.1fm, %.0fbpm", split.Number, split.Elapsed, split.Pace, split.ElevationDelta, split.AverageHeartRate)
+	}
+	if len(splits) < 21 {
+		t.Errorf("Expected at least 21 splits but found %v", len(splits))
+	}
+}
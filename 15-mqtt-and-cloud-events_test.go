@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	cloudEvents "github.com/cloudevents/sdk-go/v2"
 	"github.com/cloudevents/sdk-go/v2/event"
 	"github.com/eclipse/paho.mqtt.golang"
+	"github.com/rodolphocastro/golanghello/books"
+	"github.com/rodolphocastro/golanghello/books/events"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
+	"io"
 	"math/rand"
 	"strconv"
 	"testing"
@@ -22,6 +28,7 @@ const (
 	pathToMQTT           = "./environments/development/mqtt.yml"
 	simpleTopicName      = "my-awesome-topic"
 	cloudEventsTopicName = "cloudy-topic"
+	bookEventsTopicName  = "library/books"
 	aMessage             = "Hello, take me to your leader"
 )
 
@@ -69,6 +76,7 @@ func TestMqttScenarios(t *testing.T) {
 		givenAClientWhenAMessageIsPublishedAndAClientIsSubscribedThenAMessageIsReceived,
 		givenACloudEventWhenItsSerializedAndDeserializedThenTheDataShouldBeIntact,
 		givenACloudEventWhenItsPublishedAndTheTopicIsSubscribedThenDataShouldBeRecoveredIntact,
+		givenABookChangeWhenItsBridgedThenSubscribersShouldReceiveABookEvent,
 	}
 
 	mqttLogger := InitializeLogger().
@@ -260,3 +268,60 @@ func givenACloudEventWhenItsPublishedAndTheTopicIsSubscribedThenDataShouldBeReco
 		t.Errorf("Expected %v but found %v", expected, got)
 	}
 }
+
+func givenABookChangeWhenItsBridgedThenSubscribersShouldReceiveABookEvent(t *testing.T) {
+	// Arrange
+	var got books.Book
+	gotType := ""
+	expected := books.Book{ID: primitive.NewObjectID(), Title: "The Call of Cthulhu", Author: "H.P. Lovecraft", Version: 1}
+	change := events.Change{Token: bson.Raw{5, 0, 0, 0, 0}, Operation: events.OperationInsert, Book: &expected}
+	client := createMqqtClient(t)
+	onMessageReceived := func(client mqtt.Client, message mqtt.Message) {
+		received := cloudEvents.NewEvent()
+		if err := json.Unmarshal(message.Payload(), &received); err != nil {
+			t.Errorf("Expected no errors but found %v", err)
+		}
+		gotType = received.Type()
+		if err := received.DataAs(&got); err != nil {
+			t.Errorf("Expected no errors retriving CloudEvent.Data, but found: %v", err)
+		}
+	}
+	open := func(ctx context.Context, resumeAfter bson.Raw) (events.ChangeSource, error) {
+		return &singleChangeSource{change: &change}, nil
+	}
+	bridge := events.NewBridge(open, events.NewMQTTPublisher(client, 1), &events.MemoryTokenStore{}, events.Options{Topic: bookEventsTopicName})
+
+	// Act
+	client.Subscribe(bookEventsTopicName, 1, onMessageReceived)
+	err := bridge.Run(context.TODO())
+	time.Sleep(time.Second / 2) // Waiting for a second to give MQTT some time
+
+	// Assert
+	if err != nil {
+		t.Errorf("Expected no errors but found %v", err)
+	}
+
+	if gotType != events.BookCreated || got.ID != expected.ID {
+		t.Errorf("Expected a %v event for %v but found a %v event for %v", events.BookCreated, expected, gotType, got)
+	}
+}
+
+// singleChangeSource is a ChangeSource with a single change, so the bridge can run without mongodb.
+type singleChangeSource struct {
+	change *events.Change
+}
+
+// Next hands out the change once.
+func (s *singleChangeSource) Next(context.Context) (events.Change, error) {
+	if s.change == nil {
+		return events.Change{}, io.EOF
+	}
+	change := *s.change
+	s.change = nil
+	return change, nil
+}
+
+// Close does nothing.
+func (s *singleChangeSource) Close(context.Context) error {
+	return nil
+}
@@ -0,0 +1,136 @@
+// Package activity analyzes the activities recorded in GPX files, such as the
+// runs exported by Garmin Connect.
+//
+// Distances are in meters, computed with the haversine formula, and speeds are
+// in meters per second. Points are never shared between track segments, the gap
+// between two segments counts as time but not as distance.
+package activity
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// ErrNoPoints is returned when an activity has no track points to analyze.
+var ErrNoPoints = errors.New("the activity has no track points")
+
+// segments lists every track segment of an activity that has points, in order.
+func segments(g *gpx.GPX) [][]gpx.GPXPoint {
+	var found [][]gpx.GPXPoint
+	for _, track := range g.Tracks {
+		for _, segment := range track.Segments {
+			if len(segment.Points) != 0 {
+				found = append(found, segment.Points)
+			}
+		}
+	}
+	return found
+}
+
+// distance is the haversine distance between two points.
+func distance(a, b *gpx.GPXPoint) float64 {
+	return gpx.HaversineDistance(a.Latitude, a.Longitude, b.Latitude, b.Longitude)
+}
+
+// elevationOf gets the elevation of a point, false if it has none.
+func elevationOf(point *gpx.GPXPoint) (float64, bool) {
+	if point.Elevation.Null() {
+		return 0, false
+	}
+	return point.Elevation.Value(), true
+}
+
+// profile is an activity flattened into its points along with their cumulative distance.
+type profile struct {
+	points []gpx.GPXPoint
+	// distances are how far each point is from the start.
+	distances []float64
+	// segmentStarts tells which points start a segment.
+	segmentStarts []bool
+}
+
+// newProfile flattens the segments of an activity.
+func newProfile(g *gpx.GPX) (profile, error) {
+	var p profile
+	for _, segment := range segments(g) {
+		for idx := range segment {
+			travelled := 0.0
+			if len(p.points) != 0 {
+				travelled = p.distances[len(p.distances)-1]
+			}
+			if idx != 0 {
+				travelled += distance(&segment[idx-1], &segment[idx])
+			}
+			p.points = append(p.points, segment[idx])
+			p.distances = append(p.distances, travelled)
+			p.segmentStarts = append(p.segmentStarts, idx == 0)
+		}
+	}
+	if len(p.points) == 0 {
+		return p, ErrNoPoints
+	}
+	return p, nil
+}
+
+// elapsed is the time between the start and a point.
+func (p profile) elapsed(idx int) time.Duration {
+	return p.points[idx].Timestamp.Sub(p.points[0].Timestamp)
+}
+
+// speedAt is the speed of the step from the point before idx to idx, false
+// when idx starts a segment or the step takes no time.
+func (p profile) speedAt(idx int) (float64, bool) {
+	if p.segmentStarts[idx] {
+		return 0, false
+	}
+	elapsed := p.points[idx].Timestamp.Sub(p.points[idx-1].Timestamp)
+	if elapsed <= 0 {
+		return 0, false
+	}
+	return (p.distances[idx] - p.distances[idx-1]) / elapsed.Seconds(), true
+}
+
+// totalDistance is how far the activity went.
+func (p profile) totalDistance() float64 {
+	return p.distances[len(p.distances)-1]
+}
+
+// position is somewhere along a profile, between two of its points.
+type position struct {
+	// index is the first point at or after the position.
+	index        int
+	time         time.Time
+	elevation    float64
+	hasElevation bool
+}
+
+// positionAt interpolates where the activity was after covering some meters,
+// the last point when the activity is shorter than that.
+func (p profile) positionAt(meters float64) position {
+	idx := sort.SearchFloat64s(p.distances, meters)
+	if idx == 0 || idx == len(p.points) {
+		if idx == len(p.points) {
+			idx--
+		}
+		elevation, ok := elevationOf(&p.points[idx])
+		return position{index: idx, time: p.points[idx].Timestamp, elevation: elevation, hasElevation: ok}
+	}
+	return p.interpolate(idx, meters)
+}
+
+// interpolate interpolates where the activity was after covering some meters,
+// idx being the first point at or after that distance and not the first point.
+func (p profile) interpolate(idx int, meters float64) position {
+	before, after := &p.points[idx-1], &p.points[idx]
+	fraction := (meters - p.distances[idx-1]) / (p.distances[idx] - p.distances[idx-1])
+	at := position{index: idx, time: before.Timestamp.Add(time.Duration(fraction * float64(after.Timestamp.Sub(before.Timestamp))))}
+	elevationBefore, okBefore := elevationOf(before)
+	elevationAfter, okAfter := elevationOf(after)
+	if elevation := blend(elevationBefore, okBefore, elevationAfter, okAfter, fraction); elevation != nil {
+		at.elevation, at.hasElevation = *elevation, true
+	}
+	return at
+}
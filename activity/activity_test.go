@@ -0,0 +1,85 @@
+package activity
+
+import (
+	"encoding/xml"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+const pathToGuineaPig = "../14-test-subject.gpx"
+
+// metersPerDegree is how many meters a degree of latitude has with the haversine formula.
+const metersPerDegree = 6371 * 1000 * math.Pi / 180
+
+// startedAt is when synthetic tracks start.
+var startedAt = time.Date(2022, time.June, 12, 9, 4, 10, 0, time.UTC)
+
+// sample is a point of a synthetic track, which goes north from the equator.
+type sample struct {
+	// seconds since the start of the track.
+	seconds float64
+	// meters north of the start of the track.
+	meters    float64
+	elevation float64
+	// heartRate is left out of the point's extensions when zero.
+	heartRate int
+}
+
+// readGuineaPigFile reads the 14-test-subject.gpx file.
+func readGuineaPigFile(t *testing.T) *gpx.GPX {
+	gpxData, err := gpx.ParseFile(pathToGuineaPig)
+	require.Nil(t, err)
+	return gpxData
+}
+
+// givenATrack creates an activity with a segment per list of samples.
+func givenATrack(segments ...[]sample) *gpx.GPX {
+	track := gpx.GPXTrack{Name: "Synthetic Running", Type: "running"}
+	for _, samples := range segments {
+		var segment gpx.GPXTrackSegment
+		for _, s := range samples {
+			point := gpx.GPXPoint{
+				Point:     gpx.Point{Latitude: s.meters / metersPerDegree, Longitude: 0},
+				Timestamp: startedAt.Add(time.Duration(s.seconds * float64(time.Second))),
+			}
+			point.Elevation.SetValue(s.elevation)
+			if s.heartRate != 0 {
+				point.Extensions.Nodes = []gpx.ExtensionNode{{
+					XMLName: xml.Name{Space: TrackPointExtensionV1, Local: trackPointExtension},
+					Nodes:   []gpx.ExtensionNode{{XMLName: xml.Name{Space: TrackPointExtensionV1, Local: "hr"}, Data: strconv.Itoa(s.heartRate)}},
+				}}
+			}
+			segment.AppendPoint(&point)
+		}
+		track.AppendSegment(&segment)
+	}
+	g := &gpx.GPX{Version: "1.1", Creator: "golanghello"}
+	g.AppendTrack(&track)
+	return g
+}
+
+// steady creates samples of a steady effort, a point every interval seconds at speed meters per second.
+func steady(points int, interval, speed float64) []sample {
+	samples := make([]sample, points)
+	for idx := range samples {
+		samples[idx] = sample{seconds: float64(idx) * interval, meters: float64(idx) * interval * speed}
+	}
+	return samples
+}
+
+// then continues samples with more samples, shifting them to start where the first ones ended.
+func then(first []sample, next []sample) []sample {
+	last := first[len(first)-1]
+	joined := append([]sample(nil), first...)
+	for _, s := range next[1:] {
+		s.seconds += last.seconds
+		s.meters += last.meters
+		joined = append(joined, s)
+	}
+	return joined
+}
@@ -0,0 +1,153 @@
+package activity
+
+import (
+	"math"
+	"time"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// DefaultSameRouteOverlap is the overlap, as a percentage, both activities need for them to be the same route.
+const DefaultSameRouteOverlap = 90.0
+
+// Ghost is how an activity compares against a previous one after covering some distance.
+type Ghost struct {
+	// Distance is how far into both activities the comparison is, in meters.
+	Distance float64 `json:"distance"`
+	// Elapsed is the time the activity took to get there, PreviousElapsed the time the previous one took.
+	Elapsed         Duration `json:"elapsed"`
+	PreviousElapsed Duration `json:"previousElapsed"`
+	// Gap is Elapsed minus PreviousElapsed, negative when the activity is ahead of the previous one.
+	Gap Duration `json:"gap"`
+}
+
+// Comparison tells how alike two activities are.
+type Comparison struct {
+	// Frechet is the discrete Fréchet distance between both tracks, in meters:
+	// the shortest leash that lets someone walk one track's points while their
+	// dog walks the other's, neither going back. Unlike Hausdorff it notices
+	// when a route is run the other way around or in another order.
+	Frechet float64 `json:"frechet"`
+	// Hausdorff is the farthest any point of either track is from the other
+	// track, in meters.
+	Hausdorff float64 `json:"hausdorff"`
+	// Corridor is how far from each other, in meters, the tracks may be and still overlap.
+	Corridor float64 `json:"corridor"`
+	// Overlap is the percentage of the activity's distance that stayed within
+	// Corridor of the previous activity's track, PreviousOverlap the other way around.
+	Overlap         float64 `json:"overlap"`
+	PreviousOverlap float64 `json:"previousOverlap"`
+	// SameRoute tells both overlaps are at least DefaultSameRouteOverlap.
+	SameRoute bool `json:"sameRoute"`
+	// Ghost races the activity against the previous one every GhostStep
+	// meters, and at the end of the shortest one.
+	Ghost []Ghost `json:"ghost"`
+}
+
+// Compare compares an activity against a previous run of the same route, with
+// the Corridor and GhostStep of the Options. Both tracks are projected onto a
+// plane tangent to the first point of the activity, and the gaps between
+// segments are never part of a track.
+func Compare(g, previous *gpx.GPX, opts ...Options) (Comparison, error) {
+	options := withDefaults(opts)
+	current, err := newProfile(g)
+	if err != nil {
+		return Comparison{}, err
+	}
+	before, err := newProfile(previous)
+	if err != nil {
+		return Comparison{}, err
+	}
+	p := newProjection(&current.points[0])
+	xy, previousXY := p.project(current.points), p.project(before.points)
+
+	comparison := Comparison{Frechet: frechet(xy, previousXY), Corridor: options.Corridor}
+	nearest := current.nearest(xy, before, previousXY)
+	previousNearest := before.nearest(previousXY, current, xy)
+	for _, d := range append(nearest, previousNearest...) {
+		comparison.Hausdorff = math.Max(comparison.Hausdorff, d)
+	}
+	comparison.Overlap = current.overlap(nearest, options.Corridor)
+	comparison.PreviousOverlap = before.overlap(previousNearest, options.Corridor)
+	comparison.SameRoute = comparison.Overlap >= DefaultSameRouteOverlap && comparison.PreviousOverlap >= DefaultSameRouteOverlap
+
+	shortest := math.Min(current.totalDistance(), before.totalDistance())
+	for step := 1; ; step++ {
+		meters := math.Min(float64(step)*options.GhostStep, shortest)
+		elapsed := current.sinceStart(current.positionAt(meters).time)
+		previousElapsed := before.sinceStart(before.positionAt(meters).time)
+		comparison.Ghost = append(comparison.Ghost, Ghost{
+			Distance:        meters,
+			Elapsed:         Duration(elapsed),
+			PreviousElapsed: Duration(previousElapsed),
+			Gap:             Duration(elapsed - previousElapsed),
+		})
+		if meters >= shortest-distanceRoundOff {
+			break
+		}
+	}
+	return comparison, nil
+}
+
+// frechet is the discrete Fréchet distance between two lines, keeping a single
+// row of the usual table around so it takes memory for one of them only.
+func frechet(a, b []planar) float64 {
+	row := make([]float64, len(b))
+	for i := range a {
+		diagonal := 0.0
+		for j := range b {
+			d := math.Hypot(a[i].x-b[j].x, a[i].y-b[j].y)
+			above := row[j]
+			switch {
+			case i == 0 && j == 0:
+				row[j] = d
+			case i == 0:
+				row[j] = math.Max(d, row[j-1])
+			case j == 0:
+				row[j] = math.Max(d, above)
+			default:
+				row[j] = math.Max(d, math.Min(above, math.Min(diagonal, row[j-1])))
+			}
+			diagonal = above
+		}
+	}
+	return row[len(b)-1]
+}
+
+// nearest is how far each point of a profile is from the track of another,
+// both projected onto the same plane.
+func (p profile) nearest(xy []planar, other profile, otherXY []planar) []float64 {
+	distances := make([]float64, len(p.points))
+	for idx := range p.points {
+		closest := math.Inf(1)
+		for j := range other.points {
+			d := math.Hypot(xy[idx].x-otherXY[j].x, xy[idx].y-otherXY[j].y)
+			if j != 0 && !other.segmentStarts[j] {
+				d = xy[idx].distanceTo(otherXY[j-1], otherXY[j])
+			}
+			closest = math.Min(closest, d)
+		}
+		distances[idx] = closest
+	}
+	return distances
+}
+
+// overlap is the percentage of the distance of a profile whose steps start
+// and end within a corridor of another track, zero when it covers no distance.
+func (p profile) overlap(nearest []float64, corridor float64) float64 {
+	if p.totalDistance() == 0 {
+		return 0
+	}
+	within := 0.0
+	for idx := 1; idx < len(p.points); idx++ {
+		if nearest[idx-1] <= corridor && nearest[idx] <= corridor {
+			within += p.distances[idx] - p.distances[idx-1]
+		}
+	}
+	return within / p.totalDistance() * 100
+}
+
+// sinceStart is how long after the first point a time is.
+func (p profile) sinceStart(at time.Time) time.Duration {
+	return at.Sub(p.points[0].Timestamp)
+}
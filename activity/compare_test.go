@@ -0,0 +1,142 @@
+package activity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// eastOf moves every point of an activity some meters east.
+func eastOf(g *gpx.GPX, meters float64) *gpx.GPX {
+	for _, track := range g.Tracks {
+		for _, segment := range track.Segments {
+			for idx := range segment.Points {
+				segment.Points[idx].Longitude += meters / metersPerDegree
+			}
+		}
+	}
+	return g
+}
+
+// TestCompareTheGuineaPigWithItself verifies an activity is the same route as itself, and as fast.
+func TestCompareTheGuineaPigWithItself(t *testing.T) {
+	// Act
+	got, err := Compare(readGuineaPigFile(t), readGuineaPigFile(t))
+
+	// Assert
+	require.Nil(t, err)
+	assert.InDelta(t, 0, got.Frechet, 1e-6)
+	assert.InDelta(t, 0, got.Hausdorff, 1e-6)
+	assert.InDelta(t, 100, got.Overlap, 1e-6)
+	assert.InDelta(t, 100, got.PreviousOverlap, 1e-6)
+	assert.True(t, got.SameRoute)
+	require.Len(t, got.Ghost, 213)
+	for _, ghost := range got.Ghost {
+		assert.Zero(t, ghost.Gap)
+	}
+	assert.InDelta(t, 21233, got.Ghost[212].Distance, 1)
+}
+
+// TestCompareAFasterRunNextToIt verifies a route run a few meters over is the same, and the ghost tells who's ahead.
+func TestCompareAFasterRunNextToIt(t *testing.T) {
+	// Arrange
+	g := givenATrack(steady(101, 5, 4))
+	previous := eastOf(givenATrack(steady(81, 5, 5)), 10)
+
+	// Act
+	got, err := Compare(g, previous)
+
+	// Assert
+	require.Nil(t, err)
+	assert.InDelta(t, 10, got.Hausdorff, 0.01)
+	assert.GreaterOrEqual(t, got.Frechet, got.Hausdorff)
+	assert.Less(t, got.Frechet, 20.0)
+	assert.InDelta(t, 100, got.Overlap, 1e-6)
+	assert.True(t, got.SameRoute)
+	require.Len(t, got.Ghost, 20)
+	assert.Equal(t, Ghost{
+		Distance:        1000,
+		Elapsed:         Duration(250 * time.Second),
+		PreviousElapsed: Duration(200 * time.Second),
+		Gap:             Duration(50 * time.Second),
+	}, roundGhost(got.Ghost[9]))
+	assert.Equal(t, Duration(100*time.Second), roundGhost(got.Ghost[19]).Gap)
+}
+
+// TestCompareWithANarrowCorridor verifies tracks further apart than the corridor don't overlap.
+func TestCompareWithANarrowCorridor(t *testing.T) {
+	// Arrange
+	g := givenATrack(steady(101, 5, 4))
+	previous := eastOf(givenATrack(steady(101, 5, 4)), 10)
+
+	// Act
+	got, err := Compare(g, previous, Options{Corridor: 5})
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, 5.0, got.Corridor)
+	assert.InDelta(t, 0, got.Overlap, 1e-6)
+	assert.InDelta(t, 0, got.PreviousOverlap, 1e-6)
+	assert.False(t, got.SameRoute)
+}
+
+// TestCompareTheOtherWayAround verifies running a route backwards overlaps it fully yet is far from it by Fréchet.
+func TestCompareTheOtherWayAround(t *testing.T) {
+	// Arrange
+	g := givenATrack(steady(101, 5, 4))
+	samples := steady(101, 5, 4)
+	for idx := range samples {
+		samples[idx].meters = 2000 - samples[idx].meters
+	}
+	previous := givenATrack(samples)
+
+	// Act
+	got, err := Compare(g, previous)
+
+	// Assert
+	require.Nil(t, err)
+	assert.InDelta(t, 0, got.Hausdorff, 0.01)
+	assert.InDelta(t, 2000, got.Frechet, 0.01, "starting at either end")
+	assert.True(t, got.SameRoute)
+}
+
+// TestCompareAnotherRoute verifies a route that only shares its start isn't the same route.
+func TestCompareAnotherRoute(t *testing.T) {
+	// Arrange
+	g := givenATrack(steady(101, 5, 4))
+	previous := givenATrack(then(steady(6, 5, 4), steady(96, 5, 0)))
+	points := previous.Tracks[0].Segments[0].Points
+	for idx := 6; idx < len(points); idx++ {
+		points[idx].Longitude = float64(idx-5) * 20 / metersPerDegree
+	}
+
+	// Act
+	got, err := Compare(g, previous)
+
+	// Assert
+	require.Nil(t, err)
+	assert.InDelta(t, 6, got.Overlap, 1, "the first 100 meters and a bit of 2 kilometers")
+	assert.False(t, got.SameRoute)
+	assert.Greater(t, got.Hausdorff, 1000.0)
+}
+
+// TestCompareWithoutPoints verifies activities without points can't be compared.
+func TestCompareWithoutPoints(t *testing.T) {
+	// Act
+	_, err := Compare(givenATrack(steady(2, 5, 4)), &gpx.GPX{})
+
+	// Assert
+	assert.ErrorIs(t, err, ErrNoPoints)
+}
+
+// roundGhost rounds the times of a ghost to the second.
+func roundGhost(ghost Ghost) Ghost {
+	ghost.Distance = float64(int(ghost.Distance*1000+0.5)) / 1000
+	ghost.Elapsed = Duration(time.Duration(ghost.Elapsed).Round(time.Second))
+	ghost.PreviousElapsed = Duration(time.Duration(ghost.PreviousElapsed).Round(time.Second))
+	ghost.Gap = ghost.Elapsed - ghost.PreviousElapsed
+	return ghost
+}
@@ -0,0 +1,76 @@
+// Package csv flattens GPX activities into CSV, a row per track point, for
+// spreadsheets and the tools that read neither GPX nor TCX.
+//
+// The columns are those of Header. Times are RFC 3339 in UTC, distances are in
+// meters and speeds in meters per second. Readings a point doesn't have, and
+// the speed of the first point of every segment, are left empty.
+package csv
+
+import (
+	encoding "encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/rodolphocastro/golanghello/activity"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// Header names the columns of the rows.
+var Header = []string{"time", "lat", "lon", "ele", "hr", "cad", "atemp", "distance", "speed"}
+
+// Rows flattens every track point of an activity into a row, in order.
+func Rows(g *gpx.GPX) ([][]string, error) {
+	records, err := activity.Records(g)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([][]string, len(records))
+	for idx, record := range records {
+		rows[idx] = []string{
+			"",
+			formatFloat(&record.Latitude, -1),
+			formatFloat(&record.Longitude, -1),
+			formatFloat(record.Elevation, -1),
+			formatInt(record.HeartRate),
+			formatInt(record.Cadence),
+			formatFloat(record.Temperature, -1),
+			formatFloat(&record.Distance, 2),
+			formatFloat(record.Speed, 3),
+		}
+		if !record.Time.IsZero() {
+			rows[idx][0] = record.Time.UTC().Format(time.RFC3339Nano)
+		}
+	}
+	return rows, nil
+}
+
+// formatFloat formats a number with some decimals, -1 for as many as it
+// takes, empty when there is no number.
+func formatFloat(value *float64, decimals int) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', decimals, 64)
+}
+
+// formatInt formats a reading, empty when there is no reading.
+func formatInt(value *int) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.Itoa(*value)
+}
+
+// Write writes the Header and a row per track point of an activity.
+func Write(w io.Writer, g *gpx.GPX) error {
+	rows, err := Rows(g)
+	if err != nil {
+		return err
+	}
+	writer := encoding.NewWriter(w)
+	if err := writer.Write(Header); err != nil {
+		return err
+	}
+	return writer.WriteAll(rows)
+}
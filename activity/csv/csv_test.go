@@ -0,0 +1,96 @@
+package csv
+
+import (
+	"bytes"
+	encoding "encoding/csv"
+	"encoding/xml"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rodolphocastro/golanghello/activity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+const pathToGuineaPig = "../../14-test-subject.gpx"
+
+// startedAt is when synthetic tracks start.
+var startedAt = time.Date(2022, time.June, 12, 9, 4, 10, 0, time.UTC)
+
+// givenAPoint creates a point some seconds into the activity, with optional readings.
+func givenAPoint(lat float64, seconds int, readings ...gpx.ExtensionNode) gpx.GPXPoint {
+	point := gpx.GPXPoint{
+		Point:     gpx.Point{Latitude: lat, Longitude: -49.25},
+		Timestamp: startedAt.Add(time.Duration(seconds) * time.Second),
+	}
+	if len(readings) != 0 {
+		point.Elevation.SetValue(912.5)
+		point.Extensions.Nodes = []gpx.ExtensionNode{{
+			XMLName: xml.Name{Space: activity.TrackPointExtensionV1, Local: "TrackPointExtension"},
+			Nodes:   readings,
+		}}
+	}
+	return point
+}
+
+// reading creates a sensor reading.
+func reading(name, value string) gpx.ExtensionNode {
+	return gpx.ExtensionNode{XMLName: xml.Name{Space: activity.TrackPointExtensionV1, Local: name}, Data: value}
+}
+
+// TestRowsFlattenEveryPoint verifies every point is a row, leaving what it lacks empty.
+func TestRowsFlattenEveryPoint(t *testing.T) {
+	// Arrange
+	track := gpx.GPXTrack{Segments: []gpx.GPXTrackSegment{{Points: []gpx.GPXPoint{
+		givenAPoint(-25.4, 0),
+		givenAPoint(-25.4001, 5, reading("hr", "140"), reading("cad", "86"), reading("atemp", "21.5")),
+	}}}}
+	g := &gpx.GPX{Tracks: []gpx.GPXTrack{track}}
+
+	// Act
+	got, err := Rows(g)
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, [][]string{
+		{"2022-06-12T09:04:10Z", "-25.4", "-49.25", "", "", "", "", "0.00", ""},
+		{"2022-06-12T09:04:15Z", "-25.4001", "-49.25", "912.5", "140", "86", "21.5", "11.12", "2.224"},
+	}, got)
+}
+
+// TestRowsNeedPoints verifies activities without track points have no rows.
+func TestRowsNeedPoints(t *testing.T) {
+	_, err := Rows(&gpx.GPX{})
+	assert.ErrorIs(t, err, activity.ErrNoPoints)
+}
+
+// TestWriteTheGuineaPigFile verifies the guinea pig is written with a header and a row per point.
+func TestWriteTheGuineaPigFile(t *testing.T) {
+	// Arrange
+	g, err := gpx.ParseFile(pathToGuineaPig)
+	require.Nil(t, err)
+	summary, err := activity.Summarize(g)
+	require.Nil(t, err)
+	var written bytes.Buffer
+
+	// Act
+	err = Write(&written, g)
+
+	// Assert
+	require.Nil(t, err)
+	rows, err := encoding.NewReader(&written).ReadAll()
+	require.Nil(t, err)
+	require.Len(t, rows, 1+1657)
+	assert.Equal(t, []string{"time", "lat", "lon", "ele", "hr", "cad", "atemp", "distance", "speed"}, rows[0])
+	last := rows[len(rows)-1]
+	assert.Equal(t, "2022-06-12T09:04:10Z", rows[1][0])
+	assert.Equal(t, "182", last[4])
+	distance, err := strconv.ParseFloat(last[7], 64)
+	require.Nil(t, err)
+	assert.InDelta(t, summary.Distance, distance, 0.01)
+	for _, row := range rows[1:] {
+		assert.NotEmpty(t, row[4], "the guinea pig has a heart rate on every point")
+	}
+}
@@ -0,0 +1,47 @@
+package activity
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration is a time.Duration that is serialized as text, such as "1h52m38s".
+type Duration time.Duration
+
+// String formats the duration like time.Duration does.
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// MarshalJSON writes the duration as text.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON reads the duration from text, or from a number of nanoseconds.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		var nanoseconds int64
+		if err = json.Unmarshal(data, &nanoseconds); err != nil {
+			return fmt.Errorf("%s isn't a duration", data)
+		}
+		*d = Duration(nanoseconds)
+		return nil
+	}
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// paceOf is how long it takes to cover a kilometer at the speed of covering meters in elapsed, zero when nothing was covered.
+func paceOf(elapsed time.Duration, meters float64) Duration {
+	if meters <= 0 {
+		return 0
+	}
+	return Duration(time.Duration(float64(elapsed) * 1000 / meters).Round(time.Millisecond))
+}
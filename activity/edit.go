@@ -0,0 +1,275 @@
+package activity
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// ErrOverlappingActivities is returned when merging activities recorded at the same time.
+var ErrOverlappingActivities = errors.New("the activities overlap in time")
+
+// Merge joins activities recorded one after the other, such as a run saved in
+// two files because the watch ran out of battery, into a single activity. The
+// activities are put in order of their first track points and every segment of
+// theirs becomes a segment of a single track, so the gaps between them count as
+// time but not as distance. The metadata and extensions of the activity and of
+// its track are the earliest activity's, its time the earliest track point's.
+// Waypoints and routes are kept, as is every namespace the activities declare.
+// Activities may not overlap in time, nor be without track points.
+func Merge(activities ...*gpx.GPX) (*gpx.GPX, error) {
+	type span struct {
+		g          *gpx.GPX
+		start, end time.Time
+	}
+	var spans []span
+	for _, g := range activities {
+		found := segments(g)
+		if len(found) == 0 {
+			return nil, ErrNoPoints
+		}
+		last := found[len(found)-1]
+		spans = append(spans, span{g: g, start: found[0][0].Timestamp, end: last[len(last)-1].Timestamp})
+	}
+	if len(spans) == 0 {
+		return nil, ErrNoPoints
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].start.Before(spans[j].start) })
+	for idx := 1; idx < len(spans); idx++ {
+		if spans[idx].start.Before(spans[idx-1].end) {
+			return nil, fmt.Errorf("%w: one starts at %v, before the one before it ends at %v",
+				ErrOverlappingActivities, spans[idx].start.Format(time.RFC3339), spans[idx-1].end.Format(time.RFC3339))
+		}
+	}
+
+	ordered := make([]*gpx.GPX, len(spans))
+	for idx, s := range spans {
+		ordered[idx] = s.g
+	}
+	merged := *ordered[0]
+	merged.Waypoints, merged.Routes, merged.Tracks = nil, nil, nil
+	merged.Attrs = mergeNamespaces(ordered)
+	startedAt := spans[0].start
+	merged.Time = &startedAt
+
+	var track *gpx.GPXTrack
+	for _, g := range ordered {
+		merged.Waypoints = append(merged.Waypoints, g.Waypoints...)
+		merged.Routes = append(merged.Routes, g.Routes...)
+		for _, source := range g.Tracks {
+			for _, segment := range source.Segments {
+				if len(segment.Points) == 0 {
+					continue
+				}
+				if track == nil {
+					track = &gpx.GPXTrack{}
+					*track = source
+					track.Segments = nil
+				}
+				track.Segments = append(track.Segments, segment)
+			}
+		}
+	}
+	merged.Tracks = []gpx.GPXTrack{*track}
+	return &merged, nil
+}
+
+// mergeNamespaces declares every namespace the activities declare, so the
+// prefixes of their extensions are declared when writing them. Other
+// attributes, such as xsi:schemaLocation, are the first activity's. A prefix
+// already taken by another namespace is numbered, extensions find their
+// namespace by its URL rather than by its prefix.
+func mergeNamespaces(activities []*gpx.GPX) gpx.GPXAttributes {
+	var attrs []xml.Attr
+	declared, taken := map[string]bool{}, map[string]bool{}
+	for idx, g := range activities {
+		for _, attr := range attributesOf(g) {
+			if attr.Name.Space != "xmlns" {
+				if idx == 0 {
+					attrs = append(attrs, attr)
+				}
+				continue
+			}
+			if declared[attr.Value] {
+				continue
+			}
+			prefix := attr.Name.Local
+			for n := 2; taken[prefix]; n++ {
+				prefix = fmt.Sprintf("%v_%v", attr.Name.Local, n)
+			}
+			declared[attr.Value], taken[prefix] = true, true
+			attr.Name.Local = prefix
+			attrs = append(attrs, attr)
+		}
+	}
+	return gpx.NewGPXAttributes(attrs)
+}
+
+// attributesOf lists the attributes of the root of an activity, namespace declarations first.
+func attributesOf(g *gpx.GPX) []xml.Attr {
+	var attrs []xml.Attr
+	for _, byLocal := range g.Attrs.NamespaceAttributes {
+		for _, attr := range byLocal {
+			attrs = append(attrs, attr.Attr)
+		}
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		if (attrs[i].Name.Space == "xmlns") != (attrs[j].Name.Space == "xmlns") {
+			return attrs[i].Name.Space == "xmlns"
+		}
+		if attrs[i].Name.Space != attrs[j].Name.Space {
+			return attrs[i].Name.Space < attrs[j].Name.Space
+		}
+		return attrs[i].Name.Local < attrs[j].Name.Local
+	})
+	return attrs
+}
+
+// SplitAt splits an activity into the stretches between times, the first one
+// ending right before the earliest time and the last one starting at the
+// latest. Stretches without track points are left out.
+func SplitAt(g *gpx.GPX, times ...time.Time) ([]*gpx.GPX, error) {
+	p, err := newProfile(g)
+	if err != nil {
+		return nil, err
+	}
+	boundaries := append([]time.Time(nil), times...)
+	sort.Slice(boundaries, func(i, j int) bool { return boundaries[i].Before(boundaries[j]) })
+
+	var parts []*gpx.GPX
+	for part := 0; part <= len(boundaries); part++ {
+		picked := pick(g, func(idx int) bool {
+			at := p.points[idx].Timestamp
+			return (part == 0 || !at.Before(boundaries[part-1])) && (part == len(boundaries) || at.Before(boundaries[part]))
+		})
+		if picked != nil {
+			parts = append(parts, picked)
+		}
+	}
+	return parts, nil
+}
+
+// SplitAtPauses splits an activity into the Moving periods Periods finds with
+// the Options, leaving the pauses between them out.
+func SplitAtPauses(g *gpx.GPX, opts ...Options) ([]*gpx.GPX, error) {
+	periods, err := Periods(g, opts...)
+	if err != nil {
+		return nil, err
+	}
+	var parts []*gpx.GPX
+	for _, period := range periods {
+		if period.State != Moving {
+			continue
+		}
+		from, to := period.StartIndex, period.EndIndex
+		parts = append(parts, pick(g, func(idx int) bool { return idx >= from && idx <= to }))
+	}
+	return parts, nil
+}
+
+// CropTime keeps the track points of an activity recorded from one time to
+// another, both included. Cropping out every track point is an error.
+func CropTime(g *gpx.GPX, from, to time.Time) (*gpx.GPX, error) {
+	if to.Before(from) {
+		return nil, fmt.Errorf("can't crop from %v to the earlier %v", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	p, err := newProfile(g)
+	if err != nil {
+		return nil, err
+	}
+	cropped := pick(g, func(idx int) bool {
+		at := p.points[idx].Timestamp
+		return !at.Before(from) && !at.After(to)
+	})
+	if cropped == nil {
+		return nil, ErrNoPoints
+	}
+	return cropped, nil
+}
+
+// CropDistance keeps the track points of an activity from one distance from
+// the start to another, in meters and both included. Cropping out every track
+// point is an error.
+func CropDistance(g *gpx.GPX, from, to float64) (*gpx.GPX, error) {
+	if to < from {
+		return nil, fmt.Errorf("can't crop from %vm to the shorter %vm", from, to)
+	}
+	p, err := newProfile(g)
+	if err != nil {
+		return nil, err
+	}
+	cropped := pick(g, func(idx int) bool { return p.distances[idx] >= from && p.distances[idx] <= to })
+	if cropped == nil {
+		return nil, ErrNoPoints
+	}
+	return cropped, nil
+}
+
+// pick copies an activity with the track points keep accepts, given their
+// index counting the points of every segment in order, nil when it accepts
+// none. Segments and tracks left without points are dropped, waypoints and
+// route points timed before the first point kept or after the last one too, as
+// are routes left without points. The time of the copy becomes the time of its
+// first point, when the activity had one.
+func pick(g *gpx.GPX, keep func(idx int) bool) *gpx.GPX {
+	picked := *g
+	picked.Waypoints, picked.Routes, picked.Tracks = nil, nil, nil
+	var first, last *gpx.GPXPoint
+	idx := 0
+	for _, track := range g.Tracks {
+		segments := track.Segments
+		track.Segments = nil
+		for _, segment := range segments {
+			points := segment.Points
+			segment.Points = nil
+			for pointIdx := range points {
+				if keep(idx) {
+					segment.Points = append(segment.Points, points[pointIdx])
+					if first == nil {
+						first = &points[pointIdx]
+					}
+					last = &points[pointIdx]
+				}
+				idx++
+			}
+			if len(segment.Points) != 0 {
+				track.Segments = append(track.Segments, segment)
+			}
+		}
+		if len(track.Segments) != 0 {
+			picked.Tracks = append(picked.Tracks, track)
+		}
+	}
+	if first == nil {
+		return nil
+	}
+	within := func(at time.Time) bool {
+		return at.IsZero() || (!at.Before(first.Timestamp) && !at.After(last.Timestamp))
+	}
+	for _, waypoint := range g.Waypoints {
+		if within(waypoint.Timestamp) {
+			picked.Waypoints = append(picked.Waypoints, waypoint)
+		}
+	}
+	for _, route := range g.Routes {
+		points := route.Points
+		route.Points = nil
+		for _, point := range points {
+			if within(point.Timestamp) {
+				route.Points = append(route.Points, point)
+			}
+		}
+		if len(route.Points) != 0 {
+			picked.Routes = append(picked.Routes, route)
+		}
+	}
+	if g.Time != nil {
+		startedAt := first.Timestamp
+		picked.Time = &startedAt
+	}
+	return &picked
+}
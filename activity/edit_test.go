@@ -0,0 +1,272 @@
+package activity
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// gpx11 is the namespace of GPX 1.1 documents.
+const gpx11 = "http://www.topografix.com/GPX/1/1"
+
+// shifted moves every track point of an activity by some time.
+func shifted(g *gpx.GPX, by time.Duration) *gpx.GPX {
+	for _, points := range segments(g) {
+		for idx := range points {
+			points[idx].Timestamp = points[idx].Timestamp.Add(by)
+		}
+	}
+	return g
+}
+
+// assertValidGPX11 verifies a document is a GPX 1.1 document whose elements all belong to a declared namespace.
+func assertValidGPX11(t *testing.T, document []byte) {
+	decoder := xml.NewDecoder(bytes.NewReader(document))
+	declared := map[string]bool{}
+	root := true
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		require.Nil(t, err)
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		for _, attr := range start.Attr {
+			if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+				declared[attr.Value] = true
+			}
+		}
+		if root {
+			require.Equal(t, xml.Name{Space: gpx11, Local: "gpx"}, start.Name)
+			assert.Contains(t, start.Attr, xml.Attr{Name: xml.Name{Local: "version"}, Value: "1.1"})
+			root = false
+		}
+		assert.True(t, declared[start.Name.Space], "<%v> is within the undeclared %q", start.Name.Local, start.Name.Space)
+	}
+}
+
+// roundTrip writes an activity, checks it's valid GPX 1.1 and parses it back.
+func roundTrip(t *testing.T, g *gpx.GPX) *gpx.GPX {
+	var written bytes.Buffer
+	require.Nil(t, WriteGPX(&written, g))
+	assertValidGPX11(t, written.Bytes())
+	parsed, err := gpx.ParseBytes(written.Bytes())
+	require.Nil(t, err)
+	return parsed
+}
+
+// TestMergeJoinsActivitiesInOrder verifies merged activities become the segments of a track, earliest first.
+func TestMergeJoinsActivitiesInOrder(t *testing.T) {
+	// Arrange
+	morning := givenATrack(steady(11, 1, 4))
+	morning.Name = "Morning"
+	evening := shifted(givenATrack(steady(11, 1, 4)), time.Hour)
+	evening.Name = "Evening"
+	evening.Waypoints = []gpx.GPXPoint{{Name: "Water"}}
+
+	// Act
+	merged, err := Merge(evening, morning)
+	require.Nil(t, err)
+	got := roundTrip(t, merged)
+
+	// Assert
+	assert.Equal(t, "Morning", got.Name)
+	assert.Equal(t, startedAt, *got.Time)
+	require.Len(t, got.Tracks, 1)
+	assert.Equal(t, "Synthetic Running", got.Tracks[0].Name)
+	require.Len(t, got.Tracks[0].Segments, 2)
+	assert.Equal(t, startedAt.Add(time.Hour), got.Tracks[0].Segments[1].Points[0].Timestamp)
+	assert.Equal(t, 22, got.GetTrackPointsNo())
+	require.Len(t, got.Waypoints, 1)
+	assert.Equal(t, "Water", got.Waypoints[0].Name)
+	assert.Equal(t, "Evening", evening.Name, "the activities are untouched")
+}
+
+// TestMergeKeepsEveryExtension verifies extensions of different namespaces sharing a prefix survive merging.
+func TestMergeKeepsEveryExtension(t *testing.T) {
+	// Arrange
+	power, err := gpx.ParseString(`<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="golanghello" xmlns="http://www.topografix.com/GPX/1/1" xmlns:ns3="https://example.com/power/v1">
+  <trk><trkseg>
+    <trkpt lat="-25.41" lon="-49.26"><time>2022-06-13T09:00:00Z</time><extensions><ns3:power>250</ns3:power></extensions></trkpt>
+    <trkpt lat="-25.42" lon="-49.26"><time>2022-06-13T09:00:10Z</time><extensions><ns3:power>260</ns3:power></extensions></trkpt>
+  </trkseg></trk>
+</gpx>`)
+	require.Nil(t, err)
+
+	// Act
+	merged, err := Merge(power, readGuineaPigFile(t))
+	require.Nil(t, err)
+	got := roundTrip(t, merged)
+
+	// Assert
+	assert.Equal(t, 1657+2, got.GetTrackPointsNo())
+	assert.Equal(t, "Curitiba Running", got.Tracks[0].Name)
+	samples := Samples(got)
+	assert.NotNil(t, samples[0].HeartRate)
+	last := segments(got)[1][1].Extensions.Nodes
+	require.Len(t, last, 1)
+	assert.Equal(t, "https://example.com/power/v1", last[0].SpaceNameURL())
+	assert.Equal(t, "260", last[0].Data)
+}
+
+// TestMergeRefusesOverlapsAndNothing verifies activities recorded at the same time, or no activities, can't be merged.
+func TestMergeRefusesOverlapsAndNothing(t *testing.T) {
+	_, err := Merge(givenATrack(steady(11, 1, 4)), shifted(givenATrack(steady(11, 1, 4)), 5*time.Second))
+	assert.ErrorIs(t, err, ErrOverlappingActivities)
+	_, err = Merge()
+	assert.ErrorIs(t, err, ErrNoPoints)
+	_, err = Merge(&gpx.GPX{})
+	assert.ErrorIs(t, err, ErrNoPoints)
+}
+
+// TestSplitAtTimes verifies activities are split right before each time, in order, without empty parts.
+func TestSplitAtTimes(t *testing.T) {
+	// Arrange
+	g := givenATrack(steady(101, 1, 4))
+	g.Time = &startedAt
+
+	// Act
+	got, err := SplitAt(g, startedAt.Add(60*time.Second), startedAt.Add(30*time.Second), startedAt.Add(time.Hour))
+
+	// Assert
+	require.Nil(t, err)
+	require.Len(t, got, 3)
+	assert.Equal(t, []int{30, 30, 41}, []int{got[0].GetTrackPointsNo(), got[1].GetTrackPointsNo(), got[2].GetTrackPointsNo()})
+	assert.Equal(t, startedAt.Add(30*time.Second), *got[1].Time)
+	assert.Equal(t, "Synthetic Running", got[2].Tracks[0].Name)
+	assert.Equal(t, 101, g.GetTrackPointsNo(), "the original is untouched")
+}
+
+// TestSplitAtPausesDropsThePauses verifies every stretch of moving becomes an activity of its own.
+func TestSplitAtPausesDropsThePauses(t *testing.T) {
+	// Act
+	got, err := SplitAtPauses(givenATrack(givenAStop()))
+
+	// Assert
+	require.Nil(t, err)
+	require.Len(t, got, 2)
+	for _, part := range got {
+		summary, err := Summarize(part)
+		require.Nil(t, err)
+		assert.InDelta(t, 1000, summary.Distance, 20)
+		assert.Less(t, time.Duration(summary.TotalTime), 5*time.Minute)
+	}
+}
+
+// TestCropTimeKeepsTheRange verifies cropping by time keeps both ends and the waypoints within.
+func TestCropTimeKeepsTheRange(t *testing.T) {
+	// Arrange
+	g := givenATrack(steady(101, 1, 4))
+	g.Waypoints = []gpx.GPXPoint{
+		{Name: "Within", Timestamp: startedAt.Add(15 * time.Second)},
+		{Name: "Before", Timestamp: startedAt.Add(5 * time.Second)},
+		{Name: "Untimed"},
+	}
+
+	// Act
+	got, err := CropTime(g, startedAt.Add(10*time.Second), startedAt.Add(20*time.Second))
+
+	// Assert
+	require.Nil(t, err)
+	points := segments(got)[0]
+	assert.Len(t, points, 11)
+	assert.Equal(t, startedAt.Add(10*time.Second), points[0].Timestamp)
+	assert.Equal(t, startedAt.Add(20*time.Second), points[10].Timestamp)
+	require.Len(t, got.Waypoints, 2)
+	assert.Equal(t, []string{"Within", "Untimed"}, []string{got.Waypoints[0].Name, got.Waypoints[1].Name})
+	assert.Nil(t, got.Time, "the activity had no time")
+}
+
+// TestSplitAtTimesFiltersRoutes verifies every part keeps its own copy of the route points timed within it.
+func TestSplitAtTimesFiltersRoutes(t *testing.T) {
+	// Arrange
+	g := givenATrack(steady(101, 1, 4))
+	g.Routes = []gpx.GPXRoute{
+		{Name: "Planned", Points: []gpx.GPXPoint{{Name: "Start"}, {Name: "Turn"}}},
+		{Name: "Timed", Points: []gpx.GPXPoint{
+			{Name: "Early", Timestamp: startedAt.Add(10 * time.Second)},
+			{Name: "Late", Timestamp: startedAt.Add(90 * time.Second)},
+		}},
+	}
+
+	// Act
+	got, err := SplitAt(g, startedAt.Add(50*time.Second))
+	require.Nil(t, err)
+	require.Len(t, got, 2)
+	got[0].Routes[0].Points[0].Name = "Edited"
+
+	// Assert
+	for idx, expected := range []string{"Early", "Late"} {
+		require.Len(t, got[idx].Routes, 2)
+		assert.Len(t, got[idx].Routes[0].Points, 2, "untimed route points are kept")
+		require.Len(t, got[idx].Routes[1].Points, 1)
+		assert.Equal(t, expected, got[idx].Routes[1].Points[0].Name)
+	}
+	assert.Equal(t, "Start", got[1].Routes[0].Points[0].Name, "the other parts are untouched")
+	assert.Equal(t, "Start", g.Routes[0].Points[0].Name, "the original is untouched")
+}
+
+// TestCropDistanceKeepsTheRange verifies cropping by distance keeps the points within the range.
+func TestCropDistanceKeepsTheRange(t *testing.T) {
+	// Act
+	got, err := CropDistance(givenATrack(steady(101, 1, 4)), 99.5, 200.5)
+
+	// Assert
+	require.Nil(t, err)
+	points := segments(got)[0]
+	require.Len(t, points, 26)
+	assert.Equal(t, startedAt.Add(25*time.Second), points[0].Timestamp)
+}
+
+// TestCroppingNeedsARangeWithPoints verifies backwards ranges and ranges without points are refused.
+func TestCroppingNeedsARangeWithPoints(t *testing.T) {
+	g := givenATrack(steady(101, 1, 4))
+	_, err := CropTime(g, startedAt.Add(time.Minute), startedAt)
+	assert.Error(t, err)
+	_, err = CropTime(g, startedAt.Add(time.Hour), startedAt.Add(2*time.Hour))
+	assert.ErrorIs(t, err, ErrNoPoints)
+	_, err = CropDistance(g, 200, 100)
+	assert.Error(t, err)
+	_, err = CropDistance(g, 1000, 2000)
+	assert.ErrorIs(t, err, ErrNoPoints)
+}
+
+// TestEditedGuineaPigsRoundTrip verifies splitting, cropping and merging the guinea pig writes GPX 1.1 with every point.
+func TestEditedGuineaPigsRoundTrip(t *testing.T) {
+	// Arrange
+	g := readGuineaPigFile(t)
+	middle := segments(g)[0][800].Timestamp
+
+	// Act
+	halves, err := SplitAt(g, middle)
+	require.Nil(t, err)
+	paused, err := SplitAtPauses(g)
+	require.Nil(t, err)
+	cropped, err := CropDistance(g, 1000, 3000)
+	require.Nil(t, err)
+	merged, err := Merge(halves[1], halves[0])
+	require.Nil(t, err)
+
+	// Assert
+	require.Len(t, halves, 2)
+	assert.Equal(t, 800, halves[0].GetTrackPointsNo())
+	assert.Equal(t, middle, *halves[1].Time)
+	edited := append([]*gpx.GPX{merged, cropped}, append(halves, paused...)...)
+	for _, part := range edited {
+		got := roundTrip(t, part)
+		assert.Equal(t, part.GetTrackPointsNo(), got.GetTrackPointsNo())
+		assert.Equal(t, "Garmin Connect", got.Creator)
+		assert.Equal(t, "connect.garmin.com", got.Link)
+	}
+	assert.Equal(t, 1657, merged.GetTrackPointsNo())
+}
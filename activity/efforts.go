@@ -0,0 +1,141 @@
+package activity
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+const (
+	// HalfMarathon is the length of a half marathon, in meters.
+	HalfMarathon = 21097.5
+	// Marathon is the length of a marathon, in meters.
+	Marathon = 42195.0
+)
+
+// DefaultEffortDistances are the distances BestEfforts looks for when given none.
+var DefaultEffortDistances = []float64{Kilometer, 5 * Kilometer, 10 * Kilometer, HalfMarathon}
+
+// Effort is the fastest stretch of an activity covering a distance.
+type Effort struct {
+	// Distance is in meters.
+	Distance  float64   `json:"distance"`
+	StartedAt time.Time `json:"startedAt"`
+	EndedAt   time.Time `json:"endedAt"`
+	// StartIndex is the point at, or right before, the start of the effort. Indexes
+	// count every track point of the activity, as if there was a single segment.
+	StartIndex int `json:"startIndex"`
+	// EndIndex is the point at, or right after, the end of the effort.
+	EndIndex int      `json:"endIndex"`
+	Elapsed  Duration `json:"elapsed"`
+	// Pace is the time per kilometer.
+	Pace Duration `json:"pace"`
+}
+
+// BestEfforts finds the fastest stretch of an activity covering each distance,
+// in meters, defaulting to DefaultEffortDistances. Distances longer than the
+// activity are left out.
+//
+// Efforts start and end exactly at their distance, interpolating between
+// points. The fastest stretch always starts or ends at a point, so each
+// distance takes two linear sweeps: one ending a stretch at every point and
+// one starting a stretch at every point.
+func BestEfforts(g *gpx.GPX, distances ...float64) ([]Effort, error) {
+	if len(distances) == 0 {
+		distances = DefaultEffortDistances
+	}
+	p, err := newProfile(g)
+	if err != nil {
+		return nil, err
+	}
+
+	var efforts []Effort
+	for _, meters := range distances {
+		if meters <= 0 {
+			return nil, fmt.Errorf("efforts must be longer than %v meters", meters)
+		}
+		if effort, ok := p.bestEffort(meters); ok {
+			efforts = append(efforts, effort)
+		}
+	}
+	return efforts, nil
+}
+
+// bestEffort finds the fastest stretch covering some meters, false if the profile is shorter than that.
+func (p profile) bestEffort(meters float64) (Effort, bool) {
+	finder := effortFinder{meters: meters}
+	for idx := range p.points {
+		finder.add(milestone{index: idx, distance: p.distances[idx], time: p.points[idx].Timestamp})
+	}
+	return finder.best()
+}
+
+// milestone is a point along with how far from the start it is.
+type milestone struct {
+	index    int
+	distance float64
+	time     time.Time
+}
+
+// interpolateTime interpolates when the distance between two milestones was covered.
+func interpolateTime(before, after milestone, distance float64) time.Time {
+	fraction := (distance - before.distance) / (after.distance - before.distance)
+	return before.time.Add(time.Duration(fraction * float64(after.time.Sub(before.time))))
+}
+
+// effortFinder finds the fastest stretch covering some meters, one point at a
+// time. Every point ends a stretch, whose start is interpolated, and starts
+// another one, whose end is interpolated once a point gets far enough. Only the
+// points within the last stretch are kept around.
+type effortFinder struct {
+	meters float64
+	// window are the points since the last one at or before the start of a
+	// stretch ending at the newest point.
+	window []milestone
+	// start is the last point of window at or before the start of a stretch
+	// ending at the newest point, pending the first one yet to start a stretch.
+	start, pending int
+	effort         Effort
+	found          bool
+}
+
+// add considers the stretches a new point ends.
+func (f *effortFinder) add(m milestone) {
+	f.window = append(f.window, m)
+	if from := m.distance - f.meters; from >= 0 {
+		for f.window[f.start+1].distance <= from {
+			f.start++
+		}
+		f.consider(interpolateTime(f.window[f.start], f.window[f.start+1], from), m.time, f.window[f.start].index, m.index)
+	}
+	for last := len(f.window) - 1; f.pending < last && f.window[f.pending].distance+f.meters <= m.distance; f.pending++ {
+		started := f.window[f.pending]
+		f.consider(started.time, interpolateTime(f.window[last-1], m, started.distance+f.meters), started.index, m.index)
+	}
+	for f.start > 0 && f.pending > 0 {
+		f.window = f.window[1:]
+		f.start--
+		f.pending--
+	}
+}
+
+// consider keeps a stretch when it's the fastest so far.
+func (f *effortFinder) consider(startedAt, endedAt time.Time, startIndex, endIndex int) {
+	elapsed := Duration(endedAt.Sub(startedAt))
+	if f.found && elapsed >= f.effort.Elapsed {
+		return
+	}
+	f.found = true
+	f.effort = Effort{Distance: f.meters, StartedAt: startedAt, EndedAt: endedAt, StartIndex: startIndex, EndIndex: endIndex, Elapsed: elapsed}
+}
+
+// best is the fastest stretch found so far, false if no stretch was long enough.
+func (f *effortFinder) best() (Effort, bool) {
+	best := f.effort
+	if f.found {
+		best.Elapsed = Duration(time.Duration(best.Elapsed).Round(time.Millisecond))
+		best.Pace = paceOf(time.Duration(best.Elapsed), f.meters)
+	}
+	return best, f.found
+}
@@ -0,0 +1,164 @@
+package activity
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// givenAFartlek creates 2km at 4 m/s, a fast kilometer at 5 m/s and another 2km at 4 m/s, a point every 20 meters.
+func givenAFartlek() []sample {
+	return then(then(steady(101, 5, 4), steady(51, 4, 5)), steady(101, 5, 4))
+}
+
+// TestBestEffortsFindTheFastestStretches verifies efforts against hand computed results.
+func TestBestEffortsFindTheFastestStretches(t *testing.T) {
+	// Arrange
+	g := givenATrack(givenAFartlek())
+
+	// Act
+	got, err := BestEfforts(g, Kilometer, 2*Kilometer, 4500, 10*Kilometer)
+
+	// Assert
+	require.Nil(t, err)
+	require.Len(t, got, 3, "the 10k is longer than the activity")
+
+	assert.Equal(t, Effort{
+		Distance:   Kilometer,
+		StartedAt:  startedAt.Add(500 * time.Second),
+		EndedAt:    startedAt.Add(700 * time.Second),
+		StartIndex: 100,
+		EndIndex:   150,
+		Elapsed:    Duration(200 * time.Second),
+		Pace:       Duration(200 * time.Second),
+	}, got[0])
+	assert.Equal(t, Duration(450*time.Second), got[1].Elapsed, "the fast kilometer and a steady one")
+	assert.Equal(t, Duration(225*time.Second), got[1].Pace)
+	assert.Equal(t, 0, got[2].StartIndex, "leaving a slow 500m out of either end")
+	assert.Equal(t, startedAt, got[2].StartedAt)
+	assert.Equal(t, Duration(1075*time.Second), got[2].Elapsed)
+}
+
+// TestBestEffortsInterpolateBetweenPoints verifies efforts that start in between two points.
+func TestBestEffortsInterpolateBetweenPoints(t *testing.T) {
+	// Arrange
+	g := givenATrack([]sample{
+		{seconds: 0, meters: 0},
+		{seconds: 100, meters: 500},
+		{seconds: 200, meters: 1500},
+		{seconds: 400, meters: 2000},
+	})
+
+	// Act
+	got, err := BestEfforts(g, 1000, 1200)
+
+	// Assert
+	require.Nil(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, Duration(100*time.Second), got[0].Elapsed)
+	assert.Equal(t, 1, got[0].StartIndex)
+	assert.Equal(t, 2, got[0].EndIndex)
+	// ending at the 1500m point it starts at 300m, 60 seconds in
+	assert.Equal(t, Duration(140*time.Second), got[1].Elapsed)
+	assert.Equal(t, startedAt.Add(60*time.Second), got[1].StartedAt)
+	assert.Equal(t, 0, got[1].StartIndex)
+	assert.Equal(t, 2, got[1].EndIndex)
+}
+
+// bruteForceEffort tries every stretch starting or ending at a point, in quadratic time.
+func bruteForceEffort(p profile, meters float64) Duration {
+	var best time.Duration = -1
+	for i := range p.points {
+		for j := i + 1; j < len(p.points); j++ {
+			if p.distances[j]-p.distances[i] < meters {
+				continue
+			}
+			ending := p.points[j].Timestamp.Sub(p.positionAt(p.distances[j] - meters).time)
+			starting := p.positionAt(p.distances[i] + meters).time.Sub(p.points[i].Timestamp)
+			for _, elapsed := range []time.Duration{ending, starting} {
+				if best < 0 || elapsed < best {
+					best = elapsed
+				}
+			}
+		}
+	}
+	return Duration(best.Round(time.Millisecond))
+}
+
+// TestBestEffortsAgreeWithBruteForce verifies the sweeps on random tracks.
+func TestBestEffortsAgreeWithBruteForce(t *testing.T) {
+	random := rand.New(rand.NewSource(42))
+	for round := 0; round < 20; round++ {
+		// Arrange
+		samples := []sample{{}}
+		for idx := 1; idx < 150; idx++ {
+			previous := samples[idx-1]
+			samples = append(samples, sample{
+				seconds: previous.seconds + 1 + random.Float64()*9,
+				meters:  previous.meters + random.Float64()*60, // stops included
+			})
+		}
+		g := givenATrack(samples)
+		p, _ := newProfile(g)
+
+		for _, meters := range []float64{100, 750, 2000} {
+			// Act
+			got, err := BestEfforts(g, meters)
+
+			// Assert
+			require.Nil(t, err)
+			require.Len(t, got, 1)
+			assert.Equal(t, bruteForceEffort(p, meters), got[0].Elapsed, "round %v, %v meters", round, meters)
+		}
+	}
+}
+
+// TestBestEffortsOfTheGuineaPig verifies the half marathon has every default effort but the half marathon itself.
+func TestBestEffortsOfTheGuineaPig(t *testing.T) {
+	// Arrange
+	g := readGuineaPigFile(t)
+	summary, _ := Summarize(g)
+
+	// Act
+	got, err := BestEfforts(g)
+
+	// Assert
+	require.Nil(t, err)
+	require.Len(t, got, 4)
+	assert.Equal(t, summary.BestPace, got[0].Pace)
+	for idx := 1; idx < len(got); idx++ {
+		assert.GreaterOrEqual(t, got[idx].Pace, got[idx-1].Pace, "longer efforts shouldn't be faster")
+		assert.Less(t, got[idx].StartIndex, got[idx].EndIndex)
+	}
+}
+
+// TestBestEffortsNeedPositiveDistances verifies nonsense distances are errors.
+func TestBestEffortsNeedPositiveDistances(t *testing.T) {
+	// Act
+	_, err := BestEfforts(givenATrack(steady(11, 5, 4)), -5)
+
+	// Assert
+	assert.Error(t, err)
+}
+
+// givenALongRun creates a run with many points, way beyond a half marathon's file.
+func givenALongRun(points int) []sample {
+	random := rand.New(rand.NewSource(7))
+	samples := make([]sample, points)
+	for idx := 1; idx < points; idx++ {
+		samples[idx] = sample{seconds: float64(idx), meters: samples[idx-1].meters + 2 + random.Float64()*2}
+	}
+	return samples
+}
+
+// BenchmarkBestEfforts measures the default efforts over a 200k points run.
+func BenchmarkBestEfforts(b *testing.B) {
+	g := givenATrack(givenALongRun(200_000))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = BestEfforts(g)
+	}
+}
@@ -0,0 +1,173 @@
+package activity
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+const (
+	// TrackPointExtensionV1 is the namespace of Garmin's TrackPointExtension, as used by Garmin Connect.
+	TrackPointExtensionV1 = "http://www.garmin.com/xmlschemas/TrackPointExtension/v1"
+	// TrackPointExtensionV2 is the namespace of the second version of Garmin's TrackPointExtension.
+	TrackPointExtensionV2 = "http://www.garmin.com/xmlschemas/TrackPointExtension/v2"
+	// trackPointExtension is the name of the element holding the sensor readings.
+	trackPointExtension = "TrackPointExtension"
+)
+
+// trackPointSpaces are the namespaces and prefixes a TrackPointExtension may come with.
+// Prefixes show up instead of namespaces when a file doesn't declare them, and
+// extensions written without a prefix end up within GPX's own namespace.
+var trackPointSpaces = map[string]bool{
+	TrackPointExtensionV1:               true,
+	TrackPointExtensionV2:               true,
+	"ns3":                               true,
+	"gpxtpx":                            true,
+	"http://www.topografix.com/GPX/1/1": true,
+	"":                                  true,
+}
+
+// Sample holds the sensor readings of a point, readings that are missing are nil.
+type Sample struct {
+	Time time.Time `json:"time"`
+	// HeartRate is in beats per minute.
+	HeartRate *int `json:"hr,omitempty"`
+	// Cadence is in revolutions, or strides, per minute.
+	Cadence *int `json:"cad,omitempty"`
+	// Temperature is the ambient temperature in degrees Celsius.
+	Temperature *float64 `json:"atemp,omitempty"`
+}
+
+// IsEmpty tells if a sample has no readings at all.
+func (s Sample) IsEmpty() bool {
+	return s.HeartRate == nil && s.Cadence == nil && s.Temperature == nil
+}
+
+// DecodeSample reads the TrackPointExtension of a point. Readings that are
+// missing, or that can't be parsed, are left nil.
+func DecodeSample(point *gpx.GPXPoint) Sample {
+	sample := Sample{Time: point.Timestamp}
+	for _, node := range point.Extensions.Nodes {
+		if node.LocalName() != trackPointExtension || !trackPointSpaces[node.SpaceNameURL()] {
+			continue
+		}
+		for _, reading := range node.Nodes {
+			value, err := strconv.ParseFloat(strings.TrimSpace(reading.Data), 64)
+			if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+				continue
+			}
+			switch reading.LocalName() {
+			case "hr":
+				sample.HeartRate = intReading(value)
+			case "cad":
+				sample.Cadence = intReading(value)
+			case "atemp":
+				sample.Temperature = &value
+			}
+		}
+	}
+	return sample
+}
+
+// intReading rounds a reading into an int.
+func intReading(value float64) *int {
+	rounded := int(math.Round(value))
+	return &rounded
+}
+
+// Samples decodes the sensor readings of every track point of an activity, in order.
+func Samples(g *gpx.GPX) []Sample {
+	var samples []Sample
+	for idx := range g.Tracks {
+		samples = append(samples, TrackSamples(&g.Tracks[idx])...)
+	}
+	return samples
+}
+
+// TrackSamples decodes the sensor readings of every point of a track, in order.
+func TrackSamples(track *gpx.GPXTrack) []Sample {
+	var samples []Sample
+	for _, segment := range track.Segments {
+		for idx := range segment.Points {
+			samples = append(samples, DecodeSample(&segment.Points[idx]))
+		}
+	}
+	return samples
+}
+
+// SensorSummary aggregates the sensor readings of an activity. Aggregates
+// without readings are zero, or nil for temperatures.
+type SensorSummary struct {
+	// AverageHeartRate is the mean of every heart rate reading.
+	AverageHeartRate float64 `json:"averageHeartRate,omitempty"`
+	MaxHeartRate     int     `json:"maxHeartRate,omitempty"`
+	// AverageCadence is the mean of the cadence readings, zeros are left out as they are stops.
+	AverageCadence float64  `json:"averageCadence,omitempty"`
+	MaxCadence     int      `json:"maxCadence,omitempty"`
+	MinTemperature *float64 `json:"minTemperature,omitempty"`
+	MaxTemperature *float64 `json:"maxTemperature,omitempty"`
+}
+
+// IsEmpty tells if there were no readings to aggregate.
+func (s SensorSummary) IsEmpty() bool {
+	return s == SensorSummary{}
+}
+
+// SummarizeSamples aggregates sensor readings.
+func SummarizeSamples(samples []Sample) SensorSummary {
+	var totals sensorTotals
+	for _, sample := range samples {
+		totals.add(sample)
+	}
+	return totals.summary()
+}
+
+// sensorTotals aggregates sensor readings one at a time.
+type sensorTotals struct {
+	// extremes are the highest and lowest readings so far, averages are left for summary.
+	extremes                 SensorSummary
+	heartRates, heartRateSum int
+	cadences, cadenceSum     int
+}
+
+// add aggregates the readings of a sample.
+func (t *sensorTotals) add(sample Sample) {
+	if sample.HeartRate != nil {
+		t.heartRates++
+		t.heartRateSum += *sample.HeartRate
+		if *sample.HeartRate > t.extremes.MaxHeartRate {
+			t.extremes.MaxHeartRate = *sample.HeartRate
+		}
+	}
+	if sample.Cadence != nil && *sample.Cadence > 0 {
+		t.cadences++
+		t.cadenceSum += *sample.Cadence
+		if *sample.Cadence > t.extremes.MaxCadence {
+			t.extremes.MaxCadence = *sample.Cadence
+		}
+	}
+	if sample.Temperature != nil {
+		temperature := *sample.Temperature
+		if t.extremes.MinTemperature == nil || temperature < *t.extremes.MinTemperature {
+			t.extremes.MinTemperature = &temperature
+		}
+		if t.extremes.MaxTemperature == nil || temperature > *t.extremes.MaxTemperature {
+			t.extremes.MaxTemperature = &temperature
+		}
+	}
+}
+
+// summary is the aggregate of every reading added so far.
+func (t *sensorTotals) summary() SensorSummary {
+	summary := t.extremes
+	if t.heartRates != 0 {
+		summary.AverageHeartRate = float64(t.heartRateSum) / float64(t.heartRates)
+	}
+	if t.cadences != 0 {
+		summary.AverageCadence = float64(t.cadenceSum) / float64(t.cadences)
+	}
+	return summary
+}
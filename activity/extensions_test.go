@@ -0,0 +1,135 @@
+package activity
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// gpxWithExtensions creates a GPX document with a point per extension, declaring the gpxtpx prefix.
+func gpxWithExtensions(extensions ...string) string {
+	points := ""
+	for idx, extension := range extensions {
+		points += fmt.Sprintf(`<trkpt lat="-25.41" lon="-49.26"><time>2022-06-12T09:04:%02d.000Z</time><extensions>%v</extensions></trkpt>`, idx, extension)
+	}
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="golanghello" xmlns="http://www.topografix.com/GPX/1/1"
+  xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v2">
+  <trk><trkseg>` + points + `</trkseg></trk>
+</gpx>`
+}
+
+// intOf creates a pointer to an int.
+func intOf(value int) *int {
+	return &value
+}
+
+// floatOf creates a pointer to a float64.
+func floatOf(value float64) *float64 {
+	return &value
+}
+
+// TestSamplesAreDecodedFromEveryPrefix verifies ns3 and gpxtpx extensions, declared or not, are read.
+func TestSamplesAreDecodedFromEveryPrefix(t *testing.T) {
+	// Arrange
+	document := gpxWithExtensions(
+		`<gpxtpx:TrackPointExtension><gpxtpx:hr>140</gpxtpx:hr><gpxtpx:cad>85</gpxtpx:cad><gpxtpx:atemp>21.5</gpxtpx:atemp></gpxtpx:TrackPointExtension>`,
+		`<ns3:TrackPointExtension><ns3:atemp>18.0</ns3:atemp><ns3:hr>84</ns3:hr><ns3:cad>0</ns3:cad></ns3:TrackPointExtension>`,
+		`<TrackPointExtension><hr>150.4</hr></TrackPointExtension>`,
+	)
+	g, err := gpx.ParseString(document)
+	require.Nil(t, err)
+
+	// Act
+	got := Samples(g)
+
+	// Assert
+	require.Len(t, got, 3)
+	assert.Equal(t, Sample{Time: got[0].Time, HeartRate: intOf(140), Cadence: intOf(85), Temperature: floatOf(21.5)}, got[0])
+	assert.Equal(t, Sample{Time: got[1].Time, HeartRate: intOf(84), Cadence: intOf(0), Temperature: floatOf(18)}, got[1])
+	assert.Equal(t, Sample{Time: got[2].Time, HeartRate: intOf(150)}, got[2])
+}
+
+// TestMissingReadingsAreTolerated verifies empty, bogus and unrelated extensions are skipped.
+func TestMissingReadingsAreTolerated(t *testing.T) {
+	// Arrange
+	document := gpxWithExtensions(
+		`<gpxtpx:TrackPointExtension><gpxtpx:hr></gpxtpx:hr><gpxtpx:cad>NaN</gpxtpx:cad><gpxtpx:atemp>warm</gpxtpx:atemp></gpxtpx:TrackPointExtension>`,
+		`<power>250</power>`,
+		``,
+	)
+	g, err := gpx.ParseString(document)
+	require.Nil(t, err)
+
+	// Act
+	got := Samples(g)
+
+	// Assert
+	require.Len(t, got, 3)
+	for _, sample := range got {
+		assert.True(t, sample.IsEmpty(), "%+v should have no readings", sample)
+	}
+	assert.True(t, SummarizeSamples(got).IsEmpty())
+}
+
+// TestSensorSummariesAggregateReadings verifies averages, maximums and the temperature range.
+func TestSensorSummariesAggregateReadings(t *testing.T) {
+	// Arrange
+	samples := []Sample{
+		{HeartRate: intOf(100), Cadence: intOf(0), Temperature: floatOf(18)},
+		{HeartRate: intOf(150), Cadence: intOf(80)},
+		{Cadence: intOf(90), Temperature: floatOf(14.5)},
+		{HeartRate: intOf(170), Temperature: floatOf(16)},
+	}
+
+	// Act
+	got := SummarizeSamples(samples)
+
+	// Assert
+	assert.InDelta(t, 140, got.AverageHeartRate, 0.001)
+	assert.Equal(t, 170, got.MaxHeartRate)
+	assert.InDelta(t, 85, got.AverageCadence, 0.001, "stops shouldn't drag the cadence down")
+	assert.Equal(t, 90, got.MaxCadence)
+	assert.Equal(t, floatOf(14.5), got.MinTemperature)
+	assert.Equal(t, floatOf(18), got.MaxTemperature)
+}
+
+// TestTheGuineaPigHasReadingsOnEveryPoint verifies the Garmin Connect export is fully decoded.
+func TestTheGuineaPigHasReadingsOnEveryPoint(t *testing.T) {
+	// Arrange
+	g := readGuineaPigFile(t)
+
+	// Act
+	got := Samples(g)
+	summary, err := Summarize(g)
+
+	// Assert
+	require.Nil(t, err)
+	require.Len(t, got, g.GetTrackPointsNo())
+	for _, sample := range got {
+		require.NotNil(t, sample.HeartRate)
+		require.NotNil(t, sample.Cadence)
+		require.NotNil(t, sample.Temperature)
+	}
+	assert.Equal(t, Sample{Time: got[0].Time, HeartRate: intOf(84), Cadence: intOf(0), Temperature: floatOf(18)}, got[0])
+	assert.Equal(t, 182, *got[len(got)-1].HeartRate)
+	require.NotNil(t, summary.Sensors)
+	assert.GreaterOrEqual(t, summary.Sensors.MaxHeartRate, 182)
+	assert.Greater(t, summary.Sensors.AverageHeartRate, 84.0)
+	assert.Greater(t, summary.Sensors.AverageCadence, 0.0)
+	assert.LessOrEqual(t, *summary.Sensors.MinTemperature, 15.0)
+	assert.GreaterOrEqual(t, *summary.Sensors.MaxTemperature, 18.0)
+}
+
+// TestActivitiesWithoutSensorsHaveNoSensorSummary verifies the summary leaves sensors out when there are none.
+func TestActivitiesWithoutSensorsHaveNoSensorSummary(t *testing.T) {
+	// Act
+	got, err := Summarize(givenATrack(steady(11, 5, 4)))
+
+	// Assert
+	require.Nil(t, err)
+	assert.Nil(t, got.Sensors)
+}
@@ -0,0 +1,240 @@
+package activity
+
+import (
+	"math"
+	"time"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+const (
+	// DefaultMaxSpeed is the fastest speed, in meters per second, a runner is believed to go at.
+	DefaultMaxSpeed = 12.0
+	// DefaultAccuracy is how far off, in meters, a GPS fix usually is.
+	DefaultAccuracy = 5.0
+	// DefaultAcceleration is how quickly, in meters per second squared, a runner is believed to change pace.
+	DefaultAcceleration = 0.5
+	// DefaultStationaryRadius is how far, in meters, a standing runner's fixes wander.
+	DefaultStationaryRadius = 5.0
+	// DefaultStationaryDuration is how long a runner must stay put for it to count as a stop.
+	DefaultStationaryDuration = 30 * time.Second
+)
+
+// Filter cleans up the points of a track segment, returning new points and
+// leaving the ones it's given untouched.
+type Filter func(points []gpx.GPXPoint) []gpx.GPXPoint
+
+// FilterReport tells how much filtering changed an activity.
+type FilterReport struct {
+	Before Summary `json:"before"`
+	After  Summary `json:"after"`
+	// Removed is how many points the filters dropped.
+	Removed int `json:"removed"`
+}
+
+// ApplyFilters runs every segment of an activity through filters, in order.
+// The activity isn't changed, a filtered copy of it is returned along with the
+// summaries of both.
+func ApplyFilters(g *gpx.GPX, filters ...Filter) (*gpx.GPX, FilterReport, error) {
+	before, err := Summarize(g)
+	if err != nil {
+		return nil, FilterReport{}, err
+	}
+
+	filtered := copyTracks(g)
+	for _, track := range filtered.Tracks {
+		for idx := range track.Segments {
+			points := track.Segments[idx].Points
+			for _, filter := range filters {
+				if len(points) != 0 {
+					points = filter(points)
+				}
+			}
+			track.Segments[idx].Points = points
+		}
+	}
+
+	after, err := Summarize(filtered)
+	if err != nil {
+		return nil, FilterReport{}, err
+	}
+	return filtered, FilterReport{Before: before, After: after, Removed: before.Points - after.Points}, nil
+}
+
+// speed is how fast going from a point to another is, infinite when they share a timestamp.
+func speed(from, to *gpx.GPXPoint) float64 {
+	seconds := to.Timestamp.Sub(from.Timestamp).Seconds()
+	if seconds <= 0 {
+		return math.Inf(1)
+	}
+	return distance(from, to) / seconds
+}
+
+// SpeedOutliers drops the points that are reached faster than maxSpeed and
+// left faster than maxSpeed, the spikes of a track. The first and last point
+// are always kept, since they have one neighbor only.
+func SpeedOutliers(maxSpeed float64) Filter {
+	return func(points []gpx.GPXPoint) []gpx.GPXPoint {
+		kept := []gpx.GPXPoint{points[0]}
+		for idx := 1; idx < len(points); idx++ {
+			last := &kept[len(kept)-1]
+			if idx != len(points)-1 && speed(last, &points[idx]) > maxSpeed && speed(&points[idx], &points[idx+1]) > maxSpeed {
+				continue
+			}
+			kept = append(kept, points[idx])
+		}
+		return kept
+	}
+}
+
+// KalmanSmoother smooths positions with a constant velocity Kalman filter
+// followed by a Rauch-Tung-Striebel pass backwards, so the smoothed track
+// neither lags nor cuts corners late. Fixes are believed to be accuracy meters
+// off and runners to change pace by up to acceleration meters per second
+// squared, the larger it is the closer to the fixes the track stays. Times,
+// elevations and extensions are kept.
+func KalmanSmoother(accuracy, acceleration float64) Filter {
+	return func(points []gpx.GPXPoint) []gpx.GPXPoint {
+		p := newProjection(&points[0])
+		measured := p.project(points)
+
+		// forward, with a state of position and velocity along each axis
+		transitions := make([]matrix, len(points))
+		predicted := make([]matrix, len(points))
+		covariances := make([]matrix, len(points))
+		xs, ys := make([]vector, len(points)), make([]vector, len(points))
+		xs[0], ys[0] = vector{measured[0].x, 0}, vector{measured[0].y, 0}
+		covariances[0] = matrix{{accuracy * accuracy, 0}, {0, initialSpeedVariance}}
+		for idx := 1; idx < len(points); idx++ {
+			seconds := math.Max(0, points[idx].Timestamp.Sub(points[idx-1].Timestamp).Seconds())
+			transition := matrix{{1, seconds}, {0, 1}}
+			noise := matrix{
+				{seconds * seconds * seconds / 3, seconds * seconds / 2},
+				{seconds * seconds / 2, seconds},
+			}.scale(acceleration * acceleration)
+			transitions[idx] = transition
+			predicted[idx] = transition.mul(covariances[idx-1]).mul(transition.transpose()).add(noise)
+
+			innovation := predicted[idx][0][0] + accuracy*accuracy
+			gain := vector{predicted[idx][0][0] / innovation, predicted[idx][1][0] / innovation}
+			xs[idx] = transition.apply(xs[idx-1]).correct(gain, measured[idx].x)
+			ys[idx] = transition.apply(ys[idx-1]).correct(gain, measured[idx].y)
+			covariances[idx] = matrix{
+				{(1 - gain[0]) * predicted[idx][0][0], (1 - gain[0]) * predicted[idx][0][1]},
+				{predicted[idx][1][0] - gain[1]*predicted[idx][0][0], predicted[idx][1][1] - gain[1]*predicted[idx][0][1]},
+			}
+		}
+
+		// backwards, correcting every estimate with the ones after it
+		smoothed := make([]gpx.GPXPoint, len(points))
+		copy(smoothed, points)
+		last := len(points) - 1
+		p.unproject(planar{x: xs[last][0], y: ys[last][0]}, &smoothed[last])
+		for idx := last - 1; idx >= 0; idx-- {
+			inverse, ok := predicted[idx+1].inverse()
+			if ok {
+				gain := covariances[idx].mul(transitions[idx+1].transpose()).mul(inverse)
+				xs[idx] = xs[idx].add(gain.apply(xs[idx+1].sub(transitions[idx+1].apply(xs[idx]))))
+				ys[idx] = ys[idx].add(gain.apply(ys[idx+1].sub(transitions[idx+1].apply(ys[idx]))))
+			}
+			p.unproject(planar{x: xs[idx][0], y: ys[idx][0]}, &smoothed[idx])
+		}
+		return smoothed
+	}
+}
+
+// initialSpeedVariance is how unsure, in squared meters per second, the smoother is of the initial speed.
+const initialSpeedVariance = 100.0
+
+// vector is a position and a velocity along an axis.
+type vector [2]float64
+
+func (v vector) add(o vector) vector { return vector{v[0] + o[0], v[1] + o[1]} }
+func (v vector) sub(o vector) vector { return vector{v[0] - o[0], v[1] - o[1]} }
+
+// correct moves a predicted state towards a measured position.
+func (v vector) correct(gain vector, measured float64) vector {
+	residual := measured - v[0]
+	return vector{v[0] + gain[0]*residual, v[1] + gain[1]*residual}
+}
+
+// matrix is a 2x2 matrix.
+type matrix [2][2]float64
+
+func (m matrix) apply(v vector) vector {
+	return vector{m[0][0]*v[0] + m[0][1]*v[1], m[1][0]*v[0] + m[1][1]*v[1]}
+}
+
+func (m matrix) mul(o matrix) matrix {
+	var product matrix
+	for i := 0; i < 2; i++ {
+		for j := 0; j < 2; j++ {
+			product[i][j] = m[i][0]*o[0][j] + m[i][1]*o[1][j]
+		}
+	}
+	return product
+}
+
+func (m matrix) add(o matrix) matrix {
+	return matrix{{m[0][0] + o[0][0], m[0][1] + o[0][1]}, {m[1][0] + o[1][0], m[1][1] + o[1][1]}}
+}
+
+func (m matrix) scale(factor float64) matrix {
+	return matrix{{m[0][0] * factor, m[0][1] * factor}, {m[1][0] * factor, m[1][1] * factor}}
+}
+
+func (m matrix) transpose() matrix {
+	return matrix{{m[0][0], m[1][0]}, {m[0][1], m[1][1]}}
+}
+
+// inverse inverts the matrix, false if it can't be inverted.
+func (m matrix) inverse() (matrix, bool) {
+	determinant := m[0][0]*m[1][1] - m[0][1]*m[1][0]
+	if determinant == 0 {
+		return matrix{}, false
+	}
+	return matrix{{m[1][1], -m[0][1]}, {-m[1][0], m[0][0]}}.scale(1 / determinant), true
+}
+
+// CollapseStationary collapses the points a runner recorded while standing,
+// staying within radius meters of where they stopped for at least duration,
+// into two points at their centroid: one when they stopped and another when
+// they left. Time standing still is kept while the jitter's distance is gone.
+func CollapseStationary(radius float64, duration time.Duration) Filter {
+	return func(points []gpx.GPXPoint) []gpx.GPXPoint {
+		var kept []gpx.GPXPoint
+		for idx := 0; idx < len(points); {
+			end := idx + 1
+			for end < len(points) && distance(&points[idx], &points[end]) <= radius {
+				end++
+			}
+			last := end - 1
+			if last-idx < 2 || points[last].Timestamp.Sub(points[idx].Timestamp) < duration {
+				kept = append(kept, points[idx])
+				idx++
+				continue
+			}
+
+			var latitude, longitude float64
+			for _, point := range points[idx:end] {
+				latitude += point.Latitude
+				longitude += point.Longitude
+			}
+			stopped, left := points[idx], points[last]
+			stopped.Latitude, stopped.Longitude = latitude/float64(end-idx), longitude/float64(end-idx)
+			left.Latitude, left.Longitude = stopped.Latitude, stopped.Longitude
+			kept = append(kept, stopped, left)
+			idx = end
+		}
+		return kept
+	}
+}
+
+// DefaultFilters drops spikes, collapses stops and then smooths what's left, with the default settings.
+func DefaultFilters() []Filter {
+	return []Filter{
+		SpeedOutliers(DefaultMaxSpeed),
+		CollapseStationary(DefaultStationaryRadius, DefaultStationaryDuration),
+		KalmanSmoother(DefaultAccuracy, DefaultAcceleration),
+	}
+}
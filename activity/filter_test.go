@@ -0,0 +1,163 @@
+package activity
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// pointsOf lists the points of the only segment of an activity.
+func pointsOf(g *gpx.GPX) []gpx.GPXPoint {
+	return g.Tracks[0].Segments[0].Points
+}
+
+// TestSpeedOutliersDropSpikes verifies a fix 500 meters off the track is dropped.
+func TestSpeedOutliersDropSpikes(t *testing.T) {
+	// Arrange
+	samples := steady(21, 5, 4)
+	samples[10].meters += 500
+
+	// Act
+	got := SpeedOutliers(DefaultMaxSpeed)(pointsOf(givenATrack(samples)))
+
+	// Assert
+	require.Len(t, got, 20)
+	assert.Equal(t, startedAt.Add(55*time.Second), got[10].Timestamp)
+}
+
+// TestSpeedOutliersKeepSteadyTracks verifies nothing is dropped from a plausible track.
+func TestSpeedOutliersKeepSteadyTracks(t *testing.T) {
+	// Arrange
+	points := pointsOf(givenATrack(then(steady(21, 5, 4), steady(21, 1, 11))))
+
+	// Act
+	got := SpeedOutliers(DefaultMaxSpeed)(points)
+
+	// Assert
+	assert.Equal(t, points, got)
+}
+
+// givenAZigzag creates a 400 meters path east, zigzagging 6 meters north and south of it.
+func givenAZigzag() *gpx.GPX {
+	var path []planar
+	for idx := 0; idx <= 40; idx++ {
+		path = append(path, planar{x: float64(idx) * 10, y: float64(idx%2*2-1) * 3})
+	}
+	return givenAPath(path...)
+}
+
+// TestKalmanSmootherRemovesJitter verifies a zigzag is smoothed into nearly a straight line.
+func TestKalmanSmootherRemovesJitter(t *testing.T) {
+	// Arrange
+	g := givenAZigzag()
+	points := pointsOf(g)
+
+	// Act
+	got := KalmanSmoother(DefaultAccuracy, DefaultAcceleration)(points)
+
+	// Assert
+	require.Len(t, got, len(points))
+	smoothed := copyTracks(g)
+	smoothed.Tracks[0].Segments[0].Points = got
+	assert.InDelta(t, 400, smoothed.Length2D(), 20, "the zigzag itself is %v meters", g.Length2D())
+	for idx := range got {
+		assert.Equal(t, points[idx].Timestamp, got[idx].Timestamp)
+		assert.Equal(t, points[idx].Elevation, got[idx].Elevation)
+	}
+	assert.NotEqual(t, points[1].Latitude, got[1].Latitude, "the original points are untouched")
+	assert.Equal(t, 3/metersPerDegree, points[1].Latitude)
+}
+
+// TestKalmanSmootherKeepsStraightLines verifies a noiseless track stays where it was.
+func TestKalmanSmootherKeepsStraightLines(t *testing.T) {
+	// Arrange
+	points := pointsOf(givenATrack(steady(21, 5, 4)))
+
+	// Act
+	got := KalmanSmoother(DefaultAccuracy, DefaultAcceleration)(points)
+
+	// Assert
+	for idx := range got {
+		assert.InDelta(t, points[idx].Latitude, got[idx].Latitude, 0.25/metersPerDegree, "within 25cm")
+		assert.InDelta(t, points[idx].Longitude, got[idx].Longitude, 0.25/metersPerDegree)
+	}
+}
+
+// givenAStop creates 1km at 4 m/s, two minutes standing with jittery fixes every second and another km.
+func givenAStop() []sample {
+	random := rand.New(rand.NewSource(3))
+	standing := make([]sample, 121)
+	for idx := 1; idx < len(standing); idx++ {
+		standing[idx] = sample{seconds: float64(idx), meters: random.Float64()*4 - 2}
+	}
+	standing[len(standing)-1].meters = 0
+	return then(then(steady(51, 5, 4), standing), steady(51, 5, 4))
+}
+
+// TestCollapseStationaryCollapsesStops verifies standing still leaves two points and no distance.
+func TestCollapseStationaryCollapsesStops(t *testing.T) {
+	// Arrange
+	points := pointsOf(givenATrack(givenAStop()))
+
+	// Act
+	got := CollapseStationary(DefaultStationaryRadius, DefaultStationaryDuration)(points)
+
+	// Assert
+	require.Len(t, got, 50+2+50)
+	assert.Equal(t, startedAt.Add(250*time.Second), got[50].Timestamp)
+	assert.Equal(t, startedAt.Add(370*time.Second), got[51].Timestamp)
+	assert.Equal(t, got[50].Point, got[51].Point)
+	assert.Equal(t, points[len(points)-1], got[len(got)-1])
+}
+
+// TestCollapseStationaryNeedsLongStops verifies short stops, like a traffic light, are kept.
+func TestCollapseStationaryNeedsLongStops(t *testing.T) {
+	// Arrange
+	points := pointsOf(givenATrack(givenAStop()))
+
+	// Act
+	got := CollapseStationary(DefaultStationaryRadius, 5*time.Minute)(points)
+
+	// Assert
+	assert.Equal(t, points, got)
+}
+
+// TestApplyFiltersReportsTheChanges verifies filters compose and the summaries before and after.
+func TestApplyFiltersReportsTheChanges(t *testing.T) {
+	// Arrange
+	samples := givenAStop()
+	samples[20].meters += 300
+	g := givenATrack(samples)
+
+	// Act
+	got, report, err := ApplyFilters(g, DefaultFilters()...)
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, len(samples), g.GetTrackPointsNo(), "the original is left untouched")
+	assert.Equal(t, report.After.Points, got.GetTrackPointsNo())
+	assert.Equal(t, 1+119, report.Removed, "a spike and the stop")
+	assert.Greater(t, report.Before.Distance, 2600.0, "a spike and jitter")
+	assert.InDelta(t, 2000, report.After.Distance, 5)
+	assert.Equal(t, report.Before.TotalTime, report.After.TotalTime)
+	assert.Less(t, report.After.MovingTime, report.Before.MovingTime)
+}
+
+// TestApplyFiltersOnTheGuineaPig verifies the default filters don't do much to a good recording.
+func TestApplyFiltersOnTheGuineaPig(t *testing.T) {
+	// Arrange
+	g := readGuineaPigFile(t)
+
+	// Act
+	_, report, err := ApplyFilters(g, DefaultFilters()...)
+
+	// Assert
+	require.Nil(t, err)
+	assert.LessOrEqual(t, report.After.Distance, report.Before.Distance)
+	assert.InDelta(t, report.Before.Distance, report.After.Distance, report.Before.Distance*0.02)
+	t.Logf("%+v", report)
+}
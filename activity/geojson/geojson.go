@@ -0,0 +1,220 @@
+// Package geojson converts GPX activities into GeoJSON, so they can be drawn on maps.
+//
+// Coordinates follow RFC 7946: longitude, latitude and, when known, elevation.
+// Per point data such as times and sensor readings go into the feature's
+// "coordinateProperties", as arrays aligned with its coordinates.
+package geojson
+
+import (
+	"time"
+
+	"github.com/rodolphocastro/golanghello/activity"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+const (
+	TypeFeatureCollection = "FeatureCollection"
+	TypeFeature           = "Feature"
+	TypePoint             = "Point"
+	TypeLineString        = "LineString"
+	TypeMultiLineString   = "MultiLineString"
+)
+
+// FeatureCollection is a GeoJSON FeatureCollection.
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// Feature is a GeoJSON Feature.
+type Feature struct {
+	Type       string                 `json:"type"`
+	Geometry   Geometry               `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// Geometry is a GeoJSON geometry. Coordinates are a Position for points, a
+// []Position for line strings and a [][]Position for multi line strings.
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// Position is a longitude, a latitude and an optional elevation.
+type Position []float64
+
+// Options tunes the conversion.
+type Options struct {
+	// Extensions embeds the heart rate, cadence and temperature of every point.
+	Extensions bool
+}
+
+// Convert converts the tracks, routes and waypoints of an activity into a
+// FeatureCollection. Tracks with a single segment become LineStrings and tracks
+// with many become MultiLineStrings, empty tracks and routes are left out.
+func Convert(g *gpx.GPX, opts ...Options) FeatureCollection {
+	var options Options
+	if len(opts) != 0 {
+		options = opts[0]
+	}
+
+	collection := FeatureCollection{Type: TypeFeatureCollection, Features: []Feature{}}
+	for idx := range g.Tracks {
+		if feature, ok := convertTrack(&g.Tracks[idx], options); ok {
+			collection.Features = append(collection.Features, feature)
+		}
+	}
+	for idx := range g.Routes {
+		route := &g.Routes[idx]
+		if len(route.Points) == 0 {
+			continue
+		}
+		properties := describe(route.Name, route.Type, route.Description)
+		lines := newLines(options)
+		lines.add(route.Points)
+		lines.into(properties, true)
+		collection.Features = append(collection.Features, Feature{
+			Type:       TypeFeature,
+			Geometry:   Geometry{Type: TypeLineString, Coordinates: lines.coordinates[0]},
+			Properties: properties,
+		})
+	}
+	for idx := range g.Waypoints {
+		collection.Features = append(collection.Features, convertWaypoint(&g.Waypoints[idx]))
+	}
+	return collection
+}
+
+// convertTrack converts a track into a LineString or a MultiLineString, false if it has no points.
+func convertTrack(track *gpx.GPXTrack, options Options) (Feature, bool) {
+	lines := newLines(options)
+	for _, segment := range track.Segments {
+		if len(segment.Points) != 0 {
+			lines.add(segment.Points)
+		}
+	}
+	if len(lines.coordinates) == 0 {
+		return Feature{}, false
+	}
+
+	properties := describe(track.Name, track.Type, track.Description)
+	if bounds := track.TimeBounds(); !bounds.StartTime.IsZero() {
+		properties["startTime"] = bounds.StartTime.UTC().Format(time.RFC3339)
+		properties["endTime"] = bounds.EndTime.UTC().Format(time.RFC3339)
+	}
+	single := len(lines.coordinates) == 1
+	lines.into(properties, single)
+
+	geometry := Geometry{Type: TypeMultiLineString, Coordinates: lines.coordinates}
+	if single {
+		geometry = Geometry{Type: TypeLineString, Coordinates: lines.coordinates[0]}
+	}
+	return Feature{Type: TypeFeature, Geometry: geometry, Properties: properties}, true
+}
+
+// convertWaypoint converts a waypoint into a Point.
+func convertWaypoint(point *gpx.GPXPoint) Feature {
+	properties := describe(point.Name, point.Type, point.Description)
+	if !point.Timestamp.IsZero() {
+		properties["time"] = point.Timestamp.UTC().Format(time.RFC3339)
+	}
+	if point.Symbol != "" {
+		properties["sym"] = point.Symbol
+	}
+	return Feature{
+		Type:       TypeFeature,
+		Geometry:   Geometry{Type: TypePoint, Coordinates: positionOf(point)},
+		Properties: properties,
+	}
+}
+
+// describe creates the properties every feature has, leaving out the empty ones.
+func describe(name, kind, description string) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for key, value := range map[string]string{"name": name, "type": kind, "desc": description} {
+		if value != "" {
+			properties[key] = value
+		}
+	}
+	return properties
+}
+
+// positionOf creates the position of a point.
+func positionOf(point *gpx.GPXPoint) Position {
+	if point.Elevation.NotNull() {
+		return Position{point.Longitude, point.Latitude, point.Elevation.Value()}
+	}
+	return Position{point.Longitude, point.Latitude}
+}
+
+// lines accumulates the coordinates of a feature, a line per segment, along
+// with their coordinate properties.
+type lines struct {
+	extensions  bool
+	coordinates [][]Position
+	times       [][]interface{}
+	heartRates  [][]interface{}
+	cadences    [][]interface{}
+	temps       [][]interface{}
+	hasTimes    bool
+}
+
+// newLines creates an empty set of lines.
+func newLines(options Options) *lines {
+	return &lines{extensions: options.Extensions}
+}
+
+// add adds a line, missing values are nulls so arrays stay aligned.
+func (l *lines) add(points []gpx.GPXPoint) {
+	coordinates := make([]Position, len(points))
+	times := make([]interface{}, len(points))
+	heartRates := make([]interface{}, len(points))
+	cadences := make([]interface{}, len(points))
+	temps := make([]interface{}, len(points))
+	for idx := range points {
+		point := &points[idx]
+		coordinates[idx] = positionOf(point)
+		if !point.Timestamp.IsZero() {
+			times[idx] = point.Timestamp.UTC().Format(time.RFC3339)
+			l.hasTimes = true
+		}
+		if l.extensions {
+			sample := activity.DecodeSample(point)
+			heartRates[idx], cadences[idx], temps[idx] = valueOf(sample.HeartRate), valueOf(sample.Cadence), valueOf(sample.Temperature)
+		}
+	}
+	l.coordinates = append(l.coordinates, coordinates)
+	l.times = append(l.times, times)
+	l.heartRates = append(l.heartRates, heartRates)
+	l.cadences = append(l.cadences, cadences)
+	l.temps = append(l.temps, temps)
+}
+
+// into writes the coordinate properties into a feature's properties, nested
+// like the coordinates of a MultiLineString unless there is a single line.
+func (l *lines) into(properties map[string]interface{}, single bool) {
+	aligned := func(values [][]interface{}) interface{} {
+		if single {
+			return values[0]
+		}
+		return values
+	}
+	coordinateProperties := map[string]interface{}{}
+	if l.hasTimes {
+		coordinateProperties["times"] = aligned(l.times)
+	}
+	if l.extensions {
+		coordinateProperties["hr"] = aligned(l.heartRates)
+		coordinateProperties["cad"] = aligned(l.cadences)
+		coordinateProperties["atemp"] = aligned(l.temps)
+	}
+	properties["coordinateProperties"] = coordinateProperties
+}
+
+// valueOf dereferences a reading, nil when it is missing.
+func valueOf[T int | float64](reading *T) interface{} {
+	if reading == nil {
+		return nil
+	}
+	return *reading
+}
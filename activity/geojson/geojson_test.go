@@ -0,0 +1,147 @@
+package geojson
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/rodolphocastro/golanghello/activity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+const pathToGuineaPig = "../../14-test-subject.gpx"
+
+// startedAt is when synthetic tracks start.
+var startedAt = time.Date(2022, time.June, 12, 9, 4, 10, 0, time.UTC)
+
+// givenAPoint creates a point some seconds into the activity, with an optional heart rate.
+func givenAPoint(lat, lon float64, seconds int, heartRate string) gpx.GPXPoint {
+	point := gpx.GPXPoint{
+		Point:     gpx.Point{Latitude: lat, Longitude: lon},
+		Timestamp: startedAt.Add(time.Duration(seconds) * time.Second),
+	}
+	point.Elevation.SetValue(900 + float64(seconds))
+	if heartRate != "" {
+		point.Extensions.Nodes = []gpx.ExtensionNode{{
+			XMLName: xml.Name{Space: activity.TrackPointExtensionV1, Local: "TrackPointExtension"},
+			Nodes:   []gpx.ExtensionNode{{XMLName: xml.Name{Space: activity.TrackPointExtensionV1, Local: "hr"}, Data: heartRate}},
+		}}
+	}
+	return point
+}
+
+// givenAnActivity creates an activity with a track per list of segments and a waypoint.
+func givenAnActivity(tracks ...[][]gpx.GPXPoint) *gpx.GPX {
+	g := &gpx.GPX{Version: "1.1", Creator: "golanghello"}
+	for _, segments := range tracks {
+		track := gpx.GPXTrack{Name: "Synthetic Running", Type: "running"}
+		for _, points := range segments {
+			track.Segments = append(track.Segments, gpx.GPXTrackSegment{Points: points})
+		}
+		g.AppendTrack(&track)
+	}
+	g.Waypoints = append(g.Waypoints, gpx.GPXPoint{
+		Point:     gpx.Point{Latitude: -25.4, Longitude: -49.2},
+		Name:      "Water",
+		Timestamp: startedAt,
+	})
+	return g
+}
+
+// TestConvertASingleSegmentTrack verifies a track with one segment becomes a LineString.
+func TestConvertASingleSegmentTrack(t *testing.T) {
+	// Arrange
+	g := givenAnActivity([][]gpx.GPXPoint{{givenAPoint(1, 2, 0, "120"), givenAPoint(3, 4, 10, "")}})
+
+	// Act
+	got := Convert(g)
+
+	// Assert
+	require.Len(t, got.Features, 2)
+	track := got.Features[0]
+	assert.Equal(t, TypeLineString, track.Geometry.Type)
+	assert.Equal(t, []Position{{2, 1, 900}, {4, 3, 910}}, track.Geometry.Coordinates, "longitude comes first")
+	assert.Equal(t, "Synthetic Running", track.Properties["name"])
+	assert.Equal(t, "running", track.Properties["type"])
+	assert.Equal(t, "2022-06-12T09:04:10Z", track.Properties["startTime"])
+	assert.Equal(t, "2022-06-12T09:04:20Z", track.Properties["endTime"])
+	assert.Equal(t, map[string]interface{}{
+		"times": []interface{}{"2022-06-12T09:04:10Z", "2022-06-12T09:04:20Z"},
+	}, track.Properties["coordinateProperties"], "extensions are left out by default")
+
+	waypoint := got.Features[1]
+	assert.Equal(t, TypePoint, waypoint.Geometry.Type)
+	assert.Equal(t, Position{-49.2, -25.4}, waypoint.Geometry.Coordinates, "no elevation, no third coordinate")
+	assert.Equal(t, "Water", waypoint.Properties["name"])
+	assert.Equal(t, "2022-06-12T09:04:10Z", waypoint.Properties["time"])
+}
+
+// TestConvertAMultiSegmentTrack verifies a paused track becomes a MultiLineString with nested properties.
+func TestConvertAMultiSegmentTrack(t *testing.T) {
+	// Arrange
+	g := givenAnActivity([][]gpx.GPXPoint{
+		{givenAPoint(1, 2, 0, "120"), givenAPoint(3, 4, 10, "")},
+		{},
+		{givenAPoint(5, 6, 60, "130")},
+	})
+
+	// Act
+	got := Convert(g, Options{Extensions: true})
+
+	// Assert
+	track := got.Features[0]
+	assert.Equal(t, TypeMultiLineString, track.Geometry.Type)
+	assert.Equal(t, [][]Position{{{2, 1, 900}, {4, 3, 910}}, {{6, 5, 960}}}, track.Geometry.Coordinates, "empty segments are left out")
+	coordinateProperties := track.Properties["coordinateProperties"].(map[string]interface{})
+	assert.Equal(t, [][]interface{}{{120, nil}, {130}}, coordinateProperties["hr"], "missing readings are nulls")
+	assert.Equal(t, [][]interface{}{{nil, nil}, {nil}}, coordinateProperties["cad"])
+	assert.Len(t, coordinateProperties["times"], 2)
+}
+
+// TestConvertLeavesEmptyTracksOut verifies tracks without points aren't features.
+func TestConvertLeavesEmptyTracksOut(t *testing.T) {
+	// Act
+	got := Convert(givenAnActivity([][]gpx.GPXPoint{{}}))
+
+	// Assert
+	require.Len(t, got.Features, 1)
+	assert.Equal(t, TypePoint, got.Features[0].Geometry.Type)
+}
+
+// TestConvertTheGuineaPigFile verifies the 14-test-subject.gpx file becomes valid GeoJSON with aligned arrays.
+func TestConvertTheGuineaPigFile(t *testing.T) {
+	// Arrange
+	g, err := gpx.ParseFile(pathToGuineaPig)
+	require.Nil(t, err)
+
+	// Act
+	content, err := json.Marshal(Convert(g, Options{Extensions: true}))
+
+	// Assert
+	require.Nil(t, err)
+	var decoded struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry struct {
+				Type        string      `json:"type"`
+				Coordinates [][]float64 `json:"coordinates"`
+			} `json:"geometry"`
+			Properties struct {
+				Name                 string                   `json:"name"`
+				CoordinateProperties map[string][]interface{} `json:"coordinateProperties"`
+			} `json:"properties"`
+		} `json:"features"`
+	}
+	require.Nil(t, json.Unmarshal(content, &decoded))
+	assert.Equal(t, TypeFeatureCollection, decoded.Type)
+	require.Len(t, decoded.Features, 1)
+	feature := decoded.Features[0]
+	assert.Equal(t, "Curitiba Running", feature.Properties.Name)
+	assert.Equal(t, g.GetTrackPointsNo(), len(feature.Geometry.Coordinates))
+	for _, key := range []string{"times", "hr", "cad", "atemp"} {
+		assert.Len(t, feature.Properties.CoordinateProperties[key], len(feature.Geometry.Coordinates), key)
+	}
+}
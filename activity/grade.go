@@ -0,0 +1,238 @@
+package activity
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// ErrNoElevation is returned when an activity has no elevations to analyze.
+var ErrNoElevation = errors.New("the activity has no elevations")
+
+// SmoothingMethod is a way of smoothing elevations.
+type SmoothingMethod string
+
+const (
+	// MovingAverage averages the elevations within Window meters, centered on each point.
+	MovingAverage SmoothingMethod = "moving-average"
+	// Thresholding ignores changes smaller than Threshold meters, following
+	// larger changes Threshold meters behind. Every climb and every descent
+	// comes out Threshold meters shorter, the jitter on top of them is gone.
+	Thresholding SmoothingMethod = "threshold"
+)
+
+const (
+	// DefaultSmoothingWindow is the stretch, in meters, elevations are averaged over.
+	DefaultSmoothingWindow = 50.0
+	// DefaultElevationThreshold is the smallest change in elevation, in meters, that isn't jitter.
+	DefaultElevationThreshold = 3.0
+	// maxGradient is the steepest gradient, either way, Minetti measured the cost of running at.
+	maxGradient = 0.45
+)
+
+// Smoothing tells how to smooth elevations.
+type Smoothing struct {
+	// Method is MovingAverage or Thresholding, defaults to MovingAverage.
+	Method SmoothingMethod
+	// Window defaults to DefaultSmoothingWindow.
+	Window float64
+	// Threshold defaults to DefaultElevationThreshold.
+	Threshold float64
+}
+
+// withDefaults fills in whatever wasn't set, failing on methods it doesn't know.
+func (s Smoothing) withDefaults() (Smoothing, error) {
+	if s.Method == "" {
+		s.Method = MovingAverage
+	}
+	if s.Method != MovingAverage && s.Method != Thresholding {
+		return s, fmt.Errorf("elevations are smoothed with %q or %q, not %q", MovingAverage, Thresholding, s.Method)
+	}
+	if s.Window <= 0 {
+		s.Window = DefaultSmoothingWindow
+	}
+	if s.Threshold <= 0 {
+		s.Threshold = DefaultElevationThreshold
+	}
+	return s, nil
+}
+
+// Grade is the smoothed elevation of a point.
+type Grade struct {
+	// Distance is how far from the start the point is, in meters.
+	Distance  float64 `json:"distance"`
+	Elevation float64 `json:"elevation"`
+	// Gradient is the rise over the run around the point, 0.05 being a 5% climb.
+	Gradient float64 `json:"gradient"`
+}
+
+// ElevationProfile is the smoothed elevation of every point of an activity.
+type ElevationProfile struct {
+	Grades []Grade `json:"grades"`
+	// Gain and Loss are the smoothed climbs and descents, in meters.
+	Gain float64 `json:"gain"`
+	Loss float64 `json:"loss"`
+}
+
+// SmoothElevations smooths the elevations of every point of an activity, with
+// the Smoothing of the Options. Points without an elevation get one
+// interpolated from the points around them. The gradient of a point is the
+// rise over the run from the point before it to the one after it.
+func SmoothElevations(g *gpx.GPX, opts ...Options) (ElevationProfile, error) {
+	smoothing, err := withDefaults(opts).Smoothing.withDefaults()
+	if err != nil {
+		return ElevationProfile{}, err
+	}
+	p, err := newProfile(g)
+	if err != nil {
+		return ElevationProfile{}, err
+	}
+	smoothed, ok := p.smoothElevations(smoothing)
+	if !ok {
+		return ElevationProfile{}, ErrNoElevation
+	}
+
+	var profile ElevationProfile
+	for idx := range p.points {
+		before, after := idx, idx
+		if idx != 0 {
+			before--
+		}
+		if idx != len(p.points)-1 {
+			after++
+		}
+		grade := Grade{Distance: p.distances[idx], Elevation: smoothed[idx]}
+		if run := p.distances[after] - p.distances[before]; run > 0 {
+			grade.Gradient = (smoothed[after] - smoothed[before]) / run
+		}
+		profile.Grades = append(profile.Grades, grade)
+		if idx != 0 {
+			if climb := smoothed[idx] - smoothed[idx-1]; climb > 0 {
+				profile.Gain += climb
+			} else {
+				profile.Loss -= climb
+			}
+		}
+	}
+	return profile, nil
+}
+
+// smoothElevations smooths the elevations of a profile, false if it has none.
+// The Smoothing must have gone through withDefaults.
+func (p profile) smoothElevations(s Smoothing) ([]float64, bool) {
+	raw, ok := p.filledElevations()
+	if !ok {
+		return nil, false
+	}
+	smoothed := make([]float64, len(raw))
+	switch s.Method {
+	case Thresholding:
+		level := raw[0]
+		for idx, elevation := range raw {
+			switch {
+			case elevation > level+s.Threshold:
+				level = elevation - s.Threshold
+			case elevation < level-s.Threshold:
+				level = elevation + s.Threshold
+			}
+			smoothed[idx] = level
+		}
+	case MovingAverage:
+		half := s.Window / 2
+		from, to, sum := 0, 0, 0.0
+		for idx := range raw {
+			for to < len(raw) && p.distances[to] <= p.distances[idx]+half {
+				sum += raw[to]
+				to++
+			}
+			for p.distances[from] < p.distances[idx]-half {
+				sum -= raw[from]
+				from++
+			}
+			smoothed[idx] = sum / float64(to-from)
+		}
+	}
+	return smoothed, true
+}
+
+// filledElevations lists the elevation of every point, interpolating the
+// missing ones by distance, false if no point has an elevation.
+func (p profile) filledElevations() ([]float64, bool) {
+	elevations := make([]float64, len(p.points))
+	last := -1
+	for idx := range p.points {
+		elevation, ok := elevationOf(&p.points[idx])
+		if !ok {
+			continue
+		}
+		elevations[idx] = elevation
+		for missing := last + 1; missing < idx; missing++ {
+			elevations[missing] = elevation
+			if last >= 0 && p.distances[idx] > p.distances[last] {
+				fraction := (p.distances[missing] - p.distances[last]) / (p.distances[idx] - p.distances[last])
+				elevations[missing] = elevations[last] + fraction*(elevation-elevations[last])
+			}
+		}
+		last = idx
+	}
+	if last < 0 {
+		return nil, false
+	}
+	for missing := last + 1; missing < len(p.points); missing++ {
+		elevations[missing] = elevations[last]
+	}
+	return elevations, true
+}
+
+// RunningCost is how much energy running a meter at a gradient takes, in
+// joules per kilogram, as measured by Minetti et al. in 2002:
+//
+//	C(i) = 155.4i⁵ - 30.4i⁴ - 43.3i³ + 46.3i² + 19.5i + 3.6
+//
+// Gradients steeper than 45% either way are taken as 45%, the steepest measured.
+func RunningCost(gradient float64) float64 {
+	i := math.Max(-maxGradient, math.Min(maxGradient, gradient))
+	return ((((155.4*i-30.4)*i-43.3)*i+46.3)*i+19.5)*i + 3.6
+}
+
+// equivalentDistances are how far from the start each point is, in meters of
+// flat ground taking the same energy to run, nil without elevations. Every step
+// between two points is weighted by the cost of its smoothed gradient over the
+// cost of running on the flat:
+//
+//	equivalent = Σ meters × C(gradient) / C(0)
+//
+// A grade adjusted pace is the time taken over the equivalent distance.
+func (p profile) equivalentDistances(s Smoothing) []float64 {
+	smoothed, ok := p.smoothElevations(s)
+	if !ok {
+		return nil
+	}
+	equivalent := make([]float64, len(p.points))
+	for idx := 1; idx < len(p.points); idx++ {
+		equivalent[idx] = equivalent[idx-1]
+		if run := p.distances[idx] - p.distances[idx-1]; run > 0 {
+			equivalent[idx] += run * RunningCost((smoothed[idx]-smoothed[idx-1])/run) / RunningCost(0)
+		}
+	}
+	return equivalent
+}
+
+// equivalentAt interpolates the equivalent distance after covering some meters.
+func (p profile) equivalentAt(equivalent []float64, meters float64) float64 {
+	idx := sort.SearchFloat64s(p.distances, meters)
+	if idx == 0 {
+		return equivalent[0]
+	}
+	if idx == len(p.points) {
+		return equivalent[idx-1]
+	}
+	fraction := 1.0
+	if run := p.distances[idx] - p.distances[idx-1]; run > 0 {
+		fraction = (meters - p.distances[idx-1]) / run
+	}
+	return equivalent[idx-1] + fraction*(equivalent[idx]-equivalent[idx-1])
+}
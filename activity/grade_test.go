@@ -0,0 +1,167 @@
+package activity
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// givenAHill creates a kilometer up a 5% hill and another back down, at 4 m/s.
+func givenAHill() []sample {
+	samples := then(steady(51, 5, 4), steady(51, 5, 4))
+	for idx := range samples {
+		samples[idx].elevation = 50 - math.Abs(samples[idx].meters-1000)*0.05
+	}
+	return samples
+}
+
+// givenJitter creates a flat run, a point a second, whose elevation jitters by 0.6 meters.
+func givenJitter() []sample {
+	samples := steady(401, 1, 4)
+	for idx := range samples {
+		samples[idx].elevation = 900 + 0.6*float64(idx%2)
+	}
+	return samples
+}
+
+// TestRunningCostFollowsMinetti verifies the cost of running at a few gradients.
+func TestRunningCostFollowsMinetti(t *testing.T) {
+	scenarios := map[float64]float64{0: 3.6, 0.05: 4.6852, -0.05: 2.7459, 0.45: 19.43, 0.9: 19.43}
+	for gradient, expected := range scenarios {
+		assert.InDelta(t, expected, RunningCost(gradient), 0.01, "at %v", gradient)
+	}
+}
+
+// TestSmoothElevationsRemovesJitter verifies both methods flatten jitter out.
+func TestSmoothElevationsRemovesJitter(t *testing.T) {
+	for _, method := range []SmoothingMethod{MovingAverage, Thresholding} {
+		// Arrange
+		g := givenATrack(givenJitter())
+		raw, _ := Summarize(g)
+
+		// Act
+		got, err := SmoothElevations(g, Options{Smoothing: Smoothing{Method: method}})
+
+		// Assert
+		require.Nil(t, err)
+		require.Len(t, got.Grades, 401)
+		assert.InDelta(t, 120, raw.Elevation.Gain, 1e-6, "the raw jitter climbs 120 meters")
+		assert.Less(t, got.Gain, raw.Elevation.Gain/10, method)
+		for _, grade := range got.Grades {
+			assert.InDelta(t, 0, grade.Gradient, 0.015, method, "the raw jitter is 15% steep")
+		}
+	}
+}
+
+// TestSmoothElevationsOfAHill verifies gains, losses and gradients on a hill.
+func TestSmoothElevationsOfAHill(t *testing.T) {
+	scenarios := map[SmoothingMethod]float64{
+		MovingAverage: 48.8, // rounding the top off
+		Thresholding:  47,   // lagging 3 meters behind
+	}
+	for method, expected := range scenarios {
+		// Arrange
+		g := givenATrack(givenAHill())
+
+		// Act
+		got, err := SmoothElevations(g, Options{Smoothing: Smoothing{Method: method}})
+
+		// Assert
+		require.Nil(t, err)
+		assert.InDelta(t, expected, got.Gain, 1, method)
+		assert.InDelta(t, 0.05, got.Grades[25].Gradient, 1e-6, "half way up")
+		assert.InDelta(t, -0.05, got.Grades[75].Gradient, 1e-6, "half way down")
+		assert.InDelta(t, 1000, got.Grades[50].Distance, 1e-6)
+	}
+}
+
+// TestSmoothElevationsFillsMissingOnes verifies points without elevations are interpolated, and activities without any are an error.
+func TestSmoothElevationsFillsMissingOnes(t *testing.T) {
+	// Arrange
+	g := givenATrack(givenAHill())
+	g.Tracks[0].Segments[0].Points[10].Elevation.SetNull()
+	flat := givenATrack(steady(11, 5, 4))
+	for idx := range flat.Tracks[0].Segments[0].Points {
+		flat.Tracks[0].Segments[0].Points[idx].Elevation.SetNull()
+	}
+
+	// Act
+	got, err := SmoothElevations(g, Options{Smoothing: Smoothing{Method: Thresholding, Threshold: 0.1}})
+	_, flatErr := SmoothElevations(flat)
+
+	// Assert
+	require.Nil(t, err)
+	assert.InDelta(t, 9.9, got.Grades[10].Elevation, 1e-6, "10 meters up, trailing by the threshold")
+	assert.ErrorIs(t, flatErr, ErrNoElevation)
+}
+
+// TestGradeAdjustedPaceOfAHill verifies climbs count faster and descents slower than the flat.
+func TestGradeAdjustedPaceOfAHill(t *testing.T) {
+	// Arrange
+	g := givenATrack(givenAHill())
+
+	// Act
+	splits, err := Splits(g, Kilometer)
+	summary, summaryErr := Summarize(g)
+
+	// Assert
+	require.Nil(t, err)
+	require.Nil(t, summaryErr)
+	require.Len(t, splits, 2)
+	assert.Equal(t, Duration(250*time.Second), splits[0].Pace)
+	// 250 / (4.6852 / 3.6) and 250 / (2.7459 / 3.6), give or take the smoothing over the top
+	assert.InDelta(t, 192.1, time.Duration(splits[0].GradeAdjustedPace).Seconds(), 2)
+	assert.InDelta(t, 327.8, time.Duration(splits[1].GradeAdjustedPace).Seconds(), 3)
+	// 500 seconds over 1000 × (4.6852 + 2.7459) / 3.6 meters
+	assert.InDelta(t, 242.2, time.Duration(summary.GradeAdjustedPace).Seconds(), 2)
+}
+
+// TestGradeAdjustedPaceOnTheFlat verifies flat splits have the same grade adjusted pace as their pace.
+func TestGradeAdjustedPaceOnTheFlat(t *testing.T) {
+	// Act
+	splits, err := Splits(givenATrack(givenJitter()), Kilometer)
+
+	// Assert
+	require.Nil(t, err)
+	for _, split := range splits {
+		assert.InDelta(t, float64(split.Pace), float64(split.GradeAdjustedPace), float64(time.Second))
+	}
+}
+
+// TestSmoothElevationsOfTheGuineaPig verifies smoothing takes the jitter out of the half marathon's gain.
+func TestSmoothElevationsOfTheGuineaPig(t *testing.T) {
+	// Arrange
+	g := readGuineaPigFile(t)
+	raw, _ := Summarize(g)
+
+	for _, method := range []SmoothingMethod{MovingAverage, Thresholding} {
+		// Act
+		got, err := SmoothElevations(g, Options{Smoothing: Smoothing{Method: method}})
+
+		// Assert
+		require.Nil(t, err)
+		assert.Less(t, got.Gain, raw.Elevation.Gain, method)
+		assert.Greater(t, got.Gain, raw.Elevation.Gain/3, method)
+		t.Logf("%v: gained %.1fm and lost %.1fm, raw %.1fm and %.1fm", method, got.Gain, got.Loss, raw.Elevation.Gain, raw.Elevation.Loss)
+	}
+}
+
+// TestUnknownSmoothingMethodsAreRefused verifies smoothing with a method nobody knows fails instead of averaging.
+func TestUnknownSmoothingMethodsAreRefused(t *testing.T) {
+	// Arrange
+	g := givenATrack(givenAHill())
+	opts := Options{Smoothing: Smoothing{Method: "Threshold"}}
+
+	// Act
+	_, smoothErr := SmoothElevations(g, opts)
+	_, summaryErr := Summarize(g, opts)
+	_, splitsErr := Splits(g, Kilometer, opts)
+
+	// Assert
+	assert.Error(t, smoothErr)
+	assert.Error(t, summaryErr)
+	assert.Error(t, splitsErr)
+}
@@ -0,0 +1,207 @@
+package activity
+
+import (
+	"time"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// State is what a runner was doing during a period of an activity.
+type State string
+
+const (
+	Moving   State = "moving"
+	Paused   State = "paused"
+	Running  State = "running"
+	Walking  State = "walking"
+	Standing State = "standing"
+)
+
+// Period is a stretch of an activity during which the runner kept doing the same thing.
+type Period struct {
+	State     State     `json:"state"`
+	StartedAt time.Time `json:"startedAt"`
+	EndedAt   time.Time `json:"endedAt"`
+	// StartIndex and EndIndex are the first and last point of the period,
+	// counting the points of every segment in order.
+	StartIndex int      `json:"startIndex"`
+	EndIndex   int      `json:"endIndex"`
+	Elapsed    Duration `json:"elapsed"`
+	// Distance is in meters.
+	Distance float64 `json:"distance"`
+	// AverageSpeed is in meters per second.
+	AverageSpeed float64 `json:"averageSpeed"`
+	// Pace is the time per kilometer, zero when the period covered no distance.
+	Pace             Duration `json:"pace,omitempty"`
+	AverageHeartRate float64  `json:"averageHeartRate,omitempty"`
+	AverageCadence   float64  `json:"averageCadence,omitempty"`
+}
+
+// Periods splits an activity into Moving and Paused periods, like a watch's
+// auto-pause would. The runner is moving whenever they go at MovingSpeed or
+// faster, measured over MinPeriod so standing still with a jittery GPS isn't
+// moving. Pauses and movements shorter than MinPeriod are folded into the
+// period before them and the gaps between segments are always pauses.
+func Periods(g *gpx.GPX, opts ...Options) ([]Period, error) {
+	options := withDefaults(opts)
+	p, err := newProfile(g)
+	if err != nil {
+		return nil, err
+	}
+	speeds := p.windowedSpeeds(options.MinPeriod)
+	return p.periods(options.MinPeriod, func(idx int) State {
+		if speeds[idx] >= options.MovingSpeed {
+			return Moving
+		}
+		return Paused
+	}), nil
+}
+
+// Labels splits an activity into Running, Walking and Standing periods, going
+// further than Periods. Below MovingSpeed the runner is standing, above it they
+// are running when their cadence is at least RunningCadence. Points without a
+// cadence fall back to their speed, running from RunningSpeed on. Gaps between
+// segments are Paused.
+func Labels(g *gpx.GPX, opts ...Options) ([]Period, error) {
+	options := withDefaults(opts)
+	p, err := newProfile(g)
+	if err != nil {
+		return nil, err
+	}
+	speeds := p.windowedSpeeds(options.MinPeriod)
+	return p.periods(options.MinPeriod, func(idx int) State {
+		switch sample := DecodeSample(&p.points[idx]); {
+		case speeds[idx] < options.MovingSpeed:
+			return Standing
+		case sample.Cadence != nil && *sample.Cadence > 0:
+			if *sample.Cadence >= options.RunningCadence {
+				return Running
+			}
+			return Walking
+		case speeds[idx] >= options.RunningSpeed:
+			return Running
+		default:
+			return Walking
+		}
+	}), nil
+}
+
+// windowedSpeeds is how fast the runner went around each point, the distance
+// as the crow flies between the points a window apart, centered on the point.
+// Measuring the displacement rather than the distance travelled cancels out the
+// jitter of a GPS that stands still.
+func (p profile) windowedSpeeds(window time.Duration) []float64 {
+	speeds := make([]float64, len(p.points))
+	half := window / 2
+	from, to := 0, 0
+	for idx := range p.points {
+		if p.segmentStarts[idx] {
+			from = idx
+		}
+		for from < idx && p.points[idx].Timestamp.Sub(p.points[from].Timestamp) > half {
+			from++
+		}
+		if to < idx {
+			to = idx
+		}
+		for to+1 < len(p.points) && !p.segmentStarts[to+1] && p.points[to+1].Timestamp.Sub(p.points[idx].Timestamp) <= half {
+			to++
+		}
+
+		first, last := from, to
+		switch {
+		case first != last:
+		case !p.segmentStarts[idx]:
+			first = idx - 1 // points further apart than the window
+		case idx+1 < len(p.points) && !p.segmentStarts[idx+1]:
+			last = idx + 1
+		default:
+			continue // a segment of its own
+		}
+		if elapsed := p.points[last].Timestamp.Sub(p.points[first].Timestamp); elapsed > 0 {
+			speeds[idx] = distance(&p.points[first], &p.points[last]) / elapsed.Seconds()
+		}
+	}
+	return speeds
+}
+
+// stretch is a run of steps in the same state, from a point to another.
+type stretch struct {
+	state    State
+	from, to int
+	// gap tells the stretch is the gap between two segments.
+	gap bool
+}
+
+// periods groups the steps of a profile by their state, the state of a step
+// being the one stateOf tells for the point it ends at. Stretches shorter than
+// minPeriod are folded into the stretch before them, or the one after them
+// when they start the activity or a segment.
+func (p profile) periods(minPeriod time.Duration, stateOf func(idx int) State) []Period {
+	var stretches []stretch
+	for idx := 1; idx < len(p.points); idx++ {
+		if p.segmentStarts[idx] {
+			stretches = append(stretches, stretch{state: Paused, from: idx - 1, to: idx, gap: true})
+			continue
+		}
+		state := stateOf(idx)
+		if last := len(stretches) - 1; last >= 0 && !stretches[last].gap && stretches[last].state == state {
+			stretches[last].to = idx
+			continue
+		}
+		stretches = append(stretches, stretch{state: state, from: idx - 1, to: idx})
+	}
+
+	var folded []stretch
+	for _, s := range stretches {
+		last := len(folded) - 1
+		if last >= 0 && !s.gap && !folded[last].gap && (folded[last].state == s.state || !p.lasts(s, minPeriod)) {
+			folded[last].to = s.to
+			continue
+		}
+		folded = append(folded, s)
+	}
+
+	periods := make([]Period, 0, len(folded))
+	for idx, s := range folded {
+		leading := idx == 0 || folded[idx-1].gap
+		if leading && !s.gap && idx+1 < len(folded) && !folded[idx+1].gap && !p.lasts(s, minPeriod) {
+			folded[idx+1].from = s.from
+			continue
+		}
+		periods = append(periods, p.period(s))
+	}
+	return periods
+}
+
+// lasts tells whether a stretch lasts at least some time.
+func (p profile) lasts(s stretch, duration time.Duration) bool {
+	return p.points[s.to].Timestamp.Sub(p.points[s.from].Timestamp) >= duration
+}
+
+// period sums up a stretch.
+func (p profile) period(s stretch) Period {
+	elapsed := p.points[s.to].Timestamp.Sub(p.points[s.from].Timestamp)
+	period := Period{
+		State:      s.state,
+		StartedAt:  p.points[s.from].Timestamp,
+		EndedAt:    p.points[s.to].Timestamp,
+		StartIndex: s.from,
+		EndIndex:   s.to,
+		Elapsed:    Duration(elapsed),
+		Distance:   p.distances[s.to] - p.distances[s.from],
+	}
+	if elapsed > 0 {
+		period.AverageSpeed = period.Distance / elapsed.Seconds()
+	}
+	period.Pace = paceOf(elapsed, period.Distance)
+	if !s.gap {
+		samples := make([]Sample, 0, s.to-s.from+1)
+		for idx := s.from; idx <= s.to; idx++ {
+			samples = append(samples, DecodeSample(&p.points[idx]))
+		}
+		sensors := SummarizeSamples(samples)
+		period.AverageHeartRate, period.AverageCadence = sensors.AverageHeartRate, sensors.AverageCadence
+	}
+	return period
+}
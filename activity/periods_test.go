@@ -0,0 +1,147 @@
+package activity
+
+import (
+	"encoding/xml"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// statesOf lists the states of some periods.
+func statesOf(periods []Period) []State {
+	states := make([]State, len(periods))
+	for idx, period := range periods {
+		states[idx] = period.State
+	}
+	return states
+}
+
+// withCadence gives every point of an activity a cadence reading.
+func withCadence(g *gpx.GPX, cadence int) *gpx.GPX {
+	for _, track := range g.Tracks {
+		for _, segment := range track.Segments {
+			for idx := range segment.Points {
+				segment.Points[idx].Extensions.Nodes = []gpx.ExtensionNode{{
+					XMLName: xml.Name{Space: TrackPointExtensionV1, Local: trackPointExtension},
+					Nodes:   []gpx.ExtensionNode{{XMLName: xml.Name{Space: TrackPointExtensionV1, Local: "cad"}, Data: strconv.Itoa(cadence)}},
+				}}
+			}
+		}
+	}
+	return g
+}
+
+// TestPeriodsFindStops verifies a two minutes stop with a jittery GPS is a pause.
+func TestPeriodsFindStops(t *testing.T) {
+	// Arrange
+	g := givenATrack(givenAStop())
+
+	// Act
+	got, err := Periods(g)
+
+	// Assert
+	require.Nil(t, err)
+	require.Equal(t, []State{Moving, Paused, Moving}, statesOf(got))
+	assert.InDelta(t, 120, time.Duration(got[1].Elapsed).Seconds(), 10)
+	assert.InDelta(t, 1000, got[0].Distance, 20)
+	assert.Equal(t, Duration(250*time.Second), got[0].Pace)
+	assert.InDelta(t, 4, got[2].AverageSpeed, 0.1)
+	assert.Equal(t, got[0].EndIndex, got[1].StartIndex, "periods follow each other")
+	assert.Equal(t, got[1].EndedAt, got[2].StartedAt)
+}
+
+// TestPeriodsIgnoreShortStops verifies stops shorter than MinPeriod aren't pauses.
+func TestPeriodsIgnoreShortStops(t *testing.T) {
+	// Arrange
+	g := givenATrack(then(then(steady(51, 5, 4), []sample{{}, {seconds: 6}}), steady(51, 5, 4)))
+
+	// Act
+	got, err := Periods(g)
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, []State{Moving}, statesOf(got))
+	assert.Equal(t, startedAt, got[0].StartedAt)
+	assert.Equal(t, g.GetTrackPointsNo()-1, got[0].EndIndex)
+}
+
+// TestPeriodsPauseBetweenSegments verifies gaps between segments are pauses, however short.
+func TestPeriodsPauseBetweenSegments(t *testing.T) {
+	// Arrange
+	second := steady(51, 5, 4)
+	for idx := range second {
+		second[idx].seconds += 255
+		second[idx].meters += 1000
+	}
+	g := givenATrack(steady(51, 5, 4), second)
+
+	// Act
+	got, err := Periods(g, Options{MinPeriod: time.Minute})
+
+	// Assert
+	require.Nil(t, err)
+	require.Equal(t, []State{Moving, Paused, Moving}, statesOf(got))
+	assert.Equal(t, Duration(5*time.Second), got[1].Elapsed)
+	assert.Zero(t, got[1].Distance)
+}
+
+// TestLabelsTellRunningWalkingAndStanding verifies labels by speed when there's no cadence.
+func TestLabelsTellRunningWalkingAndStanding(t *testing.T) {
+	// Arrange
+	g := givenATrack(then(then(steady(61, 5, 3), steady(61, 5, 1.4)), givenAStop()[50:]))
+
+	// Act
+	got, err := Labels(g)
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, []State{Running, Walking, Standing, Running}, statesOf(got))
+	assert.InDelta(t, 300, time.Duration(got[1].Elapsed).Seconds(), 10)
+}
+
+// TestLabelsPreferCadence verifies a slow jog at a running cadence is running and a fast walk isn't.
+func TestLabelsPreferCadence(t *testing.T) {
+	scenarios := map[int]State{80: Running, 55: Walking}
+	for cadence, expected := range scenarios {
+		// Arrange
+		g := withCadence(givenATrack(steady(61, 5, 1.8)), cadence)
+
+		// Act
+		got, err := Labels(g)
+
+		// Assert
+		require.Nil(t, err)
+		assert.Equal(t, []State{expected}, statesOf(got), "at %v steps per minute", cadence)
+		assert.Equal(t, float64(cadence), got[0].AverageCadence)
+	}
+}
+
+// TestLabelsOfTheGuineaPig verifies the half marathon was run and its periods cover it all.
+func TestLabelsOfTheGuineaPig(t *testing.T) {
+	// Arrange
+	g := readGuineaPigFile(t)
+	summary, _ := Summarize(g)
+
+	// Act
+	got, err := Labels(g)
+
+	// Assert
+	require.Nil(t, err)
+	var elapsed, running time.Duration
+	var travelled float64
+	for _, period := range got {
+		elapsed += time.Duration(period.Elapsed)
+		travelled += period.Distance
+		if period.State == Running {
+			running += time.Duration(period.Elapsed)
+		}
+	}
+	assert.Equal(t, time.Duration(summary.TotalTime), elapsed)
+	assert.InDelta(t, summary.Distance, travelled, 0.001)
+	assert.Greater(t, running, elapsed*9/10)
+	t.Logf("%v periods, %v of %v running", len(got), running, elapsed)
+}
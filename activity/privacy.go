@@ -0,0 +1,194 @@
+package activity
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// ErrAllPrivate is returned when every track point of an activity is within a privacy zone.
+var ErrAllPrivate = errors.New("every track point of the activity is within a privacy zone")
+
+const (
+	// edgeIterations is how many times the step across the edge of a zone is halved when clipping.
+	edgeIterations = 32
+	// edgeMargin is how far outside a zone, in meters, clipped points go, so
+	// rounding their coordinates when writing them never puts them back inside.
+	edgeMargin = 0.5
+)
+
+// PrivacyZone is a circle points are hidden within, such as around home.
+type PrivacyZone struct {
+	Center Coordinate `bson:"center" json:"center"`
+	// Radius is in meters.
+	Radius float64 `bson:"radius" json:"radius"`
+}
+
+// contains tells whether a point is within the zone.
+func (z PrivacyZone) contains(point *gpx.GPXPoint) bool {
+	center := z.Center.point()
+	return distance(&center, point) <= z.Radius
+}
+
+// withinZones tells whether points are within any of the zones, widened by a margin.
+func withinZones(zones []PrivacyZone, margin float64) func(point *gpx.GPXPoint) bool {
+	return func(point *gpx.GPXPoint) bool {
+		for _, zone := range zones {
+			zone.Radius += margin
+			if zone.contains(point) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// PrivacyMode is how the edge of a privacy zone is dealt with.
+type PrivacyMode string
+
+const (
+	// Strip drops the points within zones, so tracks end at the last point
+	// recorded before entering a zone and start at the first one after leaving.
+	Strip PrivacyMode = "strip"
+	// Clip drops the points within zones too, but ends and starts tracks right
+	// at the edge of the zone. Clipped tracks look tidier, yet their ends trace
+	// the circle around whatever the zone hides.
+	Clip PrivacyMode = "clip"
+)
+
+// PrivacyReport tells how much hiding the privacy zones changed an activity.
+type PrivacyReport struct {
+	// Removed is how many track points, route points and waypoints were dropped.
+	Removed int `json:"removed"`
+	// Clipped is how many track points were put on the edge of a zone.
+	Clipped int `json:"clipped"`
+}
+
+// HidePrivacyZones drops every point of an activity within a privacy zone,
+// leaving the activity untouched and returning a sanitized copy of it. Track
+// segments are split wherever they went through a zone, so no line crosses
+// it, and segments, tracks or routes left without points are dropped. The time of the
+// activity becomes the time of its first track point left, when it had one.
+func HidePrivacyZones(g *gpx.GPX, mode PrivacyMode, zones ...PrivacyZone) (*gpx.GPX, PrivacyReport, error) {
+	if mode != Strip && mode != Clip {
+		return nil, PrivacyReport{}, fmt.Errorf("privacy zones are either stripped or clipped, not %q", mode)
+	}
+	for _, zone := range zones {
+		if zone.Radius <= 0 {
+			return nil, PrivacyReport{}, errors.New("privacy zones need a radius")
+		}
+	}
+	inside, nearby := withinZones(zones, 0), withinZones(zones, edgeMargin)
+
+	var report PrivacyReport
+	hidden := *g
+	hidden.Waypoints, hidden.Routes, hidden.Tracks = nil, nil, nil
+	for _, waypoint := range g.Waypoints {
+		if inside(&waypoint) {
+			report.Removed++
+			continue
+		}
+		hidden.Waypoints = append(hidden.Waypoints, waypoint)
+	}
+	for _, route := range g.Routes {
+		points := route.Points
+		route.Points = nil
+		for _, point := range points {
+			if inside(&point) {
+				report.Removed++
+				continue
+			}
+			route.Points = append(route.Points, point)
+		}
+		if len(route.Points) != 0 {
+			hidden.Routes = append(hidden.Routes, route)
+		}
+	}
+
+	var first *gpx.GPXPoint
+	for _, track := range g.Tracks {
+		segments := track.Segments
+		track.Segments = nil
+		for _, segment := range segments {
+			for _, points := range splitAtZones(segment.Points, mode, inside, nearby, &report) {
+				if first == nil {
+					first = &points[0]
+				}
+				track.Segments = append(track.Segments, gpx.GPXTrackSegment{Points: points, Extensions: segment.Extensions})
+			}
+		}
+		if len(track.Segments) != 0 {
+			hidden.Tracks = append(hidden.Tracks, track)
+		}
+	}
+	if first == nil && len(segments(g)) != 0 {
+		return nil, report, ErrAllPrivate
+	}
+	if g.Time != nil && first != nil {
+		startedAt := first.Timestamp
+		hidden.Time = &startedAt
+	}
+	return &hidden, report, nil
+}
+
+// splitAtZones splits points into the stretches outside zones. Clipping puts a
+// point on the edge of the zone before and after each stretch that borders one,
+// unless the stretch already ends nearby the edge.
+func splitAtZones(points []gpx.GPXPoint, mode PrivacyMode, inside, nearby func(*gpx.GPXPoint) bool, report *PrivacyReport) [][]gpx.GPXPoint {
+	var stretches [][]gpx.GPXPoint
+	var stretch []gpx.GPXPoint
+	for idx := range points {
+		if inside(&points[idx]) {
+			report.Removed++
+			if len(stretch) != 0 {
+				if mode == Clip && !nearby(&points[idx-1]) {
+					stretch = append(stretch, edgeOf(points[idx-1], points[idx], nearby))
+					report.Clipped++
+				}
+				stretches = append(stretches, stretch)
+				stretch = nil
+			}
+			continue
+		}
+		if len(stretch) == 0 && idx != 0 && mode == Clip && !nearby(&points[idx]) {
+			stretch = append(stretch, edgeOf(points[idx], points[idx-1], nearby))
+			report.Clipped++
+		}
+		stretch = append(stretch, points[idx])
+	}
+	if len(stretch) != 0 {
+		stretches = append(stretches, stretch)
+	}
+	return stretches
+}
+
+// edgeOf finds where the step from a point outside zones to a point inside one
+// stops being nearby a zone, edgeMargin outside its edge, by halving the step over and over. Times and
+// elevations are interpolated, everything else comes from the outside point.
+func edgeOf(outside, in gpx.GPXPoint, nearby func(*gpx.GPXPoint) bool) gpx.GPXPoint {
+	at := func(fraction float64) gpx.GPXPoint {
+		point := outside
+		point.Latitude += fraction * (in.Latitude - outside.Latitude)
+		point.Longitude += fraction * (in.Longitude - outside.Longitude)
+		point.Timestamp = outside.Timestamp.Add(time.Duration(fraction * float64(in.Timestamp.Sub(outside.Timestamp))))
+		elevationOutside, okOutside := elevationOf(&outside)
+		elevationIn, okIn := elevationOf(&in)
+		if elevation := blend(elevationOutside, okOutside, elevationIn, okIn, fraction); elevation != nil {
+			point.Elevation.SetValue(*elevation)
+		}
+		return point
+	}
+	// the edge is always kept on the outside, so the clipped point is never nearby
+	low, high := 0.0, 1.0
+	for iteration := 0; iteration < edgeIterations; iteration++ {
+		middle := (low + high) / 2
+		if point := at(middle); nearby(&point) {
+			high = middle
+		} else {
+			low = middle
+		}
+	}
+	return at(low)
+}
@@ -0,0 +1,164 @@
+package activity
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// givenARunFromHome creates an out and back that starts and ends at home, at
+// the equator, with a waypoint at home and another at the turnaround.
+func givenARunFromHome() *gpx.GPX {
+	g := givenATrack(givenAnOutAndBack())
+	g.Time = &startedAt
+	g.Waypoints = []gpx.GPXPoint{
+		{Point: gpx.Point{Latitude: 0, Longitude: 0}, Name: "Home"},
+		{Point: gpx.Point{Latitude: 2000 / metersPerDegree, Longitude: 0}, Name: "Turnaround"},
+	}
+	return g
+}
+
+// givenTheZones creates a zone around home and another half way to the turnaround.
+func givenTheZones() []PrivacyZone {
+	return []PrivacyZone{
+		{Center: Coordinate{}, Radius: 210},
+		{Center: Coordinate{Latitude: 1000 / metersPerDegree}, Radius: 110},
+	}
+}
+
+// metersOf lists how far north of the equator every point of every segment is, rounded to the meter.
+func metersOf(g *gpx.GPX) [][]float64 {
+	var meters [][]float64
+	for _, points := range segments(g) {
+		var segment []float64
+		for _, point := range points {
+			segment = append(segment, float64(int(point.Latitude*metersPerDegree+0.5)))
+		}
+		meters = append(meters, segment)
+	}
+	return meters
+}
+
+// TestStripSplitsAroundZones verifies stripping drops the points within zones and splits the track where it went through one.
+func TestStripSplitsAroundZones(t *testing.T) {
+	// Arrange
+	g := givenARunFromHome()
+
+	// Act
+	got, report, err := HidePrivacyZones(g, Strip, givenTheZones()...)
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, PrivacyReport{Removed: 45}, report, "44 track points and a waypoint")
+	meters := metersOf(got)
+	require.Len(t, meters, 3)
+	assert.Len(t, meters[0], 34)
+	assert.Len(t, meters[1], 89)
+	assert.Len(t, meters[2], 34)
+	assert.Equal(t, []float64{220, 880}, []float64{meters[0][0], meters[0][33]})
+	assert.Equal(t, []float64{1120, 1120}, []float64{meters[1][0], meters[1][88]})
+	assert.Equal(t, []float64{880, 220}, []float64{meters[2][0], meters[2][33]})
+	require.Len(t, got.Waypoints, 1)
+	assert.Equal(t, "Turnaround", got.Waypoints[0].Name)
+	assert.Equal(t, startedAt.Add(55*time.Second), *got.Time)
+	assert.Len(t, segments(g)[0], 201, "the original is untouched")
+	assert.Equal(t, startedAt, *g.Time)
+}
+
+// TestClipEndsTracksAtTheEdge verifies clipping puts a point where the track crosses the edge of a zone.
+func TestClipEndsTracksAtTheEdge(t *testing.T) {
+	// Act
+	got, report, err := HidePrivacyZones(givenARunFromHome(), Clip, givenTheZones()...)
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, PrivacyReport{Removed: 45, Clipped: 6}, report)
+	edges := segments(got)
+	require.Len(t, edges, 3)
+	assert.Equal(t, []int{36, 91, 36}, []int{len(edges[0]), len(edges[1]), len(edges[2])}, "a clipped point at either end")
+	scenarios := map[*gpx.GPXPoint]float64{
+		&edges[0][0]: 210 + edgeMargin, &edges[0][35]: 890 - edgeMargin,
+		&edges[1][0]: 1110 + edgeMargin, &edges[1][90]: 1110 + edgeMargin,
+		&edges[2][0]: 890 - edgeMargin, &edges[2][35]: 210 + edgeMargin,
+	}
+	for point, expected := range scenarios {
+		assert.InDelta(t, expected, point.Latitude*metersPerDegree, 0.01)
+	}
+	assert.InDelta(t, 52.625, edges[0][0].Timestamp.Sub(startedAt).Seconds(), 0.001)
+	assert.Equal(t, edges[0][0].Timestamp, *got.Time)
+	for _, points := range segments(got) {
+		for _, point := range points {
+			for _, zone := range givenTheZones() {
+				assert.False(t, zone.contains(&point))
+			}
+		}
+	}
+}
+
+// TestRoutesWithinZonesAreDropped verifies routes left without points don't show they existed.
+func TestRoutesWithinZonesAreDropped(t *testing.T) {
+	// Arrange
+	g := givenARunFromHome()
+	g.Routes = []gpx.GPXRoute{
+		{Name: "Around the block", Points: []gpx.GPXPoint{{Point: gpx.Point{Latitude: 0}}, {Point: gpx.Point{Latitude: 100 / metersPerDegree}}}},
+		{Name: "To the turnaround", Points: []gpx.GPXPoint{{Point: gpx.Point{Latitude: 0}}, {Point: gpx.Point{Latitude: 2000 / metersPerDegree}}}},
+	}
+
+	// Act
+	got, report, err := HidePrivacyZones(g, Strip, givenTheZones()...)
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, 45+3, report.Removed, "three route points within the zone around home")
+	require.Len(t, got.Routes, 1)
+	assert.Equal(t, "To the turnaround", got.Routes[0].Name)
+	assert.Len(t, got.Routes[0].Points, 1)
+	assert.Len(t, g.Routes, 2, "the original is untouched")
+}
+
+// TestHidingEverythingIsAnError verifies an activity entirely within a zone can't be shared.
+func TestHidingEverythingIsAnError(t *testing.T) {
+	// Act
+	_, _, err := HidePrivacyZones(givenARunFromHome(), Strip, PrivacyZone{Radius: 5000})
+
+	// Assert
+	assert.ErrorIs(t, err, ErrAllPrivate)
+}
+
+// TestHidingNeedsAModeAndRadiuses verifies bad modes and zones are refused.
+func TestHidingNeedsAModeAndRadiuses(t *testing.T) {
+	_, _, err := HidePrivacyZones(givenARunFromHome(), "blur", givenTheZones()...)
+	assert.Error(t, err)
+	_, _, err = HidePrivacyZones(givenARunFromHome(), Strip, PrivacyZone{})
+	assert.Error(t, err)
+}
+
+// TestHiddenActivitiesRoundTrip verifies a sanitized guinea pig is still a GPX with everything outside its zone.
+func TestHiddenActivitiesRoundTrip(t *testing.T) {
+	// Arrange
+	g := readGuineaPigFile(t)
+	start := segments(g)[0][0]
+	zone := PrivacyZone{Center: Coordinate{Latitude: start.Latitude, Longitude: start.Longitude}, Radius: 300}
+
+	// Act
+	hidden, report, err := HidePrivacyZones(g, Clip, zone)
+	require.Nil(t, err)
+	var written bytes.Buffer
+	require.Nil(t, WriteGPX(&written, hidden))
+	got, err := gpx.ParseBytes(written.Bytes())
+
+	// Assert
+	require.Nil(t, err)
+	assert.Greater(t, report.Removed, 0)
+	assert.Equal(t, 1657-report.Removed+report.Clipped, got.GetTrackPointsNo())
+	for _, points := range segments(got) {
+		for _, point := range points {
+			center := zone.Center.point()
+			assert.False(t, zone.contains(&point), distance(&center, &point))
+		}
+	}
+}
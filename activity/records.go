@@ -0,0 +1,46 @@
+package activity
+
+import "github.com/tkrajina/gpxgo/gpx"
+
+// Record is a track point flattened along with its sensor readings and how
+// far the activity had gone by then, as the formats other tools read expect.
+type Record struct {
+	Sample
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lon"`
+	// Elevation is in meters, nil when the point has none.
+	Elevation *float64 `json:"ele,omitempty"`
+	// Distance is how far the point is from the start, in meters.
+	Distance float64 `json:"distance"`
+	// Speed is how fast the step from the point before was, in meters per
+	// second, nil when the point starts a segment or the step took no time.
+	Speed *float64 `json:"speed,omitempty"`
+	// SegmentStart tells the point starts a track segment.
+	SegmentStart bool `json:"segmentStart,omitempty"`
+}
+
+// Records flattens every track point of an activity into a Record, in order.
+func Records(g *gpx.GPX) ([]Record, error) {
+	p, err := newProfile(g)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]Record, len(p.points))
+	for idx := range p.points {
+		point := &p.points[idx]
+		records[idx] = Record{
+			Sample:       DecodeSample(point),
+			Latitude:     point.Latitude,
+			Longitude:    point.Longitude,
+			Distance:     p.distances[idx],
+			SegmentStart: p.segmentStarts[idx],
+		}
+		if elevation, ok := elevationOf(point); ok {
+			records[idx].Elevation = &elevation
+		}
+		if speed, ok := p.speedAt(idx); ok {
+			records[idx].Speed = &speed
+		}
+	}
+	return records, nil
+}
@@ -0,0 +1,38 @@
+package activity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// TestRecordsFlattenEveryPoint verifies records carry the readings, distance and speed of every point.
+func TestRecordsFlattenEveryPoint(t *testing.T) {
+	// Arrange
+	first := []sample{{heartRate: 120}, {seconds: 5, meters: 20, elevation: 3, heartRate: 130}}
+	g := givenATrack(first, []sample{{seconds: 60, meters: 20}, {seconds: 70, meters: 50}})
+
+	// Act
+	got, err := Records(g)
+
+	// Assert
+	require.Nil(t, err)
+	require.Len(t, got, 4)
+	assert.Equal(t, []bool{true, false, true, false}, []bool{got[0].SegmentStart, got[1].SegmentStart, got[2].SegmentStart, got[3].SegmentStart})
+	assert.Equal(t, intOf(130), got[1].HeartRate)
+	assert.Nil(t, got[2].HeartRate)
+	assert.Equal(t, floatOf(3), got[1].Elevation)
+	assert.InDelta(t, 50, got[3].Distance, 0.01, "the gap between segments isn't distance")
+	assert.Nil(t, got[0].Speed)
+	assert.Nil(t, got[2].Speed)
+	assert.InDelta(t, 4, *got[1].Speed, 0.01)
+	assert.InDelta(t, 3, *got[3].Speed, 0.01)
+}
+
+// TestRecordsNeedPoints verifies activities without track points have no records.
+func TestRecordsNeedPoints(t *testing.T) {
+	_, err := Records(&gpx.GPX{})
+	assert.ErrorIs(t, err, ErrNoPoints)
+}
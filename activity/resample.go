@@ -0,0 +1,168 @@
+package activity
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// DefaultMaxGap is the longest time between two points that's still interpolated over.
+const DefaultMaxGap = 30 * time.Second
+
+// distanceRoundOff is how short of a step, in meters, the last step may fall and still be read.
+const distanceRoundOff = 1e-6
+
+// Resampling tells how to resample an activity, either Interval or Step must be set.
+type Resampling struct {
+	// Interval resamples the activity every so often.
+	Interval time.Duration
+	// Step resamples the activity every so many meters.
+	Step float64
+	// MaxGap is the longest time between two points that's interpolated over,
+	// defaults to DefaultMaxGap. Gaps between segments are always gaps.
+	MaxGap time.Duration
+	// SkipGaps leaves readings that fall within gaps out, rather than marking them.
+	SkipGaps bool
+}
+
+// Reading is a point of a resampled activity.
+type Reading struct {
+	Time time.Time `json:"time"`
+	// Distance is how far from the start the reading is, in meters.
+	Distance  float64  `json:"distance"`
+	Latitude  float64  `json:"lat"`
+	Longitude float64  `json:"lon"`
+	Elevation *float64 `json:"ele,omitempty"`
+	// HeartRate, Cadence and Temperature are interpolated, so they aren't whole numbers.
+	HeartRate   *float64 `json:"hr,omitempty"`
+	Cadence     *float64 `json:"cad,omitempty"`
+	Temperature *float64 `json:"atemp,omitempty"`
+	// Gap tells the reading falls within a gap. Gap readings keep the position
+	// of the point before the gap and have no elevation nor sensor readings.
+	Gap bool `json:"gap,omitempty"`
+}
+
+// Resample resamples an activity into readings a fixed time or distance apart,
+// starting at its first point. Positions, elevations and sensor readings are
+// interpolated between the points around each reading, when only one of them
+// has an elevation or a reading it's used as is.
+func Resample(g *gpx.GPX, r Resampling) ([]Reading, error) {
+	if r.Interval < 0 || r.Step < 0 || (r.Interval > 0) == (r.Step > 0) {
+		return nil, errors.New("resampling needs either an interval or a step")
+	}
+	if r.MaxGap <= 0 {
+		r.MaxGap = DefaultMaxGap
+	}
+	p, err := newProfile(g)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.points) == 1 {
+		return []Reading{p.readingAt(1, 0, r.MaxGap)}, nil
+	}
+
+	var readings []Reading
+	add := func(reading Reading) {
+		if !reading.Gap || !r.SkipGaps {
+			readings = append(readings, reading)
+		}
+	}
+	if r.Interval > 0 {
+		start, end := p.points[0].Timestamp, p.points[len(p.points)-1].Timestamp
+		idx := 1
+		for at := start; !at.After(end); at = at.Add(r.Interval) {
+			for idx < len(p.points)-1 && p.points[idx].Timestamp.Before(at) {
+				idx++
+			}
+			fraction := 0.0
+			if span := p.points[idx].Timestamp.Sub(p.points[idx-1].Timestamp); span > 0 {
+				fraction = float64(at.Sub(p.points[idx-1].Timestamp)) / float64(span)
+			}
+			reading := p.readingAt(idx, fraction, r.MaxGap)
+			reading.Time = at
+			add(reading)
+		}
+		return readings, nil
+	}
+
+	for step := 0; float64(step)*r.Step <= p.totalDistance()+distanceRoundOff; step++ {
+		covered := math.Min(float64(step)*r.Step, p.totalDistance())
+		idx := sort.SearchFloat64s(p.distances, covered)
+		if idx == 0 {
+			add(p.readingAt(1, 0, r.MaxGap))
+			continue
+		}
+		fraction := 1.0
+		if span := p.distances[idx] - p.distances[idx-1]; span > 0 {
+			fraction = (covered - p.distances[idx-1]) / span
+		}
+		add(p.readingAt(idx, fraction, r.MaxGap))
+	}
+	return readings, nil
+}
+
+// readingAt interpolates a reading between idx and the point before it, a
+// fraction of the way. A single point activity is read at idx 1 and fraction 0.
+func (p profile) readingAt(idx int, fraction float64, maxGap time.Duration) Reading {
+	before := &p.points[idx-1]
+	after := before
+	if idx < len(p.points) {
+		after = &p.points[idx]
+	}
+	reading := Reading{
+		Time:      before.Timestamp.Add(time.Duration(fraction * float64(after.Timestamp.Sub(before.Timestamp)))),
+		Distance:  p.distances[idx-1],
+		Latitude:  before.Latitude,
+		Longitude: before.Longitude,
+	}
+	if after != before {
+		if fraction > 0 && fraction < 1 && (p.segmentStarts[idx] || after.Timestamp.Sub(before.Timestamp) > maxGap) {
+			reading.Gap = true
+			return reading
+		}
+		reading.Distance += fraction * (p.distances[idx] - p.distances[idx-1])
+		reading.Latitude += fraction * (after.Latitude - before.Latitude)
+		reading.Longitude += fraction * (after.Longitude - before.Longitude)
+	}
+
+	elevationBefore, okBefore := elevationOf(before)
+	elevationAfter, okAfter := elevationOf(after)
+	reading.Elevation = blend(elevationBefore, okBefore, elevationAfter, okAfter, fraction)
+	sampleBefore, sampleAfter := DecodeSample(before), DecodeSample(after)
+	reading.HeartRate = blendReadings(sampleBefore.HeartRate, sampleAfter.HeartRate, fraction)
+	reading.Cadence = blendReadings(sampleBefore.Cadence, sampleAfter.Cadence, fraction)
+	reading.Temperature = blendReadings(sampleBefore.Temperature, sampleAfter.Temperature, fraction)
+	return reading
+}
+
+// blend interpolates a fraction of the way between two values, using the one
+// there is when the other is missing and nil when both are.
+func blend(before float64, okBefore bool, after float64, okAfter bool, fraction float64) *float64 {
+	var blended float64
+	switch {
+	case okBefore && okAfter:
+		blended = before + fraction*(after-before)
+	case okBefore:
+		blended = before
+	case okAfter:
+		blended = after
+	default:
+		return nil
+	}
+	return &blended
+}
+
+// blendReadings blends two sensor readings, either of which may be missing.
+func blendReadings[T int | float64](before, after *T, fraction float64) *float64 {
+	var valueBefore, valueAfter float64
+	if before != nil {
+		valueBefore = float64(*before)
+	}
+	if after != nil {
+		valueAfter = float64(*after)
+	}
+	return blend(valueBefore, before != nil, valueAfter, after != nil, fraction)
+}
@@ -0,0 +1,135 @@
+package activity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestResampleEverySecond verifies readings a second apart are interpolated in between points.
+func TestResampleEverySecond(t *testing.T) {
+	// Arrange
+	samples := steady(11, 5, 4)
+	for idx := range samples {
+		samples[idx].heartRate = 100 + idx*10
+		samples[idx].elevation = float64(idx)
+	}
+
+	// Act
+	got, err := Resample(givenATrack(samples), Resampling{Interval: time.Second})
+
+	// Assert
+	require.Nil(t, err)
+	require.Len(t, got, 51)
+	for idx, reading := range got {
+		assert.Equal(t, startedAt.Add(time.Duration(idx)*time.Second), reading.Time)
+		assert.InDelta(t, float64(idx)*4, reading.Distance, 0.01)
+		assert.InDelta(t, float64(idx)*4/metersPerDegree, reading.Latitude, 1e-9)
+		assert.False(t, reading.Gap)
+	}
+	assert.InDelta(t, 0.4, *got[2].Elevation, 1e-9)
+	assert.InDelta(t, 104, *got[2].HeartRate, 1e-9)
+	assert.Equal(t, 200.0, *got[50].HeartRate)
+	assert.Nil(t, got[2].Cadence)
+}
+
+// TestResampleEveryTenMeters verifies readings ten meters apart interpolate their time.
+func TestResampleEveryTenMeters(t *testing.T) {
+	// Arrange
+	g := givenATrack(steady(11, 5, 4))
+
+	// Act
+	got, err := Resample(g, Resampling{Step: 10})
+
+	// Assert
+	require.Nil(t, err)
+	require.Len(t, got, 21)
+	for idx, reading := range got {
+		assert.InDelta(t, float64(idx)*10, reading.Distance, 1e-6)
+		assert.Equal(t, startedAt.Add(time.Duration(idx)*2500*time.Millisecond), reading.Time.Round(time.Millisecond))
+	}
+}
+
+// givenALostSignal creates 200 meters at 4 m/s, a minute without a fix while going 240 meters and 200 meters more.
+// Only the points before and right after the lost signal have a heart rate.
+func givenALostSignal() []sample {
+	samples := then(steady(11, 5, 4), []sample{{}, {seconds: 60, meters: 240}})
+	for idx := range samples {
+		samples[idx].heartRate = 150
+	}
+	return then(samples, steady(11, 5, 4))
+}
+
+// TestResampleMarksGaps verifies readings within a lost signal are gaps, without readings.
+func TestResampleMarksGaps(t *testing.T) {
+	// Arrange
+	g := givenATrack(givenALostSignal())
+
+	// Act
+	got, err := Resample(g, Resampling{Interval: 10 * time.Second})
+
+	// Assert
+	require.Nil(t, err)
+	require.Len(t, got, 17)
+	for idx, reading := range got {
+		gap := idx > 5 && idx < 11
+		assert.Equal(t, gap, reading.Gap, "reading %v", idx)
+		if gap {
+			assert.Nil(t, reading.HeartRate)
+			assert.Nil(t, reading.Elevation)
+			assert.InDelta(t, 200, reading.Distance, 1e-6, "holding on to where the signal was lost")
+		}
+	}
+	assert.InDelta(t, 440, got[11].Distance, 1e-6)
+}
+
+// TestResampleSkipsGaps verifies gaps can be left out, as can gaps between segments.
+func TestResampleSkipsGaps(t *testing.T) {
+	// Arrange
+	second := steady(11, 5, 4)
+	for idx := range second {
+		second[idx].seconds += 100
+	}
+	g := givenATrack(steady(11, 5, 4), second)
+
+	// Act
+	got, err := Resample(g, Resampling{Interval: 10 * time.Second, MaxGap: time.Hour, SkipGaps: true})
+
+	// Assert
+	require.Nil(t, err)
+	require.Len(t, got, 6+6)
+	assert.Equal(t, startedAt.Add(50*time.Second), got[5].Time)
+	assert.Equal(t, startedAt.Add(100*time.Second), got[6].Time)
+}
+
+// TestResampleTheGuineaPig verifies the half marathon resampled every second has a reading per second.
+func TestResampleTheGuineaPig(t *testing.T) {
+	// Arrange
+	g := readGuineaPigFile(t)
+	summary, _ := Summarize(g)
+
+	// Act
+	got, err := Resample(g, Resampling{Interval: time.Second})
+
+	// Assert
+	require.Nil(t, err)
+	assert.Len(t, got, int(time.Duration(summary.TotalTime).Seconds())+1)
+	assert.InDelta(t, summary.Distance, got[len(got)-1].Distance, 0.001)
+	for _, reading := range got {
+		require.NotNil(t, reading.HeartRate)
+		require.NotNil(t, reading.Elevation)
+	}
+}
+
+// TestResampleNeedsAnIntervalOrAStep verifies resampling needs exactly one of them.
+func TestResampleNeedsAnIntervalOrAStep(t *testing.T) {
+	for _, scenario := range []Resampling{{}, {Interval: time.Second, Step: 10}, {Step: -1}} {
+		// Act
+		_, err := Resample(givenATrack(steady(11, 5, 4)), scenario)
+
+		// Assert
+		assert.Error(t, err, "%+v", scenario)
+	}
+}
@@ -0,0 +1,279 @@
+package activity
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// DefaultGateWidth is how wide, in meters, the start and end gates of a segment are.
+const DefaultGateWidth = 50.0
+
+// gridCell is the size, in degrees, of the cells SegmentMatcher files segments under.
+const gridCell = 0.01
+
+// Direction is which way a segment was traversed.
+type Direction string
+
+const (
+	// Forward traversals go from the first point of a segment to its last.
+	Forward Direction = "forward"
+	// Reverse traversals go from the last point of a segment to its first.
+	Reverse Direction = "reverse"
+)
+
+// Coordinate is a spot on the map.
+type Coordinate struct {
+	Latitude  float64 `bson:"lat" json:"lat"`
+	Longitude float64 `bson:"lon" json:"lon"`
+}
+
+// point turns a coordinate into a GPX point.
+func (c Coordinate) point() gpx.GPXPoint {
+	return gpx.GPXPoint{Point: gpx.Point{Latitude: c.Latitude, Longitude: c.Longitude}}
+}
+
+// Segment is a stretch of road or trail athletes race each other on. It starts
+// at a gate through its first point, square to its first leg, and ends at a
+// gate through its last point, square to its last leg.
+type Segment struct {
+	ID   string `bson:"_id,omitempty" json:"id,omitempty"`
+	Name string `bson:"name" json:"name"`
+	// Points are the polyline of the segment, at least two of them.
+	Points []Coordinate `bson:"points" json:"points"`
+	// GateWidth is how wide the gates are, in meters, defaults to DefaultGateWidth.
+	GateWidth float64 `bson:"gateWidth,omitempty" json:"gateWidth,omitempty"`
+	// Corridor is how far from the polyline, in meters, a traversal may stray, defaults to DefaultCorridor.
+	Corridor float64 `bson:"corridor,omitempty" json:"corridor,omitempty"`
+}
+
+// Traversal is a time an activity went through a segment.
+type Traversal struct {
+	SegmentID   string    `bson:"segmentId" json:"segmentId"`
+	SegmentName string    `bson:"segmentName" json:"segmentName"`
+	Direction   Direction `bson:"direction" json:"direction"`
+	// StartedAt and EndedAt are when the gates were crossed, interpolating between points.
+	StartedAt time.Time `bson:"startedAt" json:"startedAt"`
+	EndedAt   time.Time `bson:"endedAt" json:"endedAt"`
+	// StartIndex is the point right before crossing the first gate, EndIndex
+	// the point right after crossing the last one. Indexes count every track
+	// point of the activity, as if there was a single segment.
+	StartIndex int      `bson:"startIndex" json:"startIndex"`
+	EndIndex   int      `bson:"endIndex" json:"endIndex"`
+	Elapsed    Duration `bson:"elapsed" json:"elapsed"`
+	// Distance is how far the activity went between the gates, in meters.
+	Distance float64 `bson:"distance" json:"distance"`
+}
+
+// gate is a line square to a heading, crossed when going along the heading.
+type gate struct {
+	center planar
+	// heading is a unit vector.
+	heading   planar
+	halfWidth float64
+}
+
+// crossing tells how far along the step from a to b the gate is crossed, false when it isn't.
+func (g gate) crossing(a, b planar) (float64, bool) {
+	along := func(p planar) float64 { return (p.x-g.center.x)*g.heading.x + (p.y-g.center.y)*g.heading.y }
+	before, after := along(a), along(b)
+	if before >= 0 || after < 0 {
+		return 0, false
+	}
+	fraction := before / (before - after)
+	x, y := a.x+fraction*(b.x-a.x)-g.center.x, a.y+fraction*(b.y-a.y)-g.center.y
+	return fraction, math.Abs(-x*g.heading.y+y*g.heading.x) <= g.halfWidth
+}
+
+// reversed is the gate crossed the other way around.
+func (g gate) reversed() gate {
+	g.heading = planar{x: -g.heading.x, y: -g.heading.y}
+	return g
+}
+
+// newGate creates a gate through a point, square to the leg from one point to another.
+func newGate(through, from, to planar, width float64) gate {
+	dx, dy := to.x-from.x, to.y-from.y
+	length := math.Hypot(dx, dy)
+	return gate{center: through, heading: planar{x: dx / length, y: dy / length}, halfWidth: width / 2}
+}
+
+// compiledSegment is a segment projected onto a plane tangent to its first point.
+type compiledSegment struct {
+	Segment
+	projection projection
+	xy         []planar
+	start, end gate
+}
+
+// compile projects a segment and builds its gates, failing when it has no legs.
+func compile(s Segment) (compiledSegment, error) {
+	if len(s.Points) < 2 {
+		return compiledSegment{}, fmt.Errorf("segment %q needs at least two points", s.Name)
+	}
+	if s.GateWidth <= 0 {
+		s.GateWidth = DefaultGateWidth
+	}
+	if s.Corridor <= 0 {
+		s.Corridor = DefaultCorridor
+	}
+	points := make([]gpx.GPXPoint, len(s.Points))
+	for idx, c := range s.Points {
+		points[idx] = c.point()
+	}
+	c := compiledSegment{Segment: s, projection: newProjection(&points[0])}
+	c.xy = c.projection.project(points)
+	last := len(c.xy) - 1
+	if c.xy[0] == c.xy[1] || c.xy[last-1] == c.xy[last] {
+		return compiledSegment{}, fmt.Errorf("segment %q starts or ends with a leg that goes nowhere", s.Name)
+	}
+	c.start = newGate(c.xy[0], c.xy[0], c.xy[1], s.GateWidth)
+	c.end = newGate(c.xy[last], c.xy[last-1], c.xy[last], s.GateWidth)
+	return c, nil
+}
+
+// within tells whether a point is within the corridor of the segment.
+func (c compiledSegment) within(p planar) bool {
+	for idx := 1; idx < len(c.xy); idx++ {
+		if p.distanceTo(c.xy[idx-1], c.xy[idx]) <= c.Corridor {
+			return true
+		}
+	}
+	return false
+}
+
+// traversals finds every time a profile crosses an entry gate, stays within the
+// corridor and then crosses an exit gate. Crossing the entry gate again on the
+// way starts over from there, straying or reaching the end of a track segment
+// gives up until the entry gate is crossed again.
+func (c compiledSegment) traversals(p profile, xy []planar, direction Direction, entry, exit gate) []Traversal {
+	var found []Traversal
+	entered, at := -1, 0.0
+	for idx := 1; idx < len(p.points); idx++ {
+		if p.segmentStarts[idx] {
+			entered = -1
+			continue
+		}
+		if entered >= 0 && !c.within(xy[idx-1]) {
+			entered = -1
+		}
+		if fraction, ok := exit.crossing(xy[idx-1], xy[idx]); ok && entered >= 0 {
+			traversal := Traversal{
+				SegmentID:   c.ID,
+				SegmentName: c.Name,
+				Direction:   direction,
+				StartedAt:   p.timeAt(entered, at),
+				EndedAt:     p.timeAt(idx, fraction),
+				StartIndex:  entered - 1,
+				EndIndex:    idx,
+				Distance:    p.distanceAt(idx, fraction) - p.distanceAt(entered, at),
+			}
+			traversal.Elapsed = Duration(traversal.EndedAt.Sub(traversal.StartedAt))
+			found = append(found, traversal)
+			entered = -1
+		}
+		if fraction, ok := entry.crossing(xy[idx-1], xy[idx]); ok {
+			entered, at = idx, fraction
+		}
+	}
+	return found
+}
+
+// timeAt interpolates when the activity was a fraction of the way from the point before idx to idx.
+func (p profile) timeAt(idx int, fraction float64) time.Time {
+	before, after := p.points[idx-1].Timestamp, p.points[idx].Timestamp
+	return before.Add(time.Duration(fraction * float64(after.Sub(before))))
+}
+
+// distanceAt interpolates how far the activity went a fraction of the way from the point before idx to idx.
+func (p profile) distanceAt(idx int, fraction float64) float64 {
+	return p.distances[idx-1] + fraction*(p.distances[idx]-p.distances[idx-1])
+}
+
+// cell is a square of the grid SegmentMatcher files segments under.
+type cell struct {
+	latitude, longitude int
+}
+
+// cellOf is the cell a spot falls within.
+func cellOf(latitude, longitude float64) cell {
+	return cell{latitude: int(math.Floor(latitude / gridCell)), longitude: int(math.Floor(longitude / gridCell))}
+}
+
+// SegmentMatcher finds the segments activities traverse. Segments are filed
+// under every cell of a grid their bounding box, widened by their gates and
+// corridor, touches. Only the segments filed under the cells an activity goes
+// through are matched against it, so most segments are never looked at.
+type SegmentMatcher struct {
+	segments []compiledSegment
+	grid     map[cell][]int
+}
+
+// NewSegmentMatcher indexes segments, failing when any of them has fewer than
+// two points or a first or last leg of no length.
+func NewSegmentMatcher(segments []Segment) (*SegmentMatcher, error) {
+	m := &SegmentMatcher{grid: map[cell][]int{}}
+	for idx, s := range segments {
+		compiled, err := compile(s)
+		if err != nil {
+			return nil, err
+		}
+		m.segments = append(m.segments, compiled)
+
+		margin := math.Max(compiled.GateWidth/2, compiled.Corridor) / metersPerLatitude
+		south, north := math.Inf(1), math.Inf(-1)
+		west, east := math.Inf(1), math.Inf(-1)
+		for _, c := range s.Points {
+			south, north = math.Min(south, c.Latitude), math.Max(north, c.Latitude)
+			west, east = math.Min(west, c.Longitude), math.Max(east, c.Longitude)
+		}
+		// degrees of longitude shrink towards the poles, so the margin is widest there
+		poleward := math.Max(math.Abs(south), math.Abs(north)) * math.Pi / 180
+		lateral := margin / math.Max(math.Cos(poleward), 1e-6)
+		from, to := cellOf(south-margin, west-lateral), cellOf(north+margin, east+lateral)
+		for latitude := from.latitude; latitude <= to.latitude; latitude++ {
+			for longitude := from.longitude; longitude <= to.longitude; longitude++ {
+				key := cell{latitude: latitude, longitude: longitude}
+				m.grid[key] = append(m.grid[key], idx)
+			}
+		}
+	}
+	return m, nil
+}
+
+// candidates lists the segments filed under the cells a profile goes through, in order.
+func (m *SegmentMatcher) candidates(p profile) []int {
+	seen := map[int]bool{}
+	var found []int
+	for idx := range p.points {
+		for _, s := range m.grid[cellOf(p.points[idx].Latitude, p.points[idx].Longitude)] {
+			if !seen[s] {
+				seen[s] = true
+				found = append(found, s)
+			}
+		}
+	}
+	sort.Ints(found)
+	return found
+}
+
+// Match finds every traversal of every segment within an activity, either way,
+// sorted by when they started.
+func (m *SegmentMatcher) Match(g *gpx.GPX) ([]Traversal, error) {
+	p, err := newProfile(g)
+	if err != nil {
+		return nil, err
+	}
+	var found []Traversal
+	for _, idx := range m.candidates(p) {
+		s := m.segments[idx]
+		xy := s.projection.project(p.points)
+		found = append(found, s.traversals(p, xy, Forward, s.start, s.end)...)
+		found = append(found, s.traversals(p, xy, Reverse, s.end.reversed(), s.start.reversed())...)
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].StartedAt.Before(found[j].StartedAt) })
+	return found, nil
+}
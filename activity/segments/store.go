@@ -0,0 +1,157 @@
+// Package segments keeps the segments activities are matched against.
+package segments
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/rodolphocastro/golanghello/activity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrNotFound is returned when a segment doesn't exist.
+var ErrNotFound = errors.New("segment not found")
+
+// Store holds segments somewhere.
+type Store interface {
+	// Save stores a segment, replacing the one with the same ID. Segments
+	// without an ID are given one, the saved segment is returned.
+	Save(ctx context.Context, segment activity.Segment) (activity.Segment, error)
+	// Get fetches a segment.
+	Get(ctx context.Context, id string) (activity.Segment, error)
+	// Delete removes a segment.
+	Delete(ctx context.Context, id string) error
+	// All fetches every segment, sorted by ID.
+	All(ctx context.Context) ([]activity.Segment, error)
+}
+
+// Matcher loads every segment of a store into a matcher.
+func Matcher(ctx context.Context, store Store) (*activity.SegmentMatcher, error) {
+	all, err := store.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return activity.NewSegmentMatcher(all)
+}
+
+// MongoStore is a Store backed by a mongodb collection.
+type MongoStore struct {
+	collection *mongo.Collection
+}
+
+// NewMongoStore creates a Store on top of a mongodb collection.
+func NewMongoStore(collection *mongo.Collection) *MongoStore {
+	return &MongoStore{collection: collection}
+}
+
+// Save upserts a segment into the collection.
+func (s *MongoStore) Save(ctx context.Context, segment activity.Segment) (activity.Segment, error) {
+	if segment.ID == "" {
+		segment.ID = primitive.NewObjectID().Hex()
+	}
+	_, err := s.collection.ReplaceOne(ctx, bson.M{"_id": segment.ID}, segment, options.Replace().SetUpsert(true))
+	return segment, err
+}
+
+// Get fetches a segment from the collection.
+func (s *MongoStore) Get(ctx context.Context, id string) (activity.Segment, error) {
+	var segment activity.Segment
+	err := s.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&segment)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return segment, ErrNotFound
+	}
+	return segment, err
+}
+
+// Delete removes a segment from the collection.
+func (s *MongoStore) Delete(ctx context.Context, id string) error {
+	result, err := s.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// All fetches every segment of the collection.
+func (s *MongoStore) All(ctx context.Context) ([]activity.Segment, error) {
+	cursor, err := s.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var all []activity.Segment
+	err = cursor.All(ctx, &all)
+	return all, err
+}
+
+// MemoryStore is a Store that keeps everything in memory, handy for testing
+// things without a mongodb cluster.
+type MemoryStore struct {
+	mutex    sync.RWMutex
+	segments map[string]activity.Segment
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{segments: map[string]activity.Segment{}}
+}
+
+// Save stores a segment in memory.
+func (s *MemoryStore) Save(_ context.Context, segment activity.Segment) (activity.Segment, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if segment.ID == "" {
+		segment.ID = primitive.NewObjectID().Hex()
+	}
+	segment = copied(segment)
+	s.segments[segment.ID] = segment
+	return copied(segment), nil
+}
+
+// Get fetches a segment from memory.
+func (s *MemoryStore) Get(_ context.Context, id string) (activity.Segment, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	segment, exists := s.segments[id]
+	if !exists {
+		return activity.Segment{}, ErrNotFound
+	}
+	return copied(segment), nil
+}
+
+// Delete removes a segment from memory.
+func (s *MemoryStore) Delete(_ context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, exists := s.segments[id]; !exists {
+		return ErrNotFound
+	}
+	delete(s.segments, id)
+	return nil
+}
+
+// All fetches every segment in memory.
+func (s *MemoryStore) All(_ context.Context) ([]activity.Segment, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	all := make([]activity.Segment, 0, len(s.segments))
+	for _, segment := range s.segments {
+		all = append(all, copied(segment))
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+	return all, nil
+}
+
+// copied copies a segment along with its points, so whoever gets it can't
+// change what the store holds without going through Save.
+func copied(segment activity.Segment) activity.Segment {
+	segment.Points = append([]activity.Coordinate(nil), segment.Points...)
+	return segment
+}
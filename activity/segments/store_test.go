@@ -0,0 +1,112 @@
+package segments
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rodolphocastro/golanghello/activity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// givenASegment creates a segment going a kilometer north from the equator.
+func givenASegment() activity.Segment {
+	return activity.Segment{
+		Name:      "Northwards",
+		Points:    []activity.Coordinate{{Latitude: 0}, {Latitude: 0.005}, {Latitude: 0.009}},
+		GateWidth: 30,
+	}
+}
+
+// TestSegmentsRoundTripThroughBSON verifies segments are stored as they are.
+func TestSegmentsRoundTripThroughBSON(t *testing.T) {
+	// Arrange
+	segment := givenASegment()
+	segment.ID = "northwards"
+
+	// Act
+	data, err := bson.Marshal(segment)
+	require.Nil(t, err)
+	var got activity.Segment
+	err = bson.Unmarshal(data, &got)
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, segment, got)
+	assert.Equal(t, "northwards", bson.Raw(data).Lookup("_id").StringValue())
+}
+
+// TestMemoryStoreSavesGetsAndDeletes verifies the lifecycle of a segment.
+func TestMemoryStoreSavesGetsAndDeletes(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	// Act
+	saved, err := store.Save(ctx, givenASegment())
+	require.Nil(t, err)
+	saved.Name = "Renamed"
+	_, err = store.Save(ctx, saved)
+	require.Nil(t, err)
+	got, getErr := store.Get(ctx, saved.ID)
+	deleteErr := store.Delete(ctx, saved.ID)
+	_, missingErr := store.Get(ctx, saved.ID)
+
+	// Assert
+	assert.NotEmpty(t, saved.ID)
+	require.Nil(t, getErr)
+	assert.Equal(t, "Renamed", got.Name)
+	assert.Nil(t, deleteErr)
+	assert.ErrorIs(t, missingErr, ErrNotFound)
+	assert.ErrorIs(t, store.Delete(ctx, saved.ID), ErrNotFound)
+}
+
+// TestMemoryStoreHandsOutItsOwnPoints verifies changing the points of a segment read from the store leaves the store untouched.
+func TestMemoryStoreHandsOutItsOwnPoints(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := NewMemoryStore()
+	saved, err := store.Save(ctx, givenASegment())
+	require.Nil(t, err)
+
+	// Act
+	saved.Points[0].Latitude = 1
+	got, _ := store.Get(ctx, saved.ID)
+	got.Points[0].Latitude = 2
+	all, _ := store.All(ctx)
+	all[0].Points[0].Latitude = 3
+
+	// Assert
+	stored, err := store.Get(ctx, saved.ID)
+	require.Nil(t, err)
+	assert.Equal(t, givenASegment().Points, stored.Points)
+}
+
+// TestMatcherLoadsEverySegment verifies every segment of a store goes into the matcher, and bad ones fail it.
+func TestMatcherLoadsEverySegment(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := NewMemoryStore()
+	for _, id := range []string{"b", "a"} {
+		segment := givenASegment()
+		segment.ID = id
+		_, err := store.Save(ctx, segment)
+		require.Nil(t, err)
+	}
+	all, err := store.All(ctx)
+	require.Nil(t, err)
+
+	// Act
+	_, matcherErr := Matcher(ctx, store)
+	_, err = store.Save(ctx, activity.Segment{ID: "c", Points: []activity.Coordinate{{Latitude: 1}}})
+	require.Nil(t, err)
+	_, badErr := Matcher(ctx, store)
+
+	// Assert
+	assert.Nil(t, matcherErr)
+	assert.Error(t, badErr)
+	require.Len(t, all, 2)
+	assert.Equal(t, "a", all[0].ID)
+	assert.Equal(t, "b", all[1].ID)
+}
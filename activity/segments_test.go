@@ -0,0 +1,164 @@
+package activity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// northwards creates a segment going north along the meridian, from and to some meters north of the equator.
+func northwards(id string, from, to float64) Segment {
+	return Segment{
+		ID:     id,
+		Name:   "Northwards " + id,
+		Points: []Coordinate{{Latitude: from / metersPerDegree}, {Latitude: (from + to) / 2 / metersPerDegree}, {Latitude: to / metersPerDegree}},
+	}
+}
+
+// givenAnOutAndBack creates 2 kilometers north and back, at 4 m/s.
+func givenAnOutAndBack() []sample {
+	return then(steady(101, 5, 4), steady(101, 5, -4))
+}
+
+// TestMatchFindsBothWays verifies an out and back traverses a segment forward and then in reverse.
+func TestMatchFindsBothWays(t *testing.T) {
+	// Arrange
+	matcher, err := NewSegmentMatcher([]Segment{northwards("a", 500, 1500)})
+	require.Nil(t, err)
+
+	// Act
+	got, err := matcher.Match(givenATrack(givenAnOutAndBack()))
+
+	// Assert
+	require.Nil(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, Forward, got[0].Direction)
+	assert.Equal(t, "a", got[0].SegmentID)
+	assert.Equal(t, "Northwards a", got[0].SegmentName)
+	assert.Equal(t, startedAt.Add(125*time.Second), got[0].StartedAt)
+	assert.Equal(t, Duration(250*time.Second), got[0].Elapsed)
+	assert.InDelta(t, 1000, got[0].Distance, 1e-6)
+	assert.Equal(t, 24, got[0].StartIndex)
+	assert.Equal(t, 75, got[0].EndIndex)
+	assert.Equal(t, Reverse, got[1].Direction)
+	assert.Equal(t, startedAt.Add(625*time.Second), got[1].StartedAt)
+	assert.Equal(t, Duration(250*time.Second), got[1].Elapsed)
+}
+
+// TestMatchIgnoresDetours verifies leaving the corridor between the gates isn't a traversal.
+func TestMatchIgnoresDetours(t *testing.T) {
+	// Arrange
+	matcher, err := NewSegmentMatcher([]Segment{northwards("a", 500, 1500)})
+	require.Nil(t, err)
+	g := givenATrack(steady(101, 5, 4))
+	points := g.Tracks[0].Segments[0].Points
+	for idx := 41; idx < 60; idx++ {
+		points[idx].Longitude = 100 / metersPerDegree
+	}
+
+	// Act
+	got, err := matcher.Match(g)
+
+	// Assert
+	require.Nil(t, err)
+	assert.Empty(t, got)
+}
+
+// TestMatchNeedsBothGates verifies stopping short of the end gate, or starting past the start gate, isn't a traversal.
+func TestMatchNeedsBothGates(t *testing.T) {
+	// Arrange
+	matcher, err := NewSegmentMatcher([]Segment{northwards("short", 500, 2500), northwards("late", -500, 1000)})
+	require.Nil(t, err)
+
+	// Act
+	got, err := matcher.Match(givenATrack(steady(101, 5, 4)))
+
+	// Assert
+	require.Nil(t, err)
+	assert.Empty(t, got)
+}
+
+// TestMatchFindsEveryLap verifies each lap of a segment is a traversal of its own, even when laps share gates.
+func TestMatchFindsEveryLap(t *testing.T) {
+	// Arrange
+	matcher, err := NewSegmentMatcher([]Segment{northwards("a", 500, 1500), northwards("b", 1000, 1900)})
+	require.Nil(t, err)
+
+	// Act
+	got, err := matcher.Match(givenATrack(then(givenAnOutAndBack(), givenAnOutAndBack())))
+
+	// Assert
+	require.Nil(t, err)
+	var directions []Direction
+	for _, traversal := range got {
+		directions = append(directions, Direction(traversal.SegmentID)+"-"+traversal.Direction)
+	}
+	assert.Equal(t, []Direction{
+		"a-forward", "b-forward", "b-reverse", "a-reverse",
+		"a-forward", "b-forward", "b-reverse", "a-reverse",
+	}, directions)
+}
+
+// TestMatchSkipsFarAwaySegments verifies segments far from an activity are never looked at.
+func TestMatchSkipsFarAwaySegments(t *testing.T) {
+	// Arrange
+	segments := []Segment{northwards("near", 500, 1500)}
+	for idx := 0; idx < 300; idx++ {
+		far := northwards("far", 500, 1500)
+		for point := range far.Points {
+			far.Points[point].Longitude = 1 + float64(idx)*0.02
+		}
+		segments = append(segments, far)
+	}
+	matcher, err := NewSegmentMatcher(segments)
+	require.Nil(t, err)
+	p, err := newProfile(givenATrack(steady(101, 5, 4)))
+	require.Nil(t, err)
+
+	// Act
+	got := matcher.candidates(p)
+
+	// Assert
+	assert.Equal(t, []int{0}, got)
+}
+
+// TestMatchAgainstTheGuineaPig verifies a segment drawn from the half marathon is found within it.
+func TestMatchAgainstTheGuineaPig(t *testing.T) {
+	// Arrange
+	g := readGuineaPigFile(t)
+	points := g.Tracks[0].Segments[0].Points
+	segment := Segment{ID: "curitiba", Name: "Curitiba"}
+	for idx := 200; idx <= 400; idx += 5 {
+		segment.Points = append(segment.Points, Coordinate{Latitude: points[idx].Latitude, Longitude: points[idx].Longitude})
+	}
+	matcher, err := NewSegmentMatcher([]Segment{segment})
+	require.Nil(t, err)
+
+	// Act
+	got, err := matcher.Match(g)
+
+	// Assert
+	require.Nil(t, err)
+	require.NotEmpty(t, got)
+	assert.Equal(t, Forward, got[0].Direction)
+	assert.InDelta(t, 199, got[0].StartIndex, 1)
+	assert.InDelta(t, 400, got[0].EndIndex, 1)
+	assert.InDelta(t, points[400].Timestamp.Sub(points[200].Timestamp).Seconds(), time.Duration(got[0].Elapsed).Seconds(), 10)
+}
+
+// TestSegmentsNeedLegs verifies segments without a first or last leg are refused.
+func TestSegmentsNeedLegs(t *testing.T) {
+	scenarios := map[string][]Coordinate{
+		"a single point": {{Latitude: 1}},
+		"a still start":  {{Latitude: 1}, {Latitude: 1}, {Latitude: 2}},
+	}
+	for name, points := range scenarios {
+		// Act
+		_, err := NewSegmentMatcher([]Segment{{Name: name, Points: points}})
+
+		// Assert
+		assert.Error(t, err, name)
+	}
+}
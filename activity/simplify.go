@@ -0,0 +1,342 @@
+package activity
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// Algorithm is a way of simplifying a track.
+type Algorithm string
+
+const (
+	// DouglasPeucker keeps adding the point farthest from the simplified track.
+	DouglasPeucker Algorithm = "douglas-peucker"
+	// VisvalingamWhyatt keeps dropping the point whose triangle with its neighbors
+	// has the smallest area. Out and back stretches enclose little area, so it
+	// cuts them short sooner than DouglasPeucker does.
+	VisvalingamWhyatt Algorithm = "visvalingam-whyatt"
+)
+
+// metersPerLatitude is how many meters a degree of latitude has, on the haversine sphere.
+const metersPerLatitude = 6371000 * math.Pi / 180
+
+// Simplification tells how to simplify an activity, either Tolerance or Points must be set.
+type Simplification struct {
+	Algorithm Algorithm
+	// Tolerance is in meters. DouglasPeucker keeps every point farther than it
+	// from the simplified track, VisvalingamWhyatt drops every point whose
+	// triangle is smaller than Tolerance² square meters.
+	Tolerance float64
+	// Points is how many points to keep. The first and last point of every
+	// segment are always kept, even if there are more of them.
+	Points int
+}
+
+// SimplificationReport tells how much a simplification changed an activity.
+type SimplificationReport struct {
+	OriginalPoints int `json:"originalPoints"`
+	Points         int `json:"points"`
+	// OriginalDistance and Distance are in meters.
+	OriginalDistance float64 `json:"originalDistance"`
+	Distance         float64 `json:"distance"`
+	// DistanceError is how much shorter the simplified activity is, in meters.
+	DistanceError float64 `json:"distanceError"`
+	// MaxDeviation is how far the farthest dropped point is from the simplified track, in meters.
+	MaxDeviation float64 `json:"maxDeviation"`
+	// ElevationError is the largest difference, in meters, between the elevation
+	// of a dropped point and the one interpolated where it was dropped.
+	ElevationError float64 `json:"elevationError"`
+}
+
+// Simplify drops points from the tracks of an activity while keeping its shape.
+// Kept points are left untouched, times and extensions included. The activity
+// isn't changed, a simplified copy of it is returned.
+func Simplify(g *gpx.GPX, s Simplification) (*gpx.GPX, SimplificationReport, error) {
+	if s.Tolerance < 0 || s.Points < 0 || (s.Tolerance > 0) == (s.Points > 0) {
+		return nil, SimplificationReport{}, errors.New("simplifying needs either a tolerance or a number of points")
+	}
+	f := flatten(g)
+	if len(f.points) == 0 {
+		return nil, SimplificationReport{}, ErrNoPoints
+	}
+
+	var kept []bool
+	switch s.Algorithm {
+	case DouglasPeucker:
+		kept = f.douglasPeucker(s)
+	case VisvalingamWhyatt:
+		kept = f.visvalingamWhyatt(s)
+	default:
+		return nil, SimplificationReport{}, fmt.Errorf("unknown simplification algorithm %q", s.Algorithm)
+	}
+
+	simplified := copyTracks(g)
+	for _, segment := range f.segments {
+		var points []gpx.GPXPoint
+		for idx := segment.from; idx < segment.to; idx++ {
+			if kept[idx] {
+				points = append(points, f.points[idx])
+			}
+		}
+		simplified.Tracks[segment.track].Segments[segment.segment].Points = points
+	}
+	return simplified, f.report(kept), nil
+}
+
+// planar is a point projected onto a plane, in meters.
+type planar struct {
+	x, y float64
+}
+
+// projection projects points onto a plane tangent to an origin, which is
+// accurate enough for the few kilometers of a run.
+type projection struct {
+	origin             gpx.Point
+	metersPerLongitude float64
+}
+
+// newProjection creates a projection around a point.
+func newProjection(origin *gpx.GPXPoint) projection {
+	return projection{origin: origin.Point, metersPerLongitude: metersPerLatitude * math.Cos(origin.Latitude*math.Pi/180)}
+}
+
+// project projects points onto the plane.
+func (p projection) project(points []gpx.GPXPoint) []planar {
+	projected := make([]planar, len(points))
+	for idx := range points {
+		projected[idx] = planar{
+			x: (points[idx].Longitude - p.origin.Longitude) * p.metersPerLongitude,
+			y: (points[idx].Latitude - p.origin.Latitude) * metersPerLatitude,
+		}
+	}
+	return projected
+}
+
+// unproject moves a point to where a planar point is.
+func (p projection) unproject(xy planar, point *gpx.GPXPoint) {
+	point.Latitude = p.origin.Latitude + xy.y/metersPerLatitude
+	point.Longitude = p.origin.Longitude + xy.x/p.metersPerLongitude
+}
+
+// flatSegment is where the points of a track segment are within flattened.
+type flatSegment struct {
+	track, segment int
+	// from is the first point of the segment and to is one past its last point.
+	from, to int
+}
+
+// flattened are the points of an activity along with their projection onto a plane.
+type flattened struct {
+	points []gpx.GPXPoint
+	xy     []planar
+	// distances are how far each point is from the start of its segment.
+	distances []float64
+	segments  []flatSegment
+}
+
+// flatten flattens the segments of an activity that have points, projecting
+// each segment onto a plane tangent to its first point.
+func flatten(g *gpx.GPX) flattened {
+	var f flattened
+	for trackIdx, track := range g.Tracks {
+		for segmentIdx, segment := range track.Segments {
+			if len(segment.Points) == 0 {
+				continue
+			}
+			from := len(f.points)
+			for idx := range segment.Points {
+				travelled := 0.0
+				if idx != 0 {
+					travelled = f.distances[len(f.distances)-1] + distance(&segment.Points[idx-1], &segment.Points[idx])
+				}
+				f.points = append(f.points, segment.Points[idx])
+				f.distances = append(f.distances, travelled)
+			}
+			f.xy = append(f.xy, newProjection(&segment.Points[0]).project(segment.Points)...)
+			f.segments = append(f.segments, flatSegment{track: trackIdx, segment: segmentIdx, from: from, to: len(f.points)})
+		}
+	}
+	return f
+}
+
+// deviation is how far a point is from the line between two others, in meters.
+func (f flattened) deviation(idx, from, to int) float64 {
+	return f.xy[idx].distanceTo(f.xy[from], f.xy[to])
+}
+
+// distanceTo is how far a point is from the line between a and b.
+func (p planar) distanceTo(a, b planar) float64 {
+	dx, dy := b.x-a.x, b.y-a.y
+	fraction := 0.0
+	if length := dx*dx + dy*dy; length > 0 {
+		fraction = math.Max(0, math.Min(1, ((p.x-a.x)*dx+(p.y-a.y)*dy)/length))
+	}
+	return math.Hypot(p.x-(a.x+fraction*dx), p.y-(a.y+fraction*dy))
+}
+
+// area is the area of the triangle three points make, in square meters.
+func (f flattened) area(a, b, c int) float64 {
+	pa, pb, pc := f.xy[a], f.xy[b], f.xy[c]
+	return math.Abs((pb.x-pa.x)*(pc.y-pa.y)-(pc.x-pa.x)*(pb.y-pa.y)) / 2
+}
+
+// keepEndpoints keeps the first and last point of every segment, returning how many points that is.
+func (f flattened) keepEndpoints(kept []bool) int {
+	count := 0
+	for _, segment := range f.segments {
+		kept[segment.from], kept[segment.to-1] = true, true
+		count += 2
+		if segment.to-segment.from == 1 {
+			count--
+		}
+	}
+	return count
+}
+
+// douglasPeucker starts from the endpoints of every segment and keeps splitting
+// the span with the farthest point at that point. Spans of every segment share
+// a queue, so the points budget goes to where the activity deviates the most.
+func (f flattened) douglasPeucker(s Simplification) []bool {
+	kept := make([]bool, len(f.points))
+	count := f.keepEndpoints(kept)
+	spans := &candidates{}
+	for _, segment := range f.segments {
+		f.pushSpan(spans, segment.from, segment.to-1)
+	}
+	for spans.Len() != 0 {
+		farthest := heap.Pop(spans).(candidate)
+		if s.Points > 0 && count >= s.Points || s.Points == 0 && -farthest.key <= s.Tolerance {
+			break
+		}
+		kept[farthest.index] = true
+		count++
+		f.pushSpan(spans, farthest.from, farthest.index)
+		f.pushSpan(spans, farthest.index, farthest.to)
+	}
+	return kept
+}
+
+// pushSpan queues the farthest point between two kept points, if there is any point between them.
+func (f flattened) pushSpan(spans *candidates, from, to int) {
+	if to-from < 2 {
+		return
+	}
+	farthest, deviation := from+1, -1.0
+	for idx := from + 1; idx < to; idx++ {
+		if d := f.deviation(idx, from, to); d > deviation {
+			farthest, deviation = idx, d
+		}
+	}
+	// the queue pops the smallest key first
+	heap.Push(spans, candidate{index: farthest, from: from, to: to, key: -deviation})
+}
+
+// visvalingamWhyatt keeps dropping the point with the smallest effective area,
+// the area of the triangle it makes with its kept neighbors. Effective areas
+// never shrink as neighbors are dropped, so points are dropped in a stable order.
+func (f flattened) visvalingamWhyatt(s Simplification) []bool {
+	kept := make([]bool, len(f.points))
+	endpoints := make([]bool, len(f.points))
+	f.keepEndpoints(endpoints)
+	previous, next := make([]int, len(f.points)), make([]int, len(f.points))
+	areas := make([]float64, len(f.points))
+	points := &candidates{}
+	for _, segment := range f.segments {
+		for idx := segment.from; idx < segment.to; idx++ {
+			kept[idx], previous[idx], next[idx] = true, idx-1, idx+1
+			if !endpoints[idx] {
+				areas[idx] = f.area(idx-1, idx, idx+1)
+				heap.Push(points, candidate{index: idx, key: areas[idx]})
+			}
+		}
+	}
+
+	count := len(f.points)
+	for points.Len() != 0 {
+		smallest := heap.Pop(points).(candidate)
+		if !kept[smallest.index] || smallest.key != areas[smallest.index] {
+			continue // dropped or outdated
+		}
+		if s.Points > 0 && count <= s.Points || s.Points == 0 && smallest.key >= s.Tolerance*s.Tolerance {
+			break
+		}
+		kept[smallest.index] = false
+		count--
+		before, after := previous[smallest.index], next[smallest.index]
+		next[before], previous[after] = after, before
+		for _, neighbor := range []int{before, after} {
+			if endpoints[neighbor] {
+				continue
+			}
+			areas[neighbor] = math.Max(smallest.key, f.area(previous[neighbor], neighbor, next[neighbor]))
+			heap.Push(points, candidate{index: neighbor, key: areas[neighbor]})
+		}
+	}
+	return kept
+}
+
+// report compares the kept points with all of them.
+func (f flattened) report(kept []bool) SimplificationReport {
+	report := SimplificationReport{OriginalPoints: len(f.points)}
+	for _, segment := range f.segments {
+		report.OriginalDistance += f.distances[segment.to-1]
+		report.Points++
+		last := segment.from
+		for idx := segment.from + 1; idx < segment.to; idx++ {
+			if !kept[idx] {
+				continue
+			}
+			report.Points++
+			report.Distance += distance(&f.points[last], &f.points[idx])
+			for dropped := last + 1; dropped < idx; dropped++ {
+				report.MaxDeviation = math.Max(report.MaxDeviation, f.deviation(dropped, last, idx))
+				if e, ok := f.elevationError(dropped, last, idx); ok {
+					report.ElevationError = math.Max(report.ElevationError, e)
+				}
+			}
+			last = idx
+		}
+	}
+	report.DistanceError = report.OriginalDistance - report.Distance
+	return report
+}
+
+// elevationError is how far the elevation of a dropped point is from the one
+// interpolated between the kept points around it, false without elevations.
+func (f flattened) elevationError(dropped, from, to int) (float64, bool) {
+	elevation, ok := elevationOf(&f.points[dropped])
+	elevationFrom, okFrom := elevationOf(&f.points[from])
+	elevationTo, okTo := elevationOf(&f.points[to])
+	if !ok || !okFrom || !okTo {
+		return 0, false
+	}
+	fraction := 0.0
+	if span := f.distances[to] - f.distances[from]; span > 0 {
+		fraction = (f.distances[dropped] - f.distances[from]) / span
+	}
+	return math.Abs(elevation - (elevationFrom + fraction*(elevationTo-elevationFrom))), true
+}
+
+// candidate is a point that may be kept or dropped, within the span between from and to.
+type candidate struct {
+	index, from, to int
+	key             float64
+}
+
+// candidates is a queue of candidates, smallest key first.
+type candidates []candidate
+
+func (c candidates) Len() int            { return len(c) }
+func (c candidates) Less(i, j int) bool  { return c[i].key < c[j].key }
+func (c candidates) Swap(i, j int)       { c[i], c[j] = c[j], c[i] }
+func (c *candidates) Push(x interface{}) { *c = append(*c, x.(candidate)) }
+func (c *candidates) Pop() interface{} {
+	old := *c
+	last := old[len(old)-1]
+	*c = old[:len(old)-1]
+	return last
+}
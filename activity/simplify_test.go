@@ -0,0 +1,163 @@
+package activity
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// givenAPath creates an activity through points east and north of the equator and the meridian, in meters.
+func givenAPath(points ...planar) *gpx.GPX {
+	var segment gpx.GPXTrackSegment
+	for idx, p := range points {
+		point := gpx.GPXPoint{
+			Point:     gpx.Point{Latitude: p.y / metersPerDegree, Longitude: p.x / metersPerDegree},
+			Timestamp: startedAt.Add(time.Duration(idx) * 10 * time.Second),
+		}
+		point.Elevation.SetValue(float64(idx % 2))
+		segment.AppendPoint(&point)
+	}
+	track := gpx.GPXTrack{Name: "Synthetic Path"}
+	track.AppendSegment(&segment)
+	g := &gpx.GPX{Version: "1.1", Creator: "golanghello"}
+	g.AppendTrack(&track)
+	return g
+}
+
+// givenABump creates a straight 200m path with a 3m bump in the middle.
+func givenABump() *gpx.GPX {
+	return givenAPath(planar{0, 0}, planar{50, 0}, planar{100, 3}, planar{150, 0}, planar{200, 0})
+}
+
+// TestSimplifyByTolerance verifies both algorithms keep the bump only when it's beyond the tolerance.
+func TestSimplifyByTolerance(t *testing.T) {
+	scenarios := []struct {
+		algorithm Algorithm
+		tolerance float64
+		expected  int
+	}{
+		{DouglasPeucker, 5, 2},
+		{DouglasPeucker, 2, 3},
+		{VisvalingamWhyatt, 20, 2}, // the bump's triangle grows to 300m² as its neighbors go
+		{VisvalingamWhyatt, 10, 3}, // its neighbors' triangles are 75m²
+	}
+	for _, scenario := range scenarios {
+		// Arrange
+		g := givenABump()
+
+		// Act
+		got, report, err := Simplify(g, Simplification{Algorithm: scenario.algorithm, Tolerance: scenario.tolerance})
+
+		// Assert
+		require.Nil(t, err)
+		assert.Equal(t, scenario.expected, got.GetTrackPointsNo(), "%v at %vm", scenario.algorithm, scenario.tolerance)
+		assert.Equal(t, scenario.expected, report.Points)
+		assert.Equal(t, 5, report.OriginalPoints)
+		assert.Equal(t, 5, g.GetTrackPointsNo(), "the original is left untouched")
+		if scenario.expected == 2 {
+			assert.InDelta(t, 3, report.MaxDeviation, 0.01)
+			assert.InDelta(t, 1, report.ElevationError, 0.01)
+			assert.InDelta(t, 0.18, report.DistanceError, 0.01, "going over the bump is 18cm longer")
+		}
+	}
+}
+
+// TestSimplifyKeepsTheOriginalPoints verifies kept points keep their times and extensions.
+func TestSimplifyKeepsTheOriginalPoints(t *testing.T) {
+	// Arrange
+	g := givenATrack(steady(11, 5, 4), then(steady(3, 5, 4), []sample{{}, {seconds: 5, meters: 20, heartRate: 150}}))
+
+	// Act
+	got, report, err := Simplify(g, Simplification{Algorithm: DouglasPeucker, Tolerance: 1})
+
+	// Assert
+	require.Nil(t, err)
+	require.Len(t, got.Tracks[0].Segments, 2)
+	assert.Len(t, got.Tracks[0].Segments[0].Points, 2, "a straight line needs its ends only")
+	assert.Len(t, got.Tracks[0].Segments[1].Points, 2)
+	assert.Equal(t, startedAt.Add(50*time.Second), got.Tracks[0].Segments[0].Points[1].Timestamp)
+	assert.Equal(t, 150, *DecodeSample(&got.Tracks[0].Segments[1].Points[1]).HeartRate)
+	assert.InDelta(t, 0, report.DistanceError, 0.001)
+	assert.InDelta(t, 0, report.MaxDeviation, 0.001)
+}
+
+// TestSimplifyTheGuineaPigToAPointCount verifies both algorithms hit a target point count and get closer with more points.
+func TestSimplifyTheGuineaPigToAPointCount(t *testing.T) {
+	for _, algorithm := range []Algorithm{DouglasPeucker, VisvalingamWhyatt} {
+		// Arrange
+		g := readGuineaPigFile(t)
+		var deviations []float64
+
+		for _, points := range []int{50, 200, 800} {
+			// Act
+			got, report, err := Simplify(g, Simplification{Algorithm: algorithm, Points: points})
+
+			// Assert
+			require.Nil(t, err)
+			assert.Equal(t, points, got.GetTrackPointsNo(), algorithm)
+			assert.Equal(t, points, report.Points)
+			assert.Greater(t, report.DistanceError, 0.0)
+			assert.Less(t, report.DistanceError, report.OriginalDistance*0.15, "%v with %v points", algorithm, points)
+			t.Logf("%v with %v points: %+v", algorithm, points, report)
+			deviations = append(deviations, report.MaxDeviation)
+		}
+		assert.IsDecreasing(t, deviations, algorithm)
+	}
+}
+
+// TestSimplifyTheGuineaPigWithinATolerance verifies Douglas-Peucker never drops a point beyond the tolerance.
+func TestSimplifyTheGuineaPigWithinATolerance(t *testing.T) {
+	// Arrange
+	g := readGuineaPigFile(t)
+
+	// Act
+	got, report, err := Simplify(g, Simplification{Algorithm: DouglasPeucker, Tolerance: 5})
+
+	// Assert
+	require.Nil(t, err)
+	assert.LessOrEqual(t, report.MaxDeviation, 5.0)
+	assert.Less(t, got.GetTrackPointsNo(), g.GetTrackPointsNo()/4)
+	t.Logf("%+v", report)
+}
+
+// TestSimplifiedTracksAreValidGPX verifies simplified tracks can be written and parsed back.
+func TestSimplifiedTracksAreValidGPX(t *testing.T) {
+	// Arrange
+	g := readGuineaPigFile(t)
+	simplified, _, err := Simplify(g, Simplification{Algorithm: VisvalingamWhyatt, Points: 300})
+	require.Nil(t, err)
+	var written bytes.Buffer
+
+	// Act
+	err = WriteGPX(&written, simplified)
+
+	// Assert
+	require.Nil(t, err)
+	parsed, err := gpx.ParseBytes(written.Bytes())
+	require.Nil(t, err)
+	assert.Equal(t, "1.1", parsed.Version)
+	assert.Equal(t, 300, parsed.GetTrackPointsNo())
+	assert.Equal(t, g.Tracks[0].Name, parsed.Tracks[0].Name)
+	assert.Equal(t, Samples(simplified), Samples(parsed), "extensions survive being written")
+}
+
+// TestSimplifyNeedsALimit verifies simplifications need either a tolerance or a point count, and a known algorithm.
+func TestSimplifyNeedsALimit(t *testing.T) {
+	scenarios := []Simplification{
+		{Algorithm: DouglasPeucker},
+		{Algorithm: DouglasPeucker, Tolerance: 5, Points: 10},
+		{Algorithm: VisvalingamWhyatt, Tolerance: -1},
+		{Algorithm: "ramer", Tolerance: 5},
+	}
+	for _, scenario := range scenarios {
+		// Act
+		_, _, err := Simplify(givenABump(), scenario)
+
+		// Assert
+		assert.Error(t, err, "%+v", scenario)
+	}
+}
@@ -0,0 +1,97 @@
+package activity
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+const (
+	// Kilometer is the length of per kilometer splits, in meters.
+	Kilometer = 1000.0
+	// Mile is the length of per mile splits, in meters.
+	Mile = 1609.344
+	// minSplitRemainder is the shortest last split, shorter remainders are GPS noise.
+	minSplitRemainder = 1.0
+)
+
+// Split is a lap of an activity, split by distance.
+type Split struct {
+	// Number starts at 1.
+	Number    int       `json:"number"`
+	StartedAt time.Time `json:"startedAt"`
+	// Distance is in meters, only the last split may be shorter than the others.
+	Distance float64  `json:"distance"`
+	Elapsed  Duration `json:"elapsed"`
+	// Pace is the time per kilometer, even for mile splits.
+	Pace Duration `json:"pace"`
+	// GradeAdjustedPace is the time per kilometer of flat ground taking as much
+	// energy as the split did, zero without elevations.
+	GradeAdjustedPace Duration `json:"gradeAdjustedPace,omitempty"`
+	// ElevationDelta is how much higher the split ended than it started, in meters.
+	ElevationDelta float64 `json:"elevationDelta"`
+	// AverageHeartRate is the mean of the heart rate readings within the split, zero without readings.
+	AverageHeartRate float64 `json:"averageHeartRate,omitempty"`
+}
+
+// Splits splits an activity into laps of a length in meters, such as Kilometer
+// or Mile. Laps start and end exactly at their distance, times and elevations
+// in between two points are interpolated. Grade adjusted paces use the
+// Smoothing of the Options.
+func Splits(g *gpx.GPX, length float64, opts ...Options) ([]Split, error) {
+	options := withDefaults(opts)
+	if length <= 0 {
+		return nil, fmt.Errorf("splits must be longer than %v meters", length)
+	}
+	smoothing, err := options.Smoothing.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	p, err := newProfile(g)
+	if err != nil {
+		return nil, err
+	}
+
+	var splits []Split
+	equivalent := p.equivalentDistances(smoothing)
+	start := p.positionAt(0)
+	for covered := 0.0; p.totalDistance()-covered >= minSplitRemainder; covered += length {
+		splitLength := length
+		if remainder := p.totalDistance() - covered; remainder < length {
+			splitLength = remainder
+		}
+		end := p.positionAt(covered + splitLength)
+		elapsed := end.time.Sub(start.time).Round(time.Millisecond)
+		within := end.index
+		if p.totalDistance()-(covered+splitLength) < minSplitRemainder {
+			within = len(p.points) // the last split keeps the last point
+		}
+		split := Split{
+			Number:           len(splits) + 1,
+			StartedAt:        start.time,
+			Distance:         splitLength,
+			Elapsed:          Duration(elapsed),
+			Pace:             paceOf(elapsed, splitLength),
+			AverageHeartRate: averageHeartRate(p.points[start.index:within]),
+		}
+		if equivalent != nil {
+			split.GradeAdjustedPace = paceOf(elapsed, p.equivalentAt(equivalent, covered+splitLength)-p.equivalentAt(equivalent, covered))
+		}
+		if start.hasElevation && end.hasElevation {
+			split.ElevationDelta = end.elevation - start.elevation
+		}
+		splits = append(splits, split)
+		start = end
+	}
+	return splits, nil
+}
+
+// averageHeartRate is the mean heart rate of some points, zero when they have no readings.
+func averageHeartRate(points []gpx.GPXPoint) float64 {
+	samples := make([]Sample, len(points))
+	for idx := range points {
+		samples[idx] = DecodeSample(&points[idx])
+	}
+	return SummarizeSamples(samples).AverageHeartRate
+}
@@ -0,0 +1,104 @@
+package activity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSplitsFollowTheEffort verifies a 2.5km run, a kilometer climbing at 4:10, then descending at 3:20.
+func TestSplitsFollowTheEffort(t *testing.T) {
+	// Arrange
+	climb := steady(51, 5, 4)
+	for idx := range climb {
+		climb[idx].elevation = 900 + float64(idx)*0.2
+		climb[idx].heartRate = 150
+	}
+	descent := steady(76, 4, 5)
+	for idx := range descent {
+		descent[idx].elevation = 910 - float64(idx)*0.2
+		descent[idx].heartRate = 170
+	}
+
+	// Act
+	got, err := Splits(givenATrack(then(climb, descent)), Kilometer)
+
+	// Assert
+	require.Nil(t, err)
+	require.Len(t, got, 3)
+	expected := []Split{
+		{Number: 1, StartedAt: startedAt, Distance: 1000, Elapsed: Duration(250 * time.Second), Pace: Duration(250 * time.Second), ElevationDelta: 10, AverageHeartRate: 150},
+		{Number: 2, StartedAt: startedAt.Add(250 * time.Second), Distance: 1000, Elapsed: Duration(200 * time.Second), Pace: Duration(200 * time.Second), ElevationDelta: -10, AverageHeartRate: 170},
+		{Number: 3, StartedAt: startedAt.Add(450 * time.Second), Distance: 500, Elapsed: Duration(100 * time.Second), Pace: Duration(200 * time.Second), ElevationDelta: -5, AverageHeartRate: 170},
+	}
+	for idx := range expected {
+		assert.Equal(t, expected[idx].Number, got[idx].Number)
+		assert.WithinDuration(t, expected[idx].StartedAt, got[idx].StartedAt, time.Millisecond)
+		assert.InDelta(t, expected[idx].Distance, got[idx].Distance, 0.001)
+		assert.InDelta(t, float64(expected[idx].Elapsed), float64(got[idx].Elapsed), float64(time.Millisecond))
+		assert.InDelta(t, float64(expected[idx].Pace), float64(got[idx].Pace), float64(time.Millisecond))
+		assert.InDelta(t, expected[idx].ElevationDelta, got[idx].ElevationDelta, 0.001)
+		assert.InDelta(t, expected[idx].AverageHeartRate, got[idx].AverageHeartRate, 0.001)
+	}
+}
+
+// TestSplitsInterpolateBetweenPoints verifies splits end exactly at their distance, even between two points.
+func TestSplitsInterpolateBetweenPoints(t *testing.T) {
+	// Arrange
+	sparse := steady(4, 300, 3) // a point every 900 meters
+
+	// Act
+	got, err := Splits(givenATrack(sparse), Kilometer)
+
+	// Assert
+	require.Nil(t, err)
+	require.Len(t, got, 3)
+	assert.InDelta(t, float64(1000/3.0*float64(time.Second)), float64(got[0].Elapsed), float64(time.Millisecond))
+	assert.InDelta(t, 700, got[2].Distance, 0.001)
+	assert.Zero(t, got[0].AverageHeartRate, "there are no readings")
+}
+
+// TestSplitsCanBeMilesOrCustom verifies other split lengths.
+func TestSplitsCanBeMilesOrCustom(t *testing.T) {
+	// Arrange
+	run := givenATrack(steady(1001, 5, 4)) // 20km
+
+	// Act
+	miles, milesErr := Splits(run, Mile)
+	laps, lapsErr := Splits(run, 400)
+	_, badErr := Splits(run, 0)
+
+	// Assert
+	require.Nil(t, milesErr)
+	require.Nil(t, lapsErr)
+	assert.Len(t, miles, 13)
+	assert.InDelta(t, float64(Mile/4*float64(time.Second)), float64(miles[0].Elapsed), float64(time.Millisecond))
+	assert.InDelta(t, 20000-12*Mile, miles[12].Distance, 0.01)
+	assert.Len(t, laps, 50)
+	assert.Error(t, badErr)
+}
+
+// TestSplitTheGuineaPigPerKilometer verifies the splits of the half marathon add up to the whole run.
+func TestSplitTheGuineaPigPerKilometer(t *testing.T) {
+	// Arrange
+	g := readGuineaPigFile(t)
+	summary, _ := Summarize(g)
+
+	// Act
+	got, err := Splits(g, Kilometer)
+
+	// Assert
+	require.Nil(t, err)
+	assert.Len(t, got, 22)
+	var elapsed Duration
+	distance := 0.0
+	for _, split := range got {
+		elapsed += split.Elapsed
+		distance += split.Distance
+		assert.Greater(t, split.AverageHeartRate, 0.0)
+	}
+	assert.InDelta(t, float64(summary.TotalTime), float64(elapsed), float64(len(got))*float64(time.Millisecond))
+	assert.InDelta(t, summary.Distance, distance, 0.001)
+}
@@ -0,0 +1,142 @@
+package activity
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// StreamedPoint is a track point read by a PointReader.
+type StreamedPoint struct {
+	// Track and Segment are where the point is within the document, counting
+	// from zero. Segment restarts from zero on every track.
+	Track   int
+	Segment int
+	gpx.GPXPoint
+}
+
+// streamedTrackPoint is how a track point is written in a GPX document.
+type streamedTrackPoint struct {
+	Latitude   float64       `xml:"lat,attr"`
+	Longitude  float64       `xml:"lon,attr"`
+	Elevation  *float64      `xml:"ele"`
+	Time       string        `xml:"time"`
+	Extensions gpx.Extension `xml:"extensions"`
+}
+
+// PointReader reads the track points of a GPX document one at a time, going
+// through the XML rather than loading the whole document like gpx.ParseFile
+// does. Only the latitude, longitude, elevation, time and extensions of the
+// points are read, which is all the analyses need. Routes and waypoints are
+// skipped.
+//
+//	reader := activity.NewPointReader(file)
+//	for reader.Next() {
+//		point := reader.Point()
+//		...
+//	}
+//	if err := reader.Err(); err != nil {
+//		...
+//	}
+type PointReader struct {
+	decoder *xml.Decoder
+	track   int
+	segment int
+	point   StreamedPoint
+	err     error
+}
+
+// NewPointReader creates a PointReader reading a GPX document.
+func NewPointReader(r io.Reader) *PointReader {
+	return &PointReader{decoder: xml.NewDecoder(r), track: -1, segment: -1}
+}
+
+// Next reads the next track point, false at the end of the document or on error.
+func (r *PointReader) Next() bool {
+	if r.err != nil {
+		return false
+	}
+	for {
+		token, err := r.decoder.Token()
+		if err == io.EOF {
+			return false
+		}
+		if err != nil {
+			r.err = err
+			return false
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "trk":
+			r.track++
+			r.segment = -1
+		case "trkseg":
+			r.segment++
+		case "trkpt":
+			if r.track < 0 || r.segment < 0 {
+				r.err = fmt.Errorf("track point at offset %v is outside a track segment", r.decoder.InputOffset())
+				return false
+			}
+			r.point, r.err = r.decode(start)
+			return r.err == nil
+		}
+	}
+}
+
+// decode reads a track point from its start element.
+func (r *PointReader) decode(start xml.StartElement) (StreamedPoint, error) {
+	var raw streamedTrackPoint
+	if err := r.decoder.DecodeElement(&raw, &start); err != nil {
+		return StreamedPoint{}, err
+	}
+	point := StreamedPoint{Track: r.track, Segment: r.segment}
+	point.Latitude, point.Longitude = raw.Latitude, raw.Longitude
+	if raw.Elevation != nil {
+		point.Elevation.SetValue(*raw.Elevation)
+	}
+	if text := strings.TrimSpace(raw.Time); text != "" {
+		timestamp, err := time.Parse(time.RFC3339Nano, text)
+		if err != nil {
+			return StreamedPoint{}, fmt.Errorf("track point at offset %v: %w", r.decoder.InputOffset(), err)
+		}
+		point.Timestamp = timestamp
+	}
+	point.Extensions.Nodes = raw.Extensions.Nodes
+	return point, nil
+}
+
+// Point is the track point read by the last call to Next.
+func (r *PointReader) Point() StreamedPoint {
+	return r.point
+}
+
+// Err is the error that stopped Next, nil at the end of the document.
+func (r *PointReader) Err() error {
+	return r.err
+}
+
+// SummarizeStream analyzes the track points of a PointReader as they're read,
+// keeping nothing but totals and the points of the last BestPaceDistance
+// around. The summary is the one Summarize gives, except for the
+// GradeAdjustedPace which is left zero: smoothing elevations needs the points
+// ahead of each one.
+func SummarizeStream(r *PointReader, opts ...Options) (Summary, error) {
+	s := newSummarizer(withDefaults(opts))
+	track, segment := -1, -1
+	for r.Next() {
+		point := r.Point()
+		s.add(&point.GPXPoint, point.Track != track || point.Segment != segment)
+		track, segment = point.Track, point.Segment
+	}
+	if err := r.Err(); err != nil {
+		return Summary{}, err
+	}
+	return s.summary()
+}
@@ -0,0 +1,188 @@
+package activity
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// openGuineaPigFile opens the 14-test-subject.gpx file, closing it once the test is over.
+func openGuineaPigFile(t testing.TB) *os.File {
+	file, err := os.Open(pathToGuineaPig)
+	require.Nil(t, err)
+	t.Cleanup(func() { file.Close() })
+	return file
+}
+
+// givenAnEndlessRun writes a run of many points, a second and 3 meters apart,
+// as it's read, so the whole document is never in memory.
+func givenAnEndlessRun(points int) io.Reader {
+	reader, writer := io.Pipe()
+	go func() {
+		fmt.Fprint(writer, `<?xml version="1.0"?><gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1"><trk><trkseg>`)
+		for idx := 0; idx < points; idx++ {
+			fmt.Fprintf(writer, `<trkpt lat="%v" lon="0"><ele>900</ele><time>%v</time></trkpt>`,
+				float64(idx)*3/metersPerDegree, startedAt.Add(time.Duration(idx)*time.Second).Format(time.RFC3339))
+		}
+		fmt.Fprint(writer, `</trkseg></trk></gpx>`)
+		writer.Close()
+	}()
+	return reader
+}
+
+// TestPointReaderReadsWhatParseFileDoes verifies every point of the guinea pig, extensions included, comes out as gpx.ParseFile reads it.
+func TestPointReaderReadsWhatParseFileDoes(t *testing.T) {
+	// Arrange
+	expected := segments(readGuineaPigFile(t))[0]
+	reader := NewPointReader(openGuineaPigFile(t))
+
+	// Act
+	var got []StreamedPoint
+	for reader.Next() {
+		got = append(got, reader.Point())
+	}
+
+	// Assert
+	require.Nil(t, reader.Err())
+	require.Len(t, got, 1657)
+	for idx, point := range got {
+		assert.Equal(t, 0, point.Track)
+		assert.Equal(t, 0, point.Segment)
+		assert.Equal(t, expected[idx].Point, point.Point)
+		assert.True(t, expected[idx].Timestamp.Equal(point.Timestamp))
+		assert.Equal(t, DecodeSample(&expected[idx]).HeartRate, DecodeSample(&point.GPXPoint).HeartRate)
+		assert.Equal(t, DecodeSample(&expected[idx]).Cadence, DecodeSample(&point.GPXPoint).Cadence)
+	}
+}
+
+// TestPointReaderCountsTracksAndSegments verifies points tell which track and segment they're from, skipping routes and waypoints.
+func TestPointReaderCountsTracksAndSegments(t *testing.T) {
+	// Arrange
+	document := `<?xml version="1.0"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+	<wpt lat="9" lon="9"><name>Skipped</name></wpt>
+	<rte><rtept lat="9" lon="9"/></rte>
+	<trk>
+		<trkseg><trkpt lat="1" lon="1"/><trkpt lat="1" lon="2"><ele>905.5</ele></trkpt></trkseg>
+		<trkseg><trkpt lat="2" lon="1"><time>2022-06-12T09:04:10.250Z</time></trkpt></trkseg>
+	</trk>
+	<trk><trkseg><trkpt lat="3" lon="1"/></trkseg></trk>
+</gpx>`
+	reader := NewPointReader(strings.NewReader(document))
+
+	// Act
+	var got [][2]int
+	var points []gpx.GPXPoint
+	for reader.Next() {
+		got = append(got, [2]int{reader.Point().Track, reader.Point().Segment})
+		points = append(points, reader.Point().GPXPoint)
+	}
+
+	// Assert
+	require.Nil(t, reader.Err())
+	assert.Equal(t, [][2]int{{0, 0}, {0, 0}, {0, 1}, {1, 0}}, got)
+	assert.True(t, points[0].Elevation.Null())
+	assert.Equal(t, 905.5, points[1].Elevation.Value())
+	assert.Equal(t, startedAt.Add(250*time.Millisecond), points[2].Timestamp)
+	assert.True(t, points[3].Timestamp.IsZero())
+}
+
+// TestPointReaderStopsAtBadDocuments verifies malformed documents are errors rather than silently cut short.
+func TestPointReaderStopsAtBadDocuments(t *testing.T) {
+	scenarios := map[string]string{
+		"a bad time":    `<gpx><trk><trkseg><trkpt lat="1" lon="1"><time>yesterday</time></trkpt></trkseg></trk></gpx>`,
+		"a bad number":  `<gpx><trk><trkseg><trkpt lat="north" lon="1"/></trkseg></trk></gpx>`,
+		"a cut short":   `<gpx><trk><trkseg><trkpt lat="1" lon="1"><ele>9`,
+		"a stray point": `<gpx><trkpt lat="1" lon="1"/></gpx>`,
+	}
+	for name, document := range scenarios {
+		// Arrange
+		reader := NewPointReader(strings.NewReader(document))
+
+		// Act
+		for reader.Next() {
+		}
+
+		// Assert
+		assert.Error(t, reader.Err(), name)
+		assert.False(t, reader.Next(), name)
+	}
+}
+
+// TestSummarizeStreamMatchesSummarize verifies streaming the guinea pig sums it up like parsing it does, but for its grade adjusted pace.
+func TestSummarizeStreamMatchesSummarize(t *testing.T) {
+	// Arrange
+	expected, err := Summarize(readGuineaPigFile(t))
+	require.Nil(t, err)
+	expected.GradeAdjustedPace = 0
+
+	// Act
+	got, err := SummarizeStream(NewPointReader(openGuineaPigFile(t)))
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, expected, got)
+}
+
+// TestSummarizeStreamKeepsLittleAround verifies a long stream only keeps the points of the best pace stretch.
+func TestSummarizeStreamKeepsLittleAround(t *testing.T) {
+	// Arrange
+	s := newSummarizer(withDefaults(nil))
+	reader := NewPointReader(givenAnEndlessRun(100000))
+
+	// Act
+	track, segment := -1, -1
+	for reader.Next() {
+		point := reader.Point()
+		s.add(&point.GPXPoint, point.Track != track || point.Segment != segment)
+		track, segment = point.Track, point.Segment
+	}
+	got, err := s.summary()
+
+	// Assert
+	require.Nil(t, reader.Err())
+	require.Nil(t, err)
+	assert.Equal(t, 100000, got.Points)
+	assert.InDelta(t, 299997, got.Distance, 1)
+	assert.InDelta(t, 333.333, time.Duration(got.BestPace).Seconds(), 0.001)
+	assert.LessOrEqual(t, cap(s.best.window), 2*(1000/3+2), "a kilometer of points, give or take growing the slice")
+}
+
+// TestSummarizeStreamWithoutPoints verifies an empty document has nothing to sum up.
+func TestSummarizeStreamWithoutPoints(t *testing.T) {
+	_, err := SummarizeStream(NewPointReader(strings.NewReader(`<gpx><trk/></gpx>`)))
+	assert.ErrorIs(t, err, ErrNoPoints)
+}
+
+// BenchmarkPointReader reads every point of the guinea pig with a PointReader.
+func BenchmarkPointReader(b *testing.B) {
+	content, err := os.ReadFile(pathToGuineaPig)
+	require.Nil(b, err)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reader := NewPointReader(strings.NewReader(string(content)))
+		for reader.Next() {
+		}
+		require.Nil(b, reader.Err())
+	}
+}
+
+// BenchmarkParseBytes parses the guinea pig with gpx.ParseBytes, to compare against BenchmarkPointReader.
+func BenchmarkParseBytes(b *testing.B) {
+	content, err := os.ReadFile(pathToGuineaPig)
+	require.Nil(b, err)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := gpx.ParseBytes(content)
+		require.Nil(b, err)
+	}
+}
@@ -0,0 +1,225 @@
+package activity
+
+import (
+	"math"
+	"time"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+const (
+	// DefaultMovingSpeed is the slowest speed, in meters per second, that still counts as moving.
+	DefaultMovingSpeed = 0.5
+	// DefaultBestPaceDistance is the stretch, in meters, the best pace is measured over.
+	DefaultBestPaceDistance = 1000.0
+	// DefaultMinPeriod is the shortest pause, or the shortest run or walk, worth telling apart.
+	DefaultMinPeriod = 10 * time.Second
+	// DefaultRunningSpeed is the slowest speed, in meters per second, that counts as running.
+	DefaultRunningSpeed = 2.0
+	// DefaultRunningCadence is the slowest cadence, in steps per minute of a single foot, that counts as running.
+	DefaultRunningCadence = 70
+	// DefaultCorridor is how far apart, in meters, two runs of the same route may be.
+	DefaultCorridor = 25.0
+	// DefaultGhostStep is how often, in meters, a run is compared against a previous one.
+	DefaultGhostStep = 100.0
+)
+
+// Options tunes how an activity is analyzed.
+type Options struct {
+	// MovingSpeed is the slowest speed that counts as moving, defaults to DefaultMovingSpeed.
+	MovingSpeed float64
+	// BestPaceDistance is the stretch the best pace is measured over, defaults to DefaultBestPaceDistance.
+	BestPaceDistance float64
+	// MinPeriod is the shortest period Periods and Labels tell apart, defaults to DefaultMinPeriod.
+	MinPeriod time.Duration
+	// RunningSpeed is the slowest speed that counts as running when there's no cadence, defaults to DefaultRunningSpeed.
+	RunningSpeed float64
+	// RunningCadence is the slowest cadence that counts as running, defaults to DefaultRunningCadence.
+	RunningCadence int
+	// Smoothing is how elevations are smoothed before computing gradients.
+	Smoothing Smoothing
+	// Corridor is how far from a route an activity may stray and still follow it, defaults to DefaultCorridor.
+	Corridor float64
+	// GhostStep is how often Compare times an activity against another, defaults to DefaultGhostStep.
+	GhostStep float64
+}
+
+// withDefaults fills in whatever wasn't set in the first of many Options.
+func withDefaults(opts []Options) Options {
+	var o Options
+	if len(opts) != 0 {
+		o = opts[0]
+	}
+	if o.MovingSpeed <= 0 {
+		o.MovingSpeed = DefaultMovingSpeed
+	}
+	if o.BestPaceDistance <= 0 {
+		o.BestPaceDistance = DefaultBestPaceDistance
+	}
+	if o.MinPeriod <= 0 {
+		o.MinPeriod = DefaultMinPeriod
+	}
+	if o.RunningSpeed <= 0 {
+		o.RunningSpeed = DefaultRunningSpeed
+	}
+	if o.RunningCadence <= 0 {
+		o.RunningCadence = DefaultRunningCadence
+	}
+	if o.Corridor <= 0 {
+		o.Corridor = DefaultCorridor
+	}
+	if o.GhostStep <= 0 {
+		o.GhostStep = DefaultGhostStep
+	}
+	return o
+}
+
+// Summary is the outcome of analyzing an activity.
+type Summary struct {
+	StartedAt time.Time `json:"startedAt"`
+	EndedAt   time.Time `json:"endedAt"`
+	// TotalTime is the time between the first and the last point.
+	TotalTime Duration `json:"totalTime"`
+	// MovingTime is the time spent at MovingSpeed or faster.
+	MovingTime Duration `json:"movingTime"`
+	// Distance is in meters.
+	Distance float64 `json:"distance"`
+	// AveragePace is the moving time per kilometer.
+	AveragePace Duration `json:"averagePace"`
+	// BestPace is the time per kilometer of the fastest stretch covering
+	// BestPaceDistance, zero when the activity is shorter than that.
+	BestPace Duration `json:"bestPace,omitempty"`
+	// GradeAdjustedPace is the moving time per kilometer of flat ground taking
+	// as much energy as the activity did, zero without elevations.
+	GradeAdjustedPace Duration `json:"gradeAdjustedPace,omitempty"`
+	// Elevation is nil when no point has an elevation.
+	Elevation *ElevationSummary `json:"elevation,omitempty"`
+	// Sensors is nil when no point has sensor readings.
+	Sensors *SensorSummary `json:"sensors,omitempty"`
+	Points  int            `json:"points"`
+}
+
+// ElevationSummary sums up the elevations of an activity, in meters.
+type ElevationSummary struct {
+	// Gain sums every climb between two points.
+	Gain float64 `json:"gain"`
+	// Loss sums every descent between two points, as a positive number.
+	Loss    float64 `json:"loss"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+	Average float64 `json:"average"`
+}
+
+// Summarize analyzes an activity, such as the ones parsed by gpx.ParseFile.
+func Summarize(g *gpx.GPX, opts ...Options) (Summary, error) {
+	options := withDefaults(opts)
+	smoothing, err := options.Smoothing.withDefaults()
+	if err != nil {
+		return Summary{}, err
+	}
+	p, err := newProfile(g)
+	if err != nil {
+		return Summary{}, err
+	}
+	s := newSummarizer(options)
+	for idx := range p.points {
+		s.add(&p.points[idx], p.segmentStarts[idx])
+	}
+	summary, err := s.summary()
+	if err != nil {
+		return summary, err
+	}
+	if equivalent := p.equivalentDistances(smoothing); equivalent != nil {
+		summary.GradeAdjustedPace = paceOf(time.Duration(summary.MovingTime), equivalent[len(equivalent)-1])
+	}
+	return summary, nil
+}
+
+// summarizer analyzes an activity one point at a time, keeping nothing but
+// totals and the points of the last BestPaceDistance around.
+type summarizer struct {
+	options  Options
+	points   int
+	first    time.Time
+	previous gpx.GPXPoint
+	distance float64
+	moving   time.Duration
+	sensors  sensorTotals
+	best     effortFinder
+
+	elevation     ElevationSummary
+	elevations    int
+	elevationSum  float64
+	lastElevation float64
+	hasElevation  bool
+}
+
+// newSummarizer creates a summarizer with nothing added yet.
+func newSummarizer(options Options) *summarizer {
+	return &summarizer{
+		options:   options,
+		best:      effortFinder{meters: options.BestPaceDistance},
+		elevation: ElevationSummary{Min: math.Inf(1), Max: math.Inf(-1)},
+	}
+}
+
+// add analyzes the next point of an activity, which may start a new segment.
+func (s *summarizer) add(point *gpx.GPXPoint, segmentStart bool) {
+	if s.points == 0 {
+		s.first = point.Timestamp
+	} else if !segmentStart {
+		step := distance(&s.previous, point)
+		s.distance += step
+		if elapsed := point.Timestamp.Sub(s.previous.Timestamp); elapsed > 0 && step/elapsed.Seconds() >= s.options.MovingSpeed {
+			s.moving += elapsed
+		}
+	}
+
+	if elevation, ok := elevationOf(point); ok {
+		if s.hasElevation && !segmentStart {
+			if climb := elevation - s.lastElevation; climb > 0 {
+				s.elevation.Gain += climb
+			} else {
+				s.elevation.Loss -= climb
+			}
+		}
+		s.elevation.Min = math.Min(s.elevation.Min, elevation)
+		s.elevation.Max = math.Max(s.elevation.Max, elevation)
+		s.elevationSum += elevation
+		s.elevations++
+		s.lastElevation, s.hasElevation = elevation, true
+	}
+
+	s.sensors.add(DecodeSample(point))
+	s.best.add(milestone{index: s.points, distance: s.distance, time: point.Timestamp})
+	s.previous = *point
+	s.points++
+}
+
+// summary sums up the points added so far, leaving GradeAdjustedPace out.
+func (s *summarizer) summary() (Summary, error) {
+	if s.points == 0 {
+		return Summary{}, ErrNoPoints
+	}
+	summary := Summary{
+		StartedAt:   s.first,
+		EndedAt:     s.previous.Timestamp,
+		TotalTime:   Duration(s.previous.Timestamp.Sub(s.first)),
+		MovingTime:  Duration(s.moving),
+		Distance:    s.distance,
+		AveragePace: paceOf(s.moving, s.distance),
+		Points:      s.points,
+	}
+	if s.elevations != 0 {
+		elevation := s.elevation
+		elevation.Average = s.elevationSum / float64(s.elevations)
+		summary.Elevation = &elevation
+	}
+	if sensors := s.sensors.summary(); !sensors.IsEmpty() {
+		summary.Sensors = &sensors
+	}
+	if best, ok := s.best.best(); ok {
+		summary.BestPace = best.Pace
+	}
+	return summary, nil
+}
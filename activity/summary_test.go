@@ -0,0 +1,137 @@
+package activity
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// TestSummarizeASteadyRun verifies the summary of a run at 4 m/s, that is 4:10 per kilometer.
+func TestSummarizeASteadyRun(t *testing.T) {
+	// Arrange
+	samples := steady(601, 5, 4)
+	for idx := range samples {
+		samples[idx].elevation = 900 + float64(idx%3) // 900, 901, 902, 900...
+	}
+
+	// Act
+	got, err := Summarize(givenATrack(samples))
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, startedAt, got.StartedAt)
+	assert.Equal(t, startedAt.Add(50*time.Minute), got.EndedAt)
+	assert.Equal(t, Duration(50*time.Minute), got.TotalTime)
+	assert.Equal(t, Duration(50*time.Minute), got.MovingTime)
+	assert.InDelta(t, 12000, got.Distance, 0.01)
+	assert.InDelta(t, float64(250*time.Second), float64(got.AveragePace), float64(time.Millisecond))
+	assert.InDelta(t, float64(250*time.Second), float64(got.BestPace), float64(time.Millisecond))
+	assert.Equal(t, 601, got.Points)
+	require.NotNil(t, got.Elevation)
+	assert.Equal(t, 400.0, got.Elevation.Gain)
+	assert.Equal(t, 400.0, got.Elevation.Loss)
+	assert.Equal(t, 900.0, got.Elevation.Min)
+	assert.Equal(t, 902.0, got.Elevation.Max)
+	assert.InDelta(t, 901, got.Elevation.Average, 0.01)
+}
+
+// TestStopsArentMovingTime verifies standing still only counts towards the total time.
+func TestStopsArentMovingTime(t *testing.T) {
+	// Arrange
+	run := then(then(steady(121, 5, 4), steady(61, 5, 0)), steady(121, 5, 5))
+
+	// Act
+	got, err := Summarize(givenATrack(run))
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, Duration(25*time.Minute), got.TotalTime)
+	assert.Equal(t, Duration(20*time.Minute), got.MovingTime)
+	assert.InDelta(t, 5400, got.Distance, 0.01)
+	assert.InDelta(t, float64(200*time.Second), float64(got.BestPace), float64(time.Millisecond), "the faster half is 3:20 per kilometer")
+}
+
+// TestSegmentGapsAreTimeButNotDistance verifies points of different segments aren't joined.
+func TestSegmentGapsAreTimeButNotDistance(t *testing.T) {
+	// Arrange
+	second := steady(61, 5, 4)
+	for idx := range second {
+		second[idx].seconds += 600
+		second[idx].meters += 5000 // a jump in space that should be ignored
+	}
+
+	// Act
+	got, err := Summarize(givenATrack(steady(61, 5, 4), second))
+
+	// Assert
+	require.Nil(t, err)
+	assert.InDelta(t, 2400, got.Distance, 0.01)
+	assert.Equal(t, Duration(15*time.Minute), got.TotalTime)
+	assert.Equal(t, Duration(10*time.Minute), got.MovingTime)
+}
+
+// TestShortActivitiesHaveNoBestPace verifies the best pace needs a whole stretch.
+func TestShortActivitiesHaveNoBestPace(t *testing.T) {
+	// Act
+	got, err := Summarize(givenATrack(steady(11, 5, 4)), Options{BestPaceDistance: 150})
+	short, shortErr := Summarize(givenATrack(steady(11, 5, 4)))
+
+	// Assert
+	require.Nil(t, err)
+	require.Nil(t, shortErr)
+	assert.InDelta(t, float64(250*time.Second), float64(got.BestPace), float64(time.Millisecond))
+	assert.Zero(t, short.BestPace)
+}
+
+// TestActivitiesWithoutPointsCantBeSummarized verifies empty files are an error.
+func TestActivitiesWithoutPointsCantBeSummarized(t *testing.T) {
+	// Act
+	_, err := Summarize(&gpx.GPX{Tracks: []gpx.GPXTrack{{}}})
+
+	// Assert
+	assert.ErrorIs(t, err, ErrNoPoints)
+}
+
+// TestSummarizeTheGuineaPig verifies the summary of the half marathon in 14-test-subject.gpx.
+func TestSummarizeTheGuineaPig(t *testing.T) {
+	// Arrange
+	g := readGuineaPigFile(t)
+
+	// Act
+	got, err := Summarize(g)
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, g.GetTrackPointsNo(), got.Points)
+	assert.Equal(t, Duration(time.Hour+52*time.Minute+38*time.Second), got.TotalTime)
+	assert.InDelta(t, g.Length2D(), got.Distance, 0.01*g.Length2D(), "haversine and gpxgo's distances should agree")
+	assert.Greater(t, got.Distance, 21000.0)
+	assert.LessOrEqual(t, got.MovingTime, got.TotalTime)
+	assert.Less(t, got.BestPace, got.AveragePace)
+	require.NotNil(t, got.Elevation)
+	assert.LessOrEqual(t, got.Elevation.Min, got.Elevation.Average)
+	assert.LessOrEqual(t, got.Elevation.Average, got.Elevation.Max)
+	assert.Greater(t, got.Elevation.Gain, 0.0)
+}
+
+// TestSummariesAreJson verifies summaries serialize with readable durations and come back intact.
+func TestSummariesAreJson(t *testing.T) {
+	// Arrange
+	summary, _ := Summarize(givenATrack(steady(26, 5, 4)))
+	var got Summary
+
+	// Act
+	serialized, err := json.Marshal(summary)
+	_ = json.Unmarshal(serialized, &got)
+
+	// Assert
+	require.Nil(t, err)
+	assert.Contains(t, string(serialized), `"totalTime":"2m5s"`)
+	assert.Contains(t, string(serialized), `"averagePace":"4m10s"`)
+	assert.NotContains(t, string(serialized), "bestPace")
+	assert.Equal(t, summary, got)
+}
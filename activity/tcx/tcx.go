@@ -0,0 +1,250 @@
+// Package tcx converts GPX activities into Garmin's Training Center XML, for
+// the tools that read TCX rather than GPX.
+//
+// Activities are split into laps of the same length, like a watch's auto lap
+// would, and every lap holds the track points recorded during it. Heart rates
+// and cadences come from the points' TrackPointExtension.
+package tcx
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/rodolphocastro/golanghello/activity"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// TCX documents are within the TrainingCenterDatabase/v2 namespace, speeds and
+// running cadences, which TCX has no element for, within ActivityExtension/v2.
+const (
+	TrainingCenterDatabaseV2 = "http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2"
+	ActivityExtensionV2      = "http://www.garmin.com/xmlschemas/ActivityExtension/v2"
+)
+
+const (
+	SportRunning = "Running"
+	SportBiking  = "Biking"
+	SportOther   = "Other"
+)
+
+// ErrNoTimes is returned when any of an activity's track points has no time, which TCX requires.
+var ErrNoTimes = errors.New("some of the activity's track points have no times")
+
+// Options tunes the conversion.
+type Options struct {
+	// LapLength is how long laps are, in meters, defaults to activity.Kilometer.
+	LapLength float64
+	// Activity tunes the splits laps come from.
+	Activity activity.Options
+}
+
+// Database is a TCX document.
+type Database struct {
+	XMLName    xml.Name   `xml:"http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2 TrainingCenterDatabase"`
+	Activities []Activity `xml:"Activities>Activity"`
+}
+
+// Activity is an activity within a TCX document.
+type Activity struct {
+	Sport string `xml:"Sport,attr"`
+	// ID is when the activity started.
+	ID    Time   `xml:"Id"`
+	Laps  []Lap  `xml:"Lap"`
+	Notes string `xml:"Notes,omitempty"`
+}
+
+// Lap is a split of an activity.
+type Lap struct {
+	StartTime        Time           `xml:"StartTime,attr"`
+	TotalTimeSeconds float64        `xml:"TotalTimeSeconds"`
+	DistanceMeters   float64        `xml:"DistanceMeters"`
+	Calories         int            `xml:"Calories"`
+	AverageHeartRate *HeartRate     `xml:"AverageHeartRateBpm"`
+	MaximumHeartRate *HeartRate     `xml:"MaximumHeartRateBpm"`
+	Intensity        string         `xml:"Intensity"`
+	Cadence          *int           `xml:"Cadence"`
+	TriggerMethod    string         `xml:"TriggerMethod"`
+	Trackpoints      []Trackpoint   `xml:"Track>Trackpoint"`
+	Extensions       *LapExtensions `xml:"Extensions"`
+}
+
+// LapExtensions holds the average running cadence of a lap.
+type LapExtensions struct {
+	LX struct {
+		XMLName       xml.Name `xml:"http://www.garmin.com/xmlschemas/ActivityExtension/v2 LX"`
+		AvgRunCadence int      `xml:"AvgRunCadence"`
+	}
+}
+
+// HeartRate is a heart rate, in beats per minute.
+type HeartRate struct {
+	Value int `xml:"Value"`
+}
+
+// Trackpoint is a track point of a lap.
+type Trackpoint struct {
+	Time           Time                  `xml:"Time"`
+	Position       *Position             `xml:"Position"`
+	AltitudeMeters *float64              `xml:"AltitudeMeters"`
+	DistanceMeters float64               `xml:"DistanceMeters"`
+	HeartRate      *HeartRate            `xml:"HeartRateBpm"`
+	Cadence        *int                  `xml:"Cadence"`
+	Extensions     *TrackpointExtensions `xml:"Extensions"`
+}
+
+// Position is where a track point is.
+type Position struct {
+	LatitudeDegrees  float64 `xml:"LatitudeDegrees"`
+	LongitudeDegrees float64 `xml:"LongitudeDegrees"`
+}
+
+// TrackpointExtensions holds the speed and running cadence of a track point.
+type TrackpointExtensions struct {
+	TPX struct {
+		XMLName    xml.Name `xml:"http://www.garmin.com/xmlschemas/ActivityExtension/v2 TPX"`
+		Speed      *float64 `xml:"Speed"`
+		RunCadence *int     `xml:"RunCadence"`
+	}
+}
+
+// Time is a time written in UTC, as TCX documents have them.
+type Time time.Time
+
+// MarshalText writes the time as RFC 3339 in UTC.
+func (t Time) MarshalText() ([]byte, error) {
+	return []byte(time.Time(t).UTC().Format(time.RFC3339Nano)), nil
+}
+
+// Convert converts the track points of an activity into a TCX document with a
+// single activity. The sport comes from the type of the first track, running
+// cadences go into extensions as TCX's Cadence is meant for bikes.
+func Convert(g *gpx.GPX, opts ...Options) (Database, error) {
+	var options Options
+	if len(opts) != 0 {
+		options = opts[0]
+	}
+	if options.LapLength <= 0 {
+		options.LapLength = activity.Kilometer
+	}
+	records, err := activity.Records(g)
+	if err != nil {
+		return Database{}, err
+	}
+	for _, record := range records {
+		if record.Time.IsZero() {
+			return Database{}, ErrNoTimes
+		}
+	}
+	splits, err := activity.Splits(g, options.LapLength, options.Activity)
+	if err != nil {
+		return Database{}, err
+	}
+
+	converted := Activity{Sport: sportOf(g), ID: Time(records[0].Time), Notes: g.Name}
+	if len(splits) == 0 {
+		// standing still for the whole activity still makes a lap
+		last := records[len(records)-1]
+		splits = []activity.Split{{StartedAt: records[0].Time, Elapsed: activity.Duration(last.Time.Sub(records[0].Time))}}
+	}
+	laps := make([][]activity.Record, len(splits))
+	for _, record := range records {
+		lap := int(math.Min(math.Floor(record.Distance/options.LapLength), float64(len(splits)-1)))
+		laps[lap] = append(laps[lap], record)
+	}
+	for idx, split := range splits {
+		converted.Laps = append(converted.Laps, newLap(split, laps[idx], converted.Sport))
+	}
+	return Database{Activities: []Activity{converted}}, nil
+}
+
+// sportOf tells the TCX sport of an activity from the type of its first track.
+func sportOf(g *gpx.GPX) string {
+	if len(g.Tracks) == 0 {
+		return SportOther
+	}
+	switch kind := strings.ToLower(g.Tracks[0].Type); {
+	case strings.Contains(kind, "run"):
+		return SportRunning
+	case strings.Contains(kind, "bik"), strings.Contains(kind, "cycl"):
+		return SportBiking
+	default:
+		return SportOther
+	}
+}
+
+// newLap creates the lap of a split with the records within it.
+func newLap(split activity.Split, records []activity.Record, sport string) Lap {
+	lap := Lap{
+		StartTime:        Time(split.StartedAt),
+		TotalTimeSeconds: time.Duration(split.Elapsed).Seconds(),
+		DistanceMeters:   split.Distance,
+		Intensity:        "Active",
+		TriggerMethod:    "Distance",
+	}
+	samples := make([]activity.Sample, len(records))
+	for idx, record := range records {
+		samples[idx] = record.Sample
+		lap.Trackpoints = append(lap.Trackpoints, newTrackpoint(record, sport))
+	}
+	sensors := activity.SummarizeSamples(samples)
+	if sensors.AverageHeartRate != 0 {
+		lap.AverageHeartRate = &HeartRate{Value: int(math.Round(sensors.AverageHeartRate))}
+		lap.MaximumHeartRate = &HeartRate{Value: sensors.MaxHeartRate}
+	}
+	if sensors.AverageCadence != 0 {
+		cadence := int(math.Round(sensors.AverageCadence))
+		if sport == SportRunning {
+			lap.Extensions = &LapExtensions{}
+			lap.Extensions.LX.AvgRunCadence = cadence
+		} else {
+			lap.Cadence = &cadence
+		}
+	}
+	return lap
+}
+
+// newTrackpoint creates the trackpoint of a record.
+func newTrackpoint(record activity.Record, sport string) Trackpoint {
+	point := Trackpoint{
+		Time:           Time(record.Time),
+		Position:       &Position{LatitudeDegrees: record.Latitude, LongitudeDegrees: record.Longitude},
+		AltitudeMeters: record.Elevation,
+		DistanceMeters: record.Distance,
+	}
+	if record.HeartRate != nil && *record.HeartRate > 0 {
+		point.HeartRate = &HeartRate{Value: *record.HeartRate}
+	}
+	if record.Cadence != nil && sport != SportRunning {
+		point.Cadence = record.Cadence
+	}
+	if record.Speed != nil || (record.Cadence != nil && sport == SportRunning) {
+		point.Extensions = &TrackpointExtensions{}
+		point.Extensions.TPX.Speed = record.Speed
+		if sport == SportRunning {
+			point.Extensions.TPX.RunCadence = record.Cadence
+		}
+	}
+	return point
+}
+
+// Write converts an activity and writes it as an indented TCX document.
+func Write(w io.Writer, g *gpx.GPX, opts ...Options) error {
+	database, err := Convert(g, opts...)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(database); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,163 @@
+package tcx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rodolphocastro/golanghello/activity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+const pathToGuineaPig = "../../14-test-subject.gpx"
+
+// metersPerDegree is how many meters a degree of latitude has with the haversine formula.
+const metersPerDegree = 6371 * 1000 * math.Pi / 180
+
+// startedAt is when synthetic tracks start.
+var startedAt = time.Date(2022, time.June, 12, 9, 4, 10, 0, time.UTC)
+
+// givenARun creates a track of some kind going north from the equator, 30
+// meters every 10 seconds, with a heart rate going up by one every point and a
+// steady cadence.
+func givenARun(kind string, points int) *gpx.GPX {
+	var segment gpx.GPXTrackSegment
+	for idx := 0; idx < points; idx++ {
+		point := gpx.GPXPoint{
+			Point:     gpx.Point{Latitude: float64(idx) * 30 / metersPerDegree},
+			Timestamp: startedAt.Add(time.Duration(idx) * 10 * time.Second),
+		}
+		point.Elevation.SetValue(900)
+		reading := func(name string, value int) gpx.ExtensionNode {
+			return gpx.ExtensionNode{XMLName: xml.Name{Space: activity.TrackPointExtensionV1, Local: name}, Data: strconv.Itoa(value)}
+		}
+		point.Extensions.Nodes = []gpx.ExtensionNode{{
+			XMLName: xml.Name{Space: activity.TrackPointExtensionV1, Local: "TrackPointExtension"},
+			Nodes:   []gpx.ExtensionNode{reading("hr", 100+idx), reading("cad", 85)},
+		}}
+		segment.AppendPoint(&point)
+	}
+	track := gpx.GPXTrack{Name: "Synthetic", Type: kind}
+	track.AppendSegment(&segment)
+	g := &gpx.GPX{Version: "1.1", Creator: "golanghello", Name: "Morning"}
+	g.AppendTrack(&track)
+	return g
+}
+
+// TestConvertSplitsRunsIntoLaps verifies every kilometer is a lap with its points, heart rates and running cadences.
+func TestConvertSplitsRunsIntoLaps(t *testing.T) {
+	// Act
+	got, err := Convert(givenARun("running", 67))
+
+	// Assert
+	require.Nil(t, err)
+	require.Len(t, got.Activities, 1)
+	run := got.Activities[0]
+	assert.Equal(t, SportRunning, run.Sport)
+	assert.Equal(t, startedAt, time.Time(run.ID))
+	assert.Equal(t, "Morning", run.Notes)
+	require.Len(t, run.Laps, 2)
+	first, second := run.Laps[0], run.Laps[1]
+	assert.Equal(t, []int{34, 33}, []int{len(first.Trackpoints), len(second.Trackpoints)})
+	assert.InDelta(t, 1000, first.DistanceMeters, 0.01)
+	assert.InDelta(t, 980, second.DistanceMeters, 0.01)
+	assert.InDelta(t, 1000.0/3, first.TotalTimeSeconds, 0.01)
+	assert.InDelta(t, 1000.0/3, time.Time(second.StartTime).Sub(startedAt).Seconds(), 0.01)
+	assert.Equal(t, &HeartRate{Value: 117}, first.AverageHeartRate, "the mean of 100 to 133, rounded")
+	assert.Equal(t, &HeartRate{Value: 133}, first.MaximumHeartRate)
+	require.NotNil(t, first.Extensions)
+	assert.Equal(t, 85, first.Extensions.LX.AvgRunCadence)
+	assert.Nil(t, first.Cadence)
+
+	point := second.Trackpoints[0]
+	assert.Equal(t, startedAt.Add(340*time.Second), time.Time(point.Time))
+	assert.InDelta(t, 1020, point.DistanceMeters, 0.01)
+	assert.Equal(t, &HeartRate{Value: 134}, point.HeartRate)
+	assert.Nil(t, point.Cadence)
+	require.NotNil(t, point.Extensions)
+	assert.Equal(t, 85, *point.Extensions.TPX.RunCadence)
+	assert.InDelta(t, 3, *point.Extensions.TPX.Speed, 0.01)
+	assert.Nil(t, first.Trackpoints[0].Extensions.TPX.Speed, "the first point has no speed")
+}
+
+// TestConvertPutsBikeCadencesInCadence verifies rides use TCX's own cadence elements.
+func TestConvertPutsBikeCadencesInCadence(t *testing.T) {
+	// Act
+	got, err := Convert(givenARun("cycling", 67), Options{LapLength: 500})
+
+	// Assert
+	require.Nil(t, err)
+	ride := got.Activities[0]
+	assert.Equal(t, SportBiking, ride.Sport)
+	require.Len(t, ride.Laps, 4)
+	assert.Equal(t, 85, *ride.Laps[0].Cadence)
+	assert.Nil(t, ride.Laps[0].Extensions)
+	assert.Equal(t, 85, *ride.Laps[3].Trackpoints[0].Cadence)
+	assert.Nil(t, ride.Laps[3].Trackpoints[0].Extensions.TPX.RunCadence)
+}
+
+// TestConvertNeedsTimedPoints verifies activities without points, or without times, can't be converted.
+func TestConvertNeedsTimedPoints(t *testing.T) {
+	_, err := Convert(&gpx.GPX{})
+	assert.ErrorIs(t, err, activity.ErrNoPoints)
+
+	untimed := givenARun("running", 3)
+	for idx := range untimed.Tracks[0].Segments[0].Points {
+		untimed.Tracks[0].Segments[0].Points[idx].Timestamp = time.Time{}
+	}
+	_, err = Convert(untimed)
+	assert.ErrorIs(t, err, ErrNoTimes)
+}
+
+// TestConvertNeedsEveryPointTimed verifies activities where only some points have times can't be converted.
+func TestConvertNeedsEveryPointTimed(t *testing.T) {
+	// Arrange
+	partly := givenARun("running", 5)
+	partly.Tracks[0].Segments[0].Points[3].Timestamp = time.Time{}
+
+	// Act
+	_, err := Convert(partly)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrNoTimes)
+}
+
+// TestWriteTheGuineaPigFile verifies the guinea pig is written as TCX with every point and a lap per kilometer.
+func TestWriteTheGuineaPigFile(t *testing.T) {
+	// Arrange
+	g, err := gpx.ParseFile(pathToGuineaPig)
+	require.Nil(t, err)
+	summary, err := activity.Summarize(g)
+	require.Nil(t, err)
+	var written bytes.Buffer
+
+	// Act
+	err = Write(&written, g)
+
+	// Assert
+	require.Nil(t, err)
+	counts := map[string]int{}
+	decoder := xml.NewDecoder(&written)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		require.Nil(t, err)
+		if start, ok := token.(xml.StartElement); ok {
+			assert.Contains(t, []string{TrainingCenterDatabaseV2, ActivityExtensionV2}, start.Name.Space, start.Name.Local)
+			counts[start.Name.Local]++
+		}
+	}
+	assert.Equal(t, 1, counts["TrainingCenterDatabase"])
+	assert.Equal(t, 1657, counts["Trackpoint"])
+	assert.Equal(t, 1657, counts["HeartRateBpm"])
+	assert.Equal(t, int(math.Ceil(summary.Distance/activity.Kilometer)), counts["Lap"])
+	assert.Equal(t, counts["Lap"], counts["LX"])
+}
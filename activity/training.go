@@ -0,0 +1,214 @@
+package activity
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// ErrNoHeartRate is returned when an activity has no heart rate readings to analyze.
+var ErrNoHeartRate = errors.New("the activity has no heart rate readings")
+
+// ZoneBasis is what heart rate zones are a percentage of.
+type ZoneBasis string
+
+const (
+	// PercentOfMax zones are a percentage of the max heart rate.
+	PercentOfMax ZoneBasis = "max"
+	// PercentOfReserve zones are a percentage of the heart rate reserve, the
+	// difference between the max and the resting heart rate, on top of the
+	// resting heart rate. That's Karvonen's formula.
+	PercentOfReserve ZoneBasis = "reserve"
+)
+
+const (
+	// BanisterMen weights TRIMP for men.
+	BanisterMen = 1.92
+	// BanisterWomen weights TRIMP for women.
+	BanisterWomen = 1.67
+	// DefaultThreshold is the lactate threshold heart rate, as a fraction of the max heart rate, when it isn't known.
+	DefaultThreshold = 0.9
+)
+
+// DefaultZoneBounds are where the five usual zones start.
+var DefaultZoneBounds = []float64{0.5, 0.6, 0.7, 0.8, 0.9}
+
+// Athlete is whoever recorded an activity, as far as their heart goes.
+type Athlete struct {
+	MaxHeartRate     int
+	RestingHeartRate int
+	// ThresholdHeartRate is the lactate threshold heart rate, defaults to
+	// DefaultThreshold of MaxHeartRate. It must be above RestingHeartRate and
+	// at most MaxHeartRate.
+	ThresholdHeartRate int
+	// Weighting is Banister's TRIMP weighting, defaults to BanisterMen.
+	Weighting float64
+	// Basis defaults to PercentOfMax.
+	Basis ZoneBasis
+	// ZoneBounds are the fractions of the Basis each zone starts at, in
+	// ascending order. Defaults to DefaultZoneBounds.
+	ZoneBounds []float64
+}
+
+// withDefaults fills in whatever wasn't set, failing when the heart rates make no sense.
+func (a Athlete) withDefaults() (Athlete, error) {
+	if a.RestingHeartRate <= 0 || a.MaxHeartRate <= a.RestingHeartRate {
+		return a, errors.New("athletes need a resting heart rate and a higher max heart rate")
+	}
+	if a.ThresholdHeartRate <= 0 {
+		a.ThresholdHeartRate = int(math.Round(DefaultThreshold * float64(a.MaxHeartRate)))
+	}
+	if a.ThresholdHeartRate <= a.RestingHeartRate || a.ThresholdHeartRate > a.MaxHeartRate {
+		return a, fmt.Errorf("the threshold heart rate of %v must be above the resting heart rate and at most the max heart rate", a.ThresholdHeartRate)
+	}
+	if a.Weighting <= 0 {
+		a.Weighting = BanisterMen
+	}
+	if a.Basis == "" {
+		a.Basis = PercentOfMax
+	}
+	if a.Basis != PercentOfMax && a.Basis != PercentOfReserve {
+		return a, errors.New("zones are either a percent of the max heart rate or of the heart rate reserve")
+	}
+	if len(a.ZoneBounds) == 0 {
+		a.ZoneBounds = DefaultZoneBounds
+	}
+	for idx := 1; idx < len(a.ZoneBounds); idx++ {
+		if a.ZoneBounds[idx] <= a.ZoneBounds[idx-1] {
+			return a, errors.New("zone bounds must be in ascending order")
+		}
+	}
+	return a, nil
+}
+
+// heartRateAt is the heart rate a fraction of the Basis is.
+func (a Athlete) heartRateAt(fraction float64) float64 {
+	if a.Basis == PercentOfReserve {
+		return float64(a.RestingHeartRate) + fraction*float64(a.MaxHeartRate-a.RestingHeartRate)
+	}
+	return fraction * float64(a.MaxHeartRate)
+}
+
+// intensity is how far into the heart rate reserve a heart rate is, from 0 to 1.
+func (a Athlete) intensity(heartRate float64) float64 {
+	reserve := (heartRate - float64(a.RestingHeartRate)) / float64(a.MaxHeartRate-a.RestingHeartRate)
+	return math.Max(0, math.Min(1, reserve))
+}
+
+// trimpPerMinute is Banister's TRIMP for a minute at a heart rate.
+func (a Athlete) trimpPerMinute(heartRate float64) float64 {
+	x := a.intensity(heartRate)
+	return x * 0.64 * math.Exp(a.Weighting*x)
+}
+
+// Zone is the time spent within a heart rate zone.
+type Zone struct {
+	// Number starts at 1, time below the first zone goes into a zone numbered 0.
+	Number int `bson:"number" json:"number"`
+	// Min is the slowest heart rate within the zone, in beats per minute.
+	Min float64 `bson:"min" json:"min"`
+	// Max is where the next zone starts, zero for the last zone.
+	Max  float64  `bson:"max,omitempty" json:"max,omitempty"`
+	Time Duration `bson:"time" json:"time"`
+	// Share is the fraction of the time with a heart rate spent within the zone.
+	Share float64 `bson:"share" json:"share"`
+}
+
+// Training is the training load of an activity.
+type Training struct {
+	Basis ZoneBasis `bson:"basis" json:"basis"`
+	Zones []Zone    `bson:"zones" json:"zones"`
+	// Time is how long the heart rate was known.
+	Time             Duration `bson:"time" json:"time"`
+	AverageHeartRate float64  `bson:"averageHeartRate" json:"averageHeartRate"`
+	// TRIMP is Banister's training impulse, the minutes at each heart rate
+	// weighted by how hard that heart rate is:
+	//
+	//	TRIMP = Σ minutes × x × 0.64 × e^(weighting × x)
+	//
+	// x being the fraction of the heart rate reserve the heart rate is at.
+	TRIMP float64 `bson:"trimp" json:"trimp"`
+	// StressScore is the heart rate training stress score, TRIMP as a
+	// percentage of the TRIMP of an hour at the threshold heart rate. An
+	// hour's race at threshold scores 100.
+	StressScore float64 `bson:"stressScore" json:"stressScore"`
+	// AerobicDecoupling is how much the efficiency, the speed per heartbeat,
+	// dropped from the first half of the activity to the second, as a
+	// percentage of the first half's. Under 5% an effort was aerobic. Zero
+	// when either half has no heart rate or the first half went nowhere.
+	AerobicDecoupling float64 `bson:"aerobicDecoupling" json:"aerobicDecoupling"`
+}
+
+// AnalyzeTraining computes the time in each heart rate zone and the training
+// load of an activity. Steps between points are given the mean heart rate of
+// both ends, steps longer than DefaultMaxGap and gaps between segments are
+// left out since the heart rate during them isn't known.
+func AnalyzeTraining(g *gpx.GPX, athlete Athlete) (Training, error) {
+	athlete, err := athlete.withDefaults()
+	if err != nil {
+		return Training{}, err
+	}
+	p, err := newProfile(g)
+	if err != nil {
+		return Training{}, err
+	}
+
+	training := Training{Basis: athlete.Basis, Zones: make([]Zone, len(athlete.ZoneBounds)+1)}
+	for idx := range training.Zones {
+		zone := &training.Zones[idx]
+		zone.Number = idx
+		if idx != 0 {
+			zone.Min = athlete.heartRateAt(athlete.ZoneBounds[idx-1])
+		}
+		if idx != len(athlete.ZoneBounds) {
+			zone.Max = athlete.heartRateAt(athlete.ZoneBounds[idx])
+		}
+	}
+
+	var known time.Duration
+	var beats, minutes float64
+	halves := [2]struct{ meters, beats float64 }{}
+	half := p.points[0].Timestamp.Add(p.elapsed(len(p.points)-1) / 2)
+	for idx := 1; idx < len(p.points); idx++ {
+		step := p.points[idx].Timestamp.Sub(p.points[idx-1].Timestamp)
+		heartRate := blendReadings(DecodeSample(&p.points[idx-1]).HeartRate, DecodeSample(&p.points[idx]).HeartRate, 0.5)
+		if p.segmentStarts[idx] || step <= 0 || step > DefaultMaxGap || heartRate == nil {
+			continue
+		}
+		known += step
+		beats += *heartRate * step.Minutes()
+		minutes += step.Minutes()
+		training.TRIMP += step.Minutes() * athlete.trimpPerMinute(*heartRate)
+		zone := 0
+		for zone < len(athlete.ZoneBounds) && *heartRate >= training.Zones[zone+1].Min {
+			zone++
+		}
+		training.Zones[zone].Time += Duration(step)
+
+		h := &halves[0]
+		if p.points[idx].Timestamp.After(half) {
+			h = &halves[1]
+		}
+		h.meters += p.distances[idx] - p.distances[idx-1]
+		h.beats += *heartRate * step.Minutes()
+	}
+	if known == 0 {
+		return Training{}, ErrNoHeartRate
+	}
+
+	training.Time = Duration(known)
+	training.AverageHeartRate = beats / minutes
+	for idx := range training.Zones {
+		training.Zones[idx].Share = float64(training.Zones[idx].Time) / float64(known)
+	}
+	training.StressScore = training.TRIMP / (60 * athlete.trimpPerMinute(float64(athlete.ThresholdHeartRate))) * 100
+	if halves[0].beats > 0 && halves[1].beats > 0 && halves[0].meters > 0 {
+		// speed per heart rate is how many meters each heartbeat goes
+		first, second := halves[0].meters/halves[0].beats, halves[1].meters/halves[1].beats
+		training.AerobicDecoupling = (first - second) / first * 100
+	}
+	return training, nil
+}
@@ -0,0 +1,172 @@
+package activity
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// givenAnAthlete creates an athlete with a max heart rate of 200 and a resting one of 50.
+func givenAnAthlete() Athlete {
+	return Athlete{MaxHeartRate: 200, RestingHeartRate: 50}
+}
+
+// atHeartRate sets the heart rate of samples.
+func atHeartRate(samples []sample, heartRate int) []sample {
+	for idx := range samples {
+		samples[idx].heartRate = heartRate
+	}
+	return samples
+}
+
+// TestAnalyzeTrainingFindsTheZone verifies both bases put a steady effort in their zone.
+func TestAnalyzeTrainingFindsTheZone(t *testing.T) {
+	scenarios := map[ZoneBasis]int{
+		PercentOfMax:     3, // 150 is 75% of 200
+		PercentOfReserve: 2, // 150 is 67% of the way from 50 to 200
+	}
+	for basis, expected := range scenarios {
+		// Arrange
+		g := givenATrack(atHeartRate(steady(41, 5, 3), 150))
+		athlete := givenAnAthlete()
+		athlete.Basis = basis
+
+		// Act
+		got, err := AnalyzeTraining(g, athlete)
+
+		// Assert
+		require.Nil(t, err)
+		require.Len(t, got.Zones, 6)
+		assert.Equal(t, basis, got.Basis)
+		assert.Equal(t, Duration(200*time.Second), got.Zones[expected].Time, basis)
+		assert.Equal(t, 1.0, got.Zones[expected].Share)
+		assert.Equal(t, Duration(200*time.Second), got.Time)
+		assert.InDelta(t, 150, got.AverageHeartRate, 1e-9)
+		assert.InDelta(t, 5.115, got.TRIMP, 0.001, "3⅓ minutes × ⅔ × 0.64 × e^(1.92 × ⅔)")
+	}
+}
+
+// TestAnalyzeTrainingZoneBounds verifies the heart rates each zone goes through.
+func TestAnalyzeTrainingZoneBounds(t *testing.T) {
+	// Arrange
+	athlete := givenAnAthlete()
+	athlete.Basis = PercentOfReserve
+	athlete.ZoneBounds = []float64{0.6, 0.8}
+
+	// Act
+	got, err := AnalyzeTraining(givenATrack(atHeartRate(steady(11, 5, 3), 100)), athlete)
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, []Zone{
+		{Number: 0, Min: 0, Max: 140, Time: Duration(50 * time.Second), Share: 1},
+		{Number: 1, Min: 140, Max: 170},
+		{Number: 2, Min: 170},
+	}, got.Zones)
+}
+
+// TestAnalyzeTrainingScoresAnHourAtThreshold verifies an hour at threshold scores 100.
+func TestAnalyzeTrainingScoresAnHourAtThreshold(t *testing.T) {
+	// Arrange
+	g := givenATrack(atHeartRate(steady(721, 5, 4), 180))
+
+	// Act
+	got, err := AnalyzeTraining(g, givenAnAthlete())
+
+	// Assert
+	require.Nil(t, err)
+	assert.InDelta(t, 100, got.StressScore, 1e-9)
+	assert.Equal(t, 1.0, got.Zones[5].Share, "180 is 90% of 200")
+	assert.InDelta(t, 0, got.AerobicDecoupling, 1e-9)
+}
+
+// TestAnalyzeTrainingMeasuresDecoupling verifies a heart rate drifting up at the same pace is decoupling.
+func TestAnalyzeTrainingMeasuresDecoupling(t *testing.T) {
+	// Arrange
+	g := givenATrack(then(atHeartRate(steady(361, 5, 3), 140), atHeartRate(steady(361, 5, 3), 154)))
+
+	// Act
+	got, err := AnalyzeTraining(g, givenAnAthlete())
+
+	// Assert
+	require.Nil(t, err)
+	assert.InDelta(t, 9.09, got.AerobicDecoupling, 0.05, "140 / 154 is 91% as efficient, give or take the step in between")
+}
+
+// TestAnalyzeTrainingNeedsHeartRates verifies activities need a heart rate and athletes sensible heart rates.
+func TestAnalyzeTrainingNeedsHeartRates(t *testing.T) {
+	// Act
+	_, err := AnalyzeTraining(givenATrack(steady(11, 5, 3)), givenAnAthlete())
+
+	// Assert
+	assert.ErrorIs(t, err, ErrNoHeartRate)
+	for _, athlete := range []Athlete{
+		{MaxHeartRate: 190},
+		{MaxHeartRate: 50, RestingHeartRate: 60},
+		{MaxHeartRate: 190, RestingHeartRate: 50, Basis: "lactate"},
+		{MaxHeartRate: 190, RestingHeartRate: 50, ZoneBounds: []float64{0.8, 0.6}},
+	} {
+		_, err := AnalyzeTraining(givenATrack(atHeartRate(steady(11, 5, 3), 150)), athlete)
+		assert.Error(t, err, "%+v", athlete)
+	}
+}
+
+// TestAnalyzeTrainingNeedsASensibleThreshold verifies thresholds outside the heart rate reserve are refused, defaults included.
+func TestAnalyzeTrainingNeedsASensibleThreshold(t *testing.T) {
+	scenarios := map[string]Athlete{
+		"at the resting heart rate":    {MaxHeartRate: 200, RestingHeartRate: 50, ThresholdHeartRate: 50},
+		"below the resting heart rate": {MaxHeartRate: 200, RestingHeartRate: 50, ThresholdHeartRate: 40},
+		"above the max heart rate":     {MaxHeartRate: 200, RestingHeartRate: 50, ThresholdHeartRate: 201},
+		"defaulting below the resting": {MaxHeartRate: 100, RestingHeartRate: 95},
+	}
+	for name, athlete := range scenarios {
+		// Act
+		_, err := AnalyzeTraining(givenATrack(atHeartRate(steady(11, 5, 3), 150)), athlete)
+
+		// Assert
+		assert.Error(t, err, name)
+	}
+}
+
+// TestAnalyzeTrainingSkipsDecouplingWithoutDistance verifies a first half standing still has no decoupling rather than an infinite one.
+func TestAnalyzeTrainingSkipsDecouplingWithoutDistance(t *testing.T) {
+	// Arrange
+	g := givenATrack(then(atHeartRate(steady(361, 5, 0), 100), atHeartRate(steady(361, 5, 3), 150)))
+
+	// Act
+	got, err := AnalyzeTraining(g, givenAnAthlete())
+
+	// Assert
+	require.Nil(t, err)
+	assert.Zero(t, got.AerobicDecoupling)
+	_, err = json.Marshal(got)
+	assert.Nil(t, err)
+}
+
+// TestAnalyzeTrainingOfTheGuineaPig verifies the half marathon's zones add up.
+func TestAnalyzeTrainingOfTheGuineaPig(t *testing.T) {
+	// Arrange
+	g := readGuineaPigFile(t)
+	summary, _ := Summarize(g)
+	athlete := Athlete{MaxHeartRate: 190, RestingHeartRate: 55, Basis: PercentOfReserve}
+
+	// Act
+	got, err := AnalyzeTraining(g, athlete)
+
+	// Assert
+	require.Nil(t, err)
+	var total Duration
+	var share float64
+	for _, zone := range got.Zones {
+		total += zone.Time
+		share += zone.Share
+	}
+	assert.Equal(t, got.Time, total)
+	assert.InDelta(t, 1, share, 1e-9)
+	assert.InDelta(t, summary.Sensors.AverageHeartRate, got.AverageHeartRate, 5)
+	assert.Greater(t, got.StressScore, 100.0, "nearly two hours running")
+	t.Logf("%+v", got)
+}
@@ -0,0 +1,29 @@
+package activity
+
+import (
+	"io"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// WriteGPX writes an activity as an indented GPX 1.1 document.
+func WriteGPX(w io.Writer, g *gpx.GPX) error {
+	content, err := g.ToXml(gpx.ToXmlParams{Version: "1.1", Indent: true})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
+}
+
+// copyTracks copies an activity down to its segments, so their points can be
+// replaced without touching the original. Points themselves are shared.
+func copyTracks(g *gpx.GPX) *gpx.GPX {
+	copied := *g
+	copied.Tracks = make([]gpx.GPXTrack, len(g.Tracks))
+	for idx, track := range g.Tracks {
+		copied.Tracks[idx] = track
+		copied.Tracks[idx].Segments = append([]gpx.GPXTrackSegment(nil), track.Segments...)
+	}
+	return &copied
+}
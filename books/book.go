@@ -0,0 +1,126 @@
+package books
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Book is a book! It is the document we keep in the awesomeThings collection.
+type Book struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Title  string             `bson:"title,omitempty" json:"title,omitempty"`
+	Author string             `bson:"author,omitempty" json:"author,omitempty"`
+	Tags   []string           `bson:"tags,omitempty" json:"tags,omitempty"`
+	// Version is bumped on every change and must match the stored one for an update to go through.
+	Version   int64      `bson:"version" json:"version"`
+	CreatedAt time.Time  `bson:"createdAt,omitempty" json:"createdAt,omitempty"`
+	UpdatedAt time.Time  `bson:"updatedAt,omitempty" json:"updatedAt,omitempty"`
+	DeletedAt *time.Time `bson:"deletedAt,omitempty" json:"deletedAt,omitempty"`
+}
+
+// ErrNotFound is returned when a book doesn't exist, or was deleted.
+var ErrNotFound = errors.New("book not found")
+
+// ConflictError is returned when a book was changed by someone else since it was read.
+type ConflictError struct {
+	ID       primitive.ObjectID
+	Expected int64
+	Actual   int64
+}
+
+// Error describes the conflict.
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("book %v is at version %v but version %v was expected", e.ID.Hex(), e.Actual, e.Expected)
+}
+
+// Action is something that happened to a book.
+type Action string
+
+const (
+	ActionCreated  Action = "created"
+	ActionUpdated  Action = "updated"
+	ActionDeleted  Action = "deleted"
+	ActionArchived Action = "archived"
+)
+
+// Change is a single field that changed.
+type Change struct {
+	Field  string      `bson:"field" json:"field"`
+	Before interface{} `bson:"before,omitempty" json:"before,omitempty"`
+	After  interface{} `bson:"after,omitempty" json:"after,omitempty"`
+}
+
+// AuditEntry records who did what to a book, entries are never changed once written.
+type AuditEntry struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	BookID  primitive.ObjectID `bson:"bookId" json:"bookId"`
+	Actor   string             `bson:"actor" json:"actor"`
+	Action  Action             `bson:"action" json:"action"`
+	Version int64              `bson:"version" json:"version"`
+	At      time.Time          `bson:"at" json:"at"`
+	Changes []Change           `bson:"changes,omitempty" json:"changes,omitempty"`
+}
+
+// UnknownActor is recorded when a change is made without an actor in its context.
+const UnknownActor = "unknown"
+
+// actorKey is the context key holding who is making changes.
+type actorKey struct{}
+
+// WithActor returns a context that records changes as made by an actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom gets the actor within a context, UnknownActor if there's none.
+func ActorFrom(ctx context.Context) string {
+	actor, ok := ctx.Value(actorKey{}).(string)
+	if !ok || actor == "" {
+		return UnknownActor
+	}
+	return actor
+}
+
+// diff lists the fields that changed between two versions of a book.
+func diff(before, after Book) []Change {
+	var changes []Change
+	if before.Title != after.Title {
+		changes = append(changes, Change{Field: "title", Before: before.Title, After: after.Title})
+	}
+	if before.Author != after.Author {
+		changes = append(changes, Change{Field: "author", Before: before.Author, After: after.Author})
+	}
+	if !equalTags(before.Tags, after.Tags) {
+		changes = append(changes, Change{Field: "tags", Before: before.Tags, After: after.Tags})
+	}
+	return changes
+}
+
+// equalTags checks if two tag lists are the same.
+func equalTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// newAuditEntry creates the audit entry for something that happened to a book.
+func newAuditEntry(ctx context.Context, action Action, before, after Book, at time.Time) AuditEntry {
+	return AuditEntry{
+		BookID:  after.ID,
+		Actor:   ActorFrom(ctx),
+		Action:  action,
+		Version: after.Version,
+		At:      at,
+		Changes: diff(before, after),
+	}
+}
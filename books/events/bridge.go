@@ -0,0 +1,105 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.uber.org/zap"
+)
+
+// Options tunes the behavior of a Bridge.
+type Options struct {
+	// Topic is where events are published, defaults to DefaultTopic.
+	Topic string
+	// Source is the source of the events, defaults to DefaultSource.
+	Source string
+	// Logger receives what the bridge does, defaults to a no-op logger.
+	Logger *zap.Logger
+}
+
+// withDefaults fills in whatever wasn't set in the first of many Options.
+func withDefaults(opts []Options) Options {
+	var o Options
+	if len(opts) != 0 {
+		o = opts[0]
+	}
+	if o.Topic == "" {
+		o.Topic = DefaultTopic
+	}
+	if o.Source == "" {
+		o.Source = DefaultSource
+	}
+	if o.Logger == nil {
+		o.Logger = zap.NewNop()
+	}
+	return o
+}
+
+// Bridge turns the changes of the books collection into Cloud Events.
+//
+// A change's resume token is only saved once its event was published, so a
+// restart never loses events. The one event that was in flight when the bridge
+// stopped is published again, with the same ID, for consumers to deduplicate.
+type Bridge struct {
+	open      SourceOpener
+	publisher Publisher
+	tokens    TokenStore
+	options   Options
+}
+
+// NewBridge creates a Bridge.
+func NewBridge(open SourceOpener, publisher Publisher, tokens TokenStore, opts ...Options) *Bridge {
+	return &Bridge{open: open, publisher: publisher, tokens: tokens, options: withDefaults(opts)}
+}
+
+// Run resumes after the last saved token and publishes events until ctx is done,
+// the source runs out of changes (which returns nil) or something fails.
+func (b *Bridge) Run(ctx context.Context) error {
+	token, err := b.tokens.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("loading the resume token: %w", err)
+	}
+	source, err := b.open(ctx, token)
+	if err != nil {
+		return fmt.Errorf("opening the change source: %w", err)
+	}
+	defer source.Close(context.Background())
+	b.options.Logger.Info("bridge started", zap.String("topic", b.options.Topic), zap.Bool("resumed", token != nil))
+
+	for {
+		change, err := source.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			b.options.Logger.Info("change source closed")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = b.forward(ctx, change); err != nil {
+			return err
+		}
+	}
+}
+
+// forward publishes the event of a change, if it has one, and then saves its token.
+func (b *Bridge) forward(ctx context.Context, change Change) error {
+	logger := b.options.Logger.With(zap.String("bookId", change.BookID().Hex()), zap.String("operation", string(change.Operation)))
+	newEvent, ok, err := NewEvent(change, b.options.Source)
+	if err != nil {
+		return fmt.Errorf("creating the event for book %v: %w", change.BookID().Hex(), err)
+	}
+	if ok {
+		if err = b.publisher.Publish(ctx, b.options.Topic, newEvent); err != nil {
+			return fmt.Errorf("publishing %v for book %v: %w", newEvent.Type(), change.BookID().Hex(), err)
+		}
+		logger.Debug("event published", zap.String("eventId", newEvent.ID()), zap.String("eventType", newEvent.Type()))
+	} else {
+		logger.Debug("change ignored")
+	}
+	if err = b.tokens.Save(ctx, change.Token); err != nil {
+		return fmt.Errorf("saving the resume token: %w", err)
+	}
+	return nil
+}
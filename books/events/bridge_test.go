@@ -0,0 +1,285 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/rodolphocastro/golanghello/books"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fakeSource is a ChangeSource replaying scripted changes, resuming after a token just like a change stream.
+type fakeSource struct {
+	changes []Change
+	closed  bool
+}
+
+// Next pops the next change, io.EOF once there are none.
+func (s *fakeSource) Next(ctx context.Context) (Change, error) {
+	if err := ctx.Err(); err != nil {
+		return Change{}, err
+	}
+	if len(s.changes) == 0 {
+		return Change{}, io.EOF
+	}
+	change := s.changes[0]
+	s.changes = s.changes[1:]
+	return change, nil
+}
+
+// Close records the source was closed.
+func (s *fakeSource) Close(context.Context) error {
+	s.closed = true
+	return nil
+}
+
+// fakeOpener opens fakeSources over the same history and records the tokens it was asked to resume after.
+type fakeOpener struct {
+	history []Change
+	resumed []bson.Raw
+	sources []*fakeSource
+}
+
+// open skips whatever came up to resumeAfter.
+func (o *fakeOpener) open(_ context.Context, resumeAfter bson.Raw) (ChangeSource, error) {
+	o.resumed = append(o.resumed, resumeAfter)
+	changes := o.history
+	for idx, change := range o.history {
+		if resumeAfter != nil && string(change.Token) == string(resumeAfter) {
+			changes = o.history[idx+1:]
+		}
+	}
+	source := &fakeSource{changes: append([]Change(nil), changes...)}
+	o.sources = append(o.sources, source)
+	return source, nil
+}
+
+// fakePublisher records what was published to each topic, failing whenever failOn says so.
+type fakePublisher struct {
+	topics    []string
+	published []event.Event
+	failOn    func(event.Event) error
+}
+
+// Publish records the event unless failOn rejects it.
+func (p *fakePublisher) Publish(_ context.Context, topic string, published event.Event) error {
+	if p.failOn != nil {
+		if err := p.failOn(published); err != nil {
+			return err
+		}
+	}
+	p.topics = append(p.topics, topic)
+	p.published = append(p.published, published)
+	return nil
+}
+
+// types lists the types of the events published.
+func (p *fakePublisher) types() []string {
+	types := make([]string, len(p.published))
+	for idx, published := range p.published {
+		types[idx] = published.Type()
+	}
+	return types
+}
+
+// tokenFor creates a resume token like the ones mongodb hands out.
+func tokenFor(idx int) bson.Raw {
+	token, _ := bson.Marshal(bson.M{"_data": fmt.Sprintf("8262A5%04d", idx)})
+	return token
+}
+
+// givenABookLifecycle creates the changes of a book being inserted, updated and soft-deleted.
+func givenABookLifecycle() (books.Book, []Change) {
+	book := books.Book{ID: primitive.NewObjectID(), Title: "The Dunwich Horror", Author: "H.P. Lovecraft", Version: 1}
+	updated := book
+	updated.Title, updated.Version = "The Dunwich Horror and Others", 2
+	deleted := updated
+	deletedAt := time.Date(2022, 6, 12, 10, 0, 0, 0, time.UTC)
+	deleted.DeletedAt, deleted.Version = &deletedAt, 3
+
+	changes := []Change{
+		{Operation: OperationInsert, Book: &book},
+		{Operation: OperationUpdate, Book: &updated},
+		{Operation: OperationUpdate, Book: &deleted},
+	}
+	for idx := range changes {
+		changes[idx].Token = tokenFor(idx)
+		changes[idx].Key.ID = book.ID
+	}
+	return book, changes
+}
+
+// TestEventsAreBuiltLikeTheSeriesOnes verifies events carry the book, like createCloudEvent does with series.
+func TestEventsAreBuiltLikeTheSeriesOnes(t *testing.T) {
+	// Arrange
+	book, changes := givenABookLifecycle()
+	var got books.Book
+
+	// Act
+	created, ok, err := NewEvent(changes[0], DefaultSource)
+
+	// Assert
+	require.Nil(t, err)
+	assert.True(t, ok)
+	assert.Nil(t, created.Validate())
+	assert.Equal(t, "8262A50000", created.ID())
+	assert.Equal(t, DefaultSource, created.Source())
+	assert.Equal(t, BookCreated, created.Type())
+	assert.Equal(t, book.ID.Hex(), created.Subject())
+	assert.Equal(t, "application/json", created.DataContentType())
+	require.Nil(t, created.DataAs(&got))
+	assert.Equal(t, book, got)
+}
+
+// TestChangesBecomeTheRightEventTypes verifies soft and hard deletes are both deletions.
+func TestChangesBecomeTheRightEventTypes(t *testing.T) {
+	// Arrange
+	_, changes := givenABookLifecycle()
+	replaced := changes[1]
+	replaced.Operation = OperationReplace
+	removed := Change{Token: tokenFor(9), Operation: OperationDelete}
+	removed.Key.ID = primitive.NewObjectID()
+	scenarios := map[string]Change{
+		BookCreated: changes[0],
+		BookUpdated: replaced,
+		BookDeleted: changes[2],
+	}
+
+	for expected, change := range scenarios {
+		// Act
+		got, ok := change.EventType()
+
+		// Assert
+		assert.True(t, ok)
+		assert.Equal(t, expected, got)
+	}
+
+	deletion, ok, err := NewEvent(removed, DefaultSource)
+	var got books.Book
+	require.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, BookDeleted, deletion.Type())
+	require.Nil(t, deletion.DataAs(&got))
+	assert.Equal(t, books.Book{ID: removed.Key.ID}, got, "hard deletes only know the book's id")
+
+	_, ok, _ = NewEvent(Change{Operation: OperationInvalidate}, DefaultSource)
+	assert.False(t, ok, "invalidations aren't about books")
+}
+
+// TestChangesAreDecodedFromChangeStreamDocuments verifies the change stream's documents map into Changes.
+func TestChangesAreDecodedFromChangeStreamDocuments(t *testing.T) {
+	// Arrange
+	id := primitive.NewObjectID()
+	document, _ := bson.Marshal(bson.M{
+		"_id":           bson.M{"_data": "8262A5F00D"},
+		"operationType": "insert",
+		"documentKey":   bson.M{"_id": id},
+		"fullDocument":  bson.M{"_id": id, "title": "Dagon", "version": 1},
+		"ns":            bson.M{"db": "integration-tests", "coll": "awesomeThings"},
+	})
+	var got Change
+
+	// Act
+	err := bson.Unmarshal(document, &got)
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, OperationInsert, got.Operation)
+	assert.Equal(t, id, got.BookID())
+	assert.Equal(t, "8262A5F00D", got.EventID())
+	require.NotNil(t, got.Book)
+	assert.Equal(t, "Dagon", got.Book.Title)
+}
+
+// TestTheBridgePublishesEveryChangeToItsTopic verifies each change is published and its token saved.
+func TestTheBridgePublishesEveryChangeToItsTopic(t *testing.T) {
+	// Arrange
+	_, changes := givenABookLifecycle()
+	opener := &fakeOpener{history: append(changes, Change{Token: tokenFor(3), Operation: OperationInvalidate})}
+	publisher := &fakePublisher{}
+	tokens := &MemoryTokenStore{}
+	bridge := NewBridge(opener.open, publisher, tokens, Options{Topic: "library/books"})
+
+	// Act
+	err := bridge.Run(context.Background())
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, []string{BookCreated, BookUpdated, BookDeleted}, publisher.types())
+	assert.Equal(t, []string{"library/books", "library/books", "library/books"}, publisher.topics)
+	saved, _ := tokens.Load(context.Background())
+	assert.Equal(t, tokenFor(3), saved, "ignored changes still move the token forward")
+	assert.True(t, opener.sources[0].closed)
+}
+
+// TestTheBridgeResumesWhereItStopped verifies restarts neither lose nor repeat events.
+func TestTheBridgeResumesWhereItStopped(t *testing.T) {
+	// Arrange
+	_, changes := givenABookLifecycle()
+	opener := &fakeOpener{history: changes}
+	publisher := &fakePublisher{}
+	tokens := &MemoryTokenStore{}
+	_ = tokens.Save(context.Background(), changes[0].Token)
+
+	// Act
+	err := NewBridge(opener.open, publisher, tokens).Run(context.Background())
+	restartErr := NewBridge(opener.open, publisher, tokens).Run(context.Background())
+
+	// Assert
+	require.Nil(t, err)
+	require.Nil(t, restartErr)
+	assert.Equal(t, []bson.Raw{changes[0].Token, changes[2].Token}, opener.resumed)
+	assert.Equal(t, []string{BookUpdated, BookDeleted}, publisher.types())
+	assert.Equal(t, DefaultTopic, publisher.topics[0])
+}
+
+// TestTheBridgeDoesntSaveTokensOfUnpublishedEvents verifies a failed publish is retried after a restart, keeping its id.
+func TestTheBridgeDoesntSaveTokensOfUnpublishedEvents(t *testing.T) {
+	// Arrange
+	_, changes := givenABookLifecycle()
+	opener := &fakeOpener{history: changes}
+	brokerDown := errors.New("the broker is down")
+	failing := &fakePublisher{failOn: func(published event.Event) error {
+		if published.Type() == BookUpdated {
+			return brokerDown
+		}
+		return nil
+	}}
+	publisher := &fakePublisher{}
+	tokens := &MemoryTokenStore{}
+
+	// Act
+	err := NewBridge(opener.open, failing, tokens).Run(context.Background())
+	saved, _ := tokens.Load(context.Background())
+	restartErr := NewBridge(opener.open, publisher, tokens).Run(context.Background())
+
+	// Assert
+	assert.ErrorIs(t, err, brokerDown)
+	assert.Nil(t, restartErr)
+	assert.Equal(t, changes[0].Token, saved)
+	assert.Equal(t, []string{BookUpdated, BookDeleted}, publisher.types())
+	assert.Equal(t, changes[1].EventID(), publisher.published[0].ID())
+}
+
+// TestTheBridgeStopsWithItsContext verifies a cancelled context stops the bridge.
+func TestTheBridgeStopsWithItsContext(t *testing.T) {
+	// Arrange
+	_, changes := givenABookLifecycle()
+	opener := &fakeOpener{history: changes}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Act
+	err := NewBridge(opener.open, &fakePublisher{}, &MemoryTokenStore{}).Run(ctx)
+
+	// Assert
+	assert.ErrorIs(t, err, context.Canceled)
+}
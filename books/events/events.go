@@ -0,0 +1,102 @@
+package events
+
+import (
+	"encoding/hex"
+
+	cloudEvents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/rodolphocastro/golanghello/books"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// DefaultSource is the source of the Cloud Events.
+	DefaultSource = "github.com/rodolphocastro/hellogo"
+	// DefaultTopic is the MQTT topic events are published to.
+	DefaultTopic = "books"
+	// BookCreated is the type of the Cloud Event emitted when a book is inserted.
+	BookCreated = "book.created"
+	// BookUpdated is the type of the Cloud Event emitted when a book is changed.
+	BookUpdated = "book.updated"
+	// BookDeleted is the type of the Cloud Event emitted when a book is deleted, softly or not.
+	BookDeleted = "book.deleted"
+)
+
+// Operation is what happened to a document, as told by mongodb's change streams.
+type Operation string
+
+const (
+	OperationInsert     Operation = "insert"
+	OperationUpdate     Operation = "update"
+	OperationReplace    Operation = "replace"
+	OperationDelete     Operation = "delete"
+	OperationInvalidate Operation = "invalidate"
+)
+
+// Change is something that happened to the books collection.
+type Change struct {
+	// Token is the resume token of the change, resuming after it skips the change.
+	Token bson.Raw `bson:"_id"`
+	// Operation is what happened.
+	Operation Operation `bson:"operationType"`
+	// Key identifies the book that changed.
+	Key struct {
+		ID primitive.ObjectID `bson:"_id"`
+	} `bson:"documentKey"`
+	// Book is the book as it was after the change, nil for deletes.
+	Book *books.Book `bson:"fullDocument"`
+}
+
+// BookID tells which book changed.
+func (c Change) BookID() primitive.ObjectID {
+	return c.Key.ID
+}
+
+// EventType tells which Cloud Event a change becomes, false if it shouldn't become one.
+// Soft-deletes are updates setting deletedAt, so they become BookDeleted.
+func (c Change) EventType() (string, bool) {
+	switch c.Operation {
+	case OperationInsert:
+		return BookCreated, true
+	case OperationUpdate, OperationReplace:
+		if c.Book != nil && c.Book.DeletedAt != nil {
+			return BookDeleted, true
+		}
+		return BookUpdated, true
+	case OperationDelete:
+		return BookDeleted, true
+	}
+	return "", false
+}
+
+// EventID is the ID of the Cloud Event a change becomes. It comes from the
+// resume token, so an event published again after a restart keeps its ID and
+// consumers can drop the duplicate.
+func (c Change) EventID() string {
+	if data, ok := c.Token.Lookup("_data").StringValueOK(); ok {
+		return data
+	}
+	return hex.EncodeToString(c.Token)
+}
+
+// NewEvent creates a json CloudEvent from a change, false if the change shouldn't
+// become an event. The data is the book, or just its ID when it was removed.
+func NewEvent(change Change, source string) (event.Event, bool, error) {
+	eventType, ok := change.EventType()
+	if !ok {
+		return event.Event{}, false, nil
+	}
+	book := books.Book{ID: change.BookID()}
+	if change.Book != nil {
+		book = *change.Book
+	}
+
+	newCloudEvent := cloudEvents.NewEvent()
+	newCloudEvent.SetID(change.EventID())
+	newCloudEvent.SetSource(source)
+	newCloudEvent.SetType(eventType)
+	newCloudEvent.SetSubject(book.ID.Hex())
+	err := newCloudEvent.SetData(cloudEvents.ApplicationJSON, book)
+	return newCloudEvent, true, err
+}
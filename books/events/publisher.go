@@ -0,0 +1,41 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/eclipse/paho.mqtt.golang"
+)
+
+// Publisher publishes Cloud Events to a topic.
+type Publisher interface {
+	// Publish publishes an event, returning once it was delivered or failed to be.
+	Publish(ctx context.Context, topic string, event event.Event) error
+}
+
+// MQTTPublisher is a Publisher that publishes json Cloud Events through an MQTT client.
+type MQTTPublisher struct {
+	client mqtt.Client
+	qos    byte
+}
+
+// NewMQTTPublisher creates a Publisher for a connected MQTT client and a quality of service.
+func NewMQTTPublisher(client mqtt.Client, qos byte) *MQTTPublisher {
+	return &MQTTPublisher{client: client, qos: qos}
+}
+
+// Publish serializes the event and waits for the broker, or for ctx to be done.
+func (p *MQTTPublisher) Publish(ctx context.Context, topic string, event event.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	publishToken := p.client.Publish(topic, p.qos, false, payload)
+	select {
+	case <-publishToken.Done():
+		return publishToken.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
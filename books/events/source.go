@@ -0,0 +1,71 @@
+package events
+
+import (
+	"context"
+	"io"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ChangeSource tells what happens to the books collection.
+type ChangeSource interface {
+	// Next blocks until something changes, io.EOF is returned once there won't be more changes.
+	Next(ctx context.Context) (Change, error)
+	// Close stops watching for changes.
+	Close(ctx context.Context) error
+}
+
+// SourceOpener opens a ChangeSource, resuming after a token unless it is nil.
+type SourceOpener func(ctx context.Context, resumeAfter bson.Raw) (ChangeSource, error)
+
+// watchedOperations are the only operations the change stream asks for.
+var watchedOperations = bson.A{OperationInsert, OperationUpdate, OperationReplace, OperationDelete}
+
+// MongoChangeSource is a ChangeSource backed by a mongodb change stream, which needs a replica set.
+type MongoChangeSource struct {
+	stream *mongo.ChangeStream
+}
+
+// WatchCollection returns a SourceOpener watching a collection.
+func WatchCollection(collection *mongo.Collection) SourceOpener {
+	return func(ctx context.Context, resumeAfter bson.Raw) (ChangeSource, error) {
+		return NewMongoChangeSource(ctx, collection, resumeAfter)
+	}
+}
+
+// NewMongoChangeSource starts watching a collection, resuming after a token unless it is nil.
+func NewMongoChangeSource(ctx context.Context, collection *mongo.Collection, resumeAfter bson.Raw) (*MongoChangeSource, error) {
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{"operationType": bson.M{"$in": watchedOperations}}}}}
+	streamOptions := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if resumeAfter != nil {
+		streamOptions.SetResumeAfter(resumeAfter)
+	}
+	stream, err := collection.Watch(ctx, pipeline, streamOptions)
+	if err != nil {
+		return nil, err
+	}
+	return &MongoChangeSource{stream: stream}, nil
+}
+
+// Next decodes the next change of the stream.
+func (s *MongoChangeSource) Next(ctx context.Context) (Change, error) {
+	if !s.stream.Next(ctx) {
+		if err := s.stream.Err(); err != nil {
+			return Change{}, err
+		}
+		if err := ctx.Err(); err != nil {
+			return Change{}, err
+		}
+		return Change{}, io.EOF
+	}
+	var change Change
+	err := s.stream.Decode(&change)
+	return change, err
+}
+
+// Close closes the stream.
+func (s *MongoChangeSource) Close(ctx context.Context) error {
+	return s.stream.Close(ctx)
+}
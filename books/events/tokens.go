@@ -0,0 +1,76 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TokenStore keeps the resume token of the last change that was published.
+type TokenStore interface {
+	// Load gets the last token saved, nil if there's none.
+	Load(ctx context.Context) (bson.Raw, error)
+	// Save replaces the last token.
+	Save(ctx context.Context, token bson.Raw) error
+}
+
+// savedToken is the document a MongoTokenStore keeps.
+type savedToken struct {
+	Name    string    `bson:"_id"`
+	Token   bson.Raw  `bson:"token"`
+	SavedAt time.Time `bson:"savedAt"`
+}
+
+// MongoTokenStore is a TokenStore keeping tokens in a collection, one document per watcher name.
+type MongoTokenStore struct {
+	collection *mongo.Collection
+	name       string
+}
+
+// NewMongoTokenStore creates a TokenStore for a watcher, several watchers may share a collection.
+func NewMongoTokenStore(collection *mongo.Collection, name string) *MongoTokenStore {
+	return &MongoTokenStore{collection: collection, name: name}
+}
+
+// Load fetches the watcher's document.
+func (s *MongoTokenStore) Load(ctx context.Context) (bson.Raw, error) {
+	var saved savedToken
+	err := s.collection.FindOne(ctx, bson.M{"_id": s.name}).Decode(&saved)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	return saved.Token, err
+}
+
+// Save upserts the watcher's document.
+func (s *MongoTokenStore) Save(ctx context.Context, token bson.Raw) error {
+	saved := savedToken{Name: s.name, Token: token, SavedAt: time.Now().UTC()}
+	_, err := s.collection.ReplaceOne(ctx, bson.M{"_id": s.name}, saved, options.Replace().SetUpsert(true))
+	return err
+}
+
+// MemoryTokenStore is a TokenStore that keeps the token in memory.
+type MemoryTokenStore struct {
+	mutex sync.Mutex
+	token bson.Raw
+}
+
+// Load gets the token.
+func (s *MemoryTokenStore) Load(context.Context) (bson.Raw, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.token, nil
+}
+
+// Save replaces the token.
+func (s *MemoryTokenStore) Save(_ context.Context, token bson.Raw) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.token = token
+	return nil
+}
@@ -0,0 +1,45 @@
+package books
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/rodolphocastro/golanghello/storage"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// CSVColumns maps books into CSV columns, for storage.Export.
+var CSVColumns = []storage.Column{
+	{Header: "id", Field: "_id"},
+	{Header: "title", Field: "title"},
+	{Header: "author", Field: "author"},
+	{Header: "tags", Field: "tags"},
+	{Header: "version", Field: "version"},
+	{Header: "createdAt", Field: "createdAt"},
+	{Header: "updatedAt", Field: "updatedAt"},
+}
+
+// Import reads books exported by storage.Export and restores them into a store
+// exactly as they were exported, deleted books included, keeping their IDs,
+// versions and timestamps. Books that already exist in the store are an error.
+func Import(ctx context.Context, store Store, reader *storage.DocumentReader) (int64, error) {
+	var imported int64
+	for {
+		document, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return imported, nil
+		}
+		if err != nil {
+			return imported, err
+		}
+		var book Book
+		if err = bson.Unmarshal(document, &book); err != nil {
+			return imported, err
+		}
+		if _, err = store.Restore(ctx, book); err != nil {
+			return imported, err
+		}
+		imported++
+	}
+}
@@ -0,0 +1,136 @@
+package books
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rodolphocastro/golanghello/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// exportStore writes every book of a store the same way storage.Export would.
+func exportStore(t *testing.T, store Store, format storage.Format) *bytes.Buffer {
+	var buffer bytes.Buffer
+	writer, err := storage.NewDocumentWriter(&buffer, format, CSVColumns, true)
+	require.Nil(t, err)
+	for _, book := range listEverything(t, store, storage.PageRequest{}) {
+		document, err := bson.Marshal(book)
+		require.Nil(t, err)
+		require.Nil(t, writer.Write(document))
+	}
+	require.Nil(t, writer.Flush())
+	return &buffer
+}
+
+// TestExportedBooksRoundTripThroughTheImporter verifies exports can be imported back into a store.
+func TestExportedBooksRoundTripThroughTheImporter(t *testing.T) {
+	scenarios := []storage.Format{storage.FormatJSONL, storage.FormatBSON}
+	original := givenAStoreWithBooks(t, 12)
+	expected := listEverything(t, original, storage.PageRequest{})
+
+	for _, format := range scenarios {
+		// Arrange
+		restored := NewMemoryStore()
+		reader, err := storage.NewDocumentReader(exportStore(t, original, format), format)
+		require.Nil(t, err)
+
+		// Act
+		imported, err := Import(context.Background(), restored, reader)
+
+		// Assert
+		require.Nil(t, err, "importing %v should work", format)
+		assert.EqualValues(t, len(expected), imported)
+		got := listEverything(t, restored, storage.PageRequest{})
+		require.Len(t, got, len(expected))
+		for idx := range expected {
+			assert.Equal(t, expected[idx].ID, got[idx].ID)
+			assert.Equal(t, expected[idx].Title, got[idx].Title)
+			assert.Equal(t, expected[idx].Author, got[idx].Author)
+			assert.Equal(t, expected[idx].Tags, got[idx].Tags)
+		}
+	}
+}
+
+// TestBooksCanBeExportedAsCSV verifies the CSV column mapping of books.
+func TestBooksCanBeExportedAsCSV(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	book, _ := store.Insert(context.Background(), Book{Title: "Dagon", Author: "H.P. Lovecraft", Tags: []string{"Horror", "Lovecraftian"}})
+
+	// Act
+	got := exportStore(t, store, storage.FormatCSV).String()
+
+	// Assert
+	assert.Contains(t, got, "id,title,author,tags,version,createdAt,updatedAt\n")
+	assert.Contains(t, got, book.ID.Hex()+",Dagon,H.P. Lovecraft,Horror;Lovecraftian,1,")
+}
+
+// TestImportingTwiceConflicts verifies the importer doesn't silently duplicate books.
+func TestImportingTwiceConflicts(t *testing.T) {
+	// Arrange
+	original := givenAStoreWithBooks(t, 2)
+	export := exportStore(t, original, storage.FormatJSONL).Bytes()
+	reader, _ := storage.NewDocumentReader(bytes.NewReader(export), storage.FormatJSONL)
+
+	// Act
+	imported, err := Import(context.Background(), original, reader)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Zero(t, imported)
+}
+
+// TestImportRestoresBooksAsTheyWere verifies deleted and updated books keep their versions, timestamps and deletion.
+func TestImportRestoresBooksAsTheyWere(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	original, clock := givenAStoreWithAClock()
+	updated, err := original.Insert(ctx, Book{Title: "Dagon", Author: "H.P. Lovecraft", Tags: []string{"Horror"}})
+	require.Nil(t, err)
+	clock.now = clock.now.Add(time.Hour)
+	updated.Title = "Dagon and Other Stories"
+	updated, err = original.Update(ctx, updated)
+	require.Nil(t, err)
+	deleted, err := original.Insert(ctx, Book{Title: "The Shadow over Innsmouth"})
+	require.Nil(t, err)
+	clock.now = clock.now.Add(time.Hour)
+	require.Nil(t, original.Delete(ctx, deleted.ID, deleted.Version))
+	deleted, err = original.GetDeleted(ctx, deleted.ID)
+	require.Nil(t, err)
+
+	var export bytes.Buffer
+	writer, err := storage.NewDocumentWriter(&export, storage.FormatJSONL, nil, false)
+	require.Nil(t, err)
+	for _, book := range []Book{updated, deleted} {
+		document, err := bson.Marshal(book)
+		require.Nil(t, err)
+		require.Nil(t, writer.Write(document))
+	}
+	require.Nil(t, writer.Flush())
+	reader, err := storage.NewDocumentReader(&export, storage.FormatJSONL)
+	require.Nil(t, err)
+	restored := NewMemoryStore(Options{Retention: 24 * time.Hour, Now: clock.Now})
+
+	// Act
+	imported, err := Import(ctx, restored, reader)
+
+	// Assert
+	require.Nil(t, err)
+	assert.EqualValues(t, 2, imported)
+	got, err := restored.Get(ctx, updated.ID)
+	require.Nil(t, err)
+	assert.Equal(t, updated, got)
+	assert.EqualValues(t, 2, got.Version)
+	_, err = restored.Get(ctx, deleted.ID)
+	assert.ErrorIs(t, err, ErrNotFound, "deleted books stay deleted")
+	got, err = restored.GetDeleted(ctx, deleted.ID)
+	require.Nil(t, err)
+	assert.Equal(t, deleted, got)
+	history, err := restored.History(ctx, updated.ID)
+	require.Nil(t, err)
+	assert.Empty(t, history, "restoring isn't a change")
+}
@@ -0,0 +1,448 @@
+package books
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rodolphocastro/golanghello/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SortParam is the query parameter sorting the results, a leading "-" sorts them descending.
+const SortParam = "sort"
+
+// TagSeparator separates the tags of a tags filter.
+const TagSeparator = ","
+
+// Operator compares a book's field with the value of a filter.
+type Operator string
+
+const (
+	OpEq     Operator = "eq"
+	OpNe     Operator = "ne"
+	OpGt     Operator = "gt"
+	OpGte    Operator = "gte"
+	OpLt     Operator = "lt"
+	OpLte    Operator = "lte"
+	OpPrefix Operator = "prefix"
+	// OpAll matches books with every tag.
+	OpAll Operator = "all"
+	// OpAny matches books with at least one of the tags.
+	OpAny Operator = "any"
+)
+
+// fieldKind is the type of a field that can be filtered.
+type fieldKind int
+
+const (
+	textField fieldKind = iota
+	tagsField
+	numberField
+	timeField
+	idField
+)
+
+// queryField describes a field that can be filtered.
+type queryField struct {
+	kind      fieldKind
+	bsonName  string
+	operators []Operator
+	sortable  bool
+}
+
+// comparisons are the operators of fields that can be ordered.
+var comparisons = []Operator{OpEq, OpNe, OpGt, OpGte, OpLt, OpLte}
+
+// queryFields are the fields a Query can filter, by their name in query strings.
+// The first operator of each field is used when a filter has none.
+var queryFields = map[string]queryField{
+	"id":        {kind: idField, bsonName: storage.IDField, operators: []Operator{OpEq, OpNe}, sortable: true},
+	"title":     {kind: textField, bsonName: "title", operators: []Operator{OpEq, OpNe, OpPrefix}, sortable: true},
+	"author":    {kind: textField, bsonName: "author", operators: []Operator{OpEq, OpNe, OpPrefix}, sortable: true},
+	"tags":      {kind: tagsField, bsonName: "tags", operators: []Operator{OpAll, OpAny}},
+	"version":   {kind: numberField, bsonName: "version", operators: comparisons},
+	"createdAt": {kind: timeField, bsonName: "createdAt", operators: comparisons, sortable: true},
+	"updatedAt": {kind: timeField, bsonName: "updatedAt", operators: comparisons, sortable: true},
+}
+
+// QueryError describes what is wrong with a parameter of a query string.
+type QueryError struct {
+	Param  string
+	Reason string
+}
+
+// Error describes the parameter and its problem.
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("invalid query parameter %q: %v", e.Param, e.Reason)
+}
+
+// Condition is a single filter of a Query.
+type Condition struct {
+	// Field is the name of the field in query strings.
+	Field    string
+	Operator Operator
+	// Value is a string, []string, int64, time.Time or primitive.ObjectID, depending on the field.
+	Value interface{}
+}
+
+// Sort tells how the results of a Query are sorted.
+type Sort struct {
+	// Field is the name of the field in query strings.
+	Field      string
+	Descending bool
+}
+
+// Query is a set of conditions over books, all of which must match. Queries
+// from ParseQuery are valid, hand-built ones are checked by Validate.
+type Query struct {
+	Conditions []Condition
+	// Sort is nil when the query doesn't care about the order.
+	Sort *Sort
+}
+
+// ParseQuery parses a query string such as
+// "author=H.P. Lovecraft&tags=Horror,Lovecraftian&sort=-title".
+//
+// Each parameter is a field, optionally followed by an operator within
+// brackets (title[prefix]=The, createdAt[gte]=2022-06-01). Tags are separated
+// by commas and, by default, books need every tag. Times are RFC 3339 times or
+// dates. Repeated parameters must all match.
+func ParseQuery(raw string) (Query, error) {
+	values, err := url.ParseQuery(strings.TrimPrefix(raw, "?"))
+	if err != nil {
+		return Query{}, &QueryError{Param: raw, Reason: err.Error()}
+	}
+	return QueryFromValues(values)
+}
+
+// QueryFromValues parses already decoded query parameters, as found in http.Request.URL.Query().
+func QueryFromValues(values url.Values) (Query, error) {
+	var query Query
+	params := make([]string, 0, len(values))
+	for param := range values {
+		params = append(params, param)
+	}
+	sort.Strings(params)
+
+	for _, param := range params {
+		if param == SortParam {
+			if len(values[param]) != 1 {
+				return Query{}, &QueryError{Param: param, Reason: "results can only be sorted once"}
+			}
+			sorting, err := parseSort(values[param][0])
+			if err != nil {
+				return Query{}, err
+			}
+			query.Sort = &sorting
+			continue
+		}
+		for _, value := range values[param] {
+			condition, err := parseCondition(param, value)
+			if err != nil {
+				return Query{}, err
+			}
+			query.Conditions = append(query.Conditions, condition)
+		}
+	}
+	return query, nil
+}
+
+// parseSort parses the value of the sort parameter.
+func parseSort(value string) (Sort, error) {
+	sorting := Sort{Field: strings.TrimPrefix(value, "-"), Descending: strings.HasPrefix(value, "-")}
+	field, known := queryFields[sorting.Field]
+	if !known || !field.sortable {
+		return Sort{}, &QueryError{Param: SortParam, Reason: fmt.Sprintf("books can't be sorted by %q, use one of %v", sorting.Field, sortableFields())}
+	}
+	return sorting, nil
+}
+
+// parseCondition parses a single parameter into a Condition.
+func parseCondition(param, value string) (Condition, error) {
+	name, operator, err := splitParam(param)
+	if err != nil {
+		return Condition{}, err
+	}
+	if field, known := queryFields[name]; known && operator == "" {
+		operator = field.operators[0]
+	}
+	field, err := lookupField(param, name, operator)
+	if err != nil {
+		return Condition{}, err
+	}
+	if strings.TrimSpace(value) == "" {
+		return Condition{}, &QueryError{Param: param, Reason: "a value is required"}
+	}
+
+	parsed, err := parseValue(field.kind, value)
+	if err != nil {
+		return Condition{}, &QueryError{Param: param, Reason: err.Error()}
+	}
+	return Condition{Field: name, Operator: operator, Value: parsed}, nil
+}
+
+// lookupField finds the field of a condition, checking it can be filtered with the operator.
+func lookupField(param, name string, operator Operator) (queryField, error) {
+	field, known := queryFields[name]
+	if !known {
+		return queryField{}, &QueryError{Param: param, Reason: fmt.Sprintf("unknown field %q, use one of %v", name, knownFields())}
+	}
+	if !hasOperator(field.operators, operator) {
+		return queryField{}, &QueryError{Param: param, Reason: fmt.Sprintf("%v can't be filtered with %q, use one of %v", name, operator, field.operators)}
+	}
+	return field, nil
+}
+
+// splitParam splits a parameter such as "title[prefix]" into its field and operator.
+func splitParam(param string) (string, Operator, error) {
+	open := strings.IndexByte(param, '[')
+	if open < 0 {
+		if strings.ContainsRune(param, ']') {
+			return "", "", &QueryError{Param: param, Reason: "operators must be written as field[operator]"}
+		}
+		return param, "", nil
+	}
+	if !strings.HasSuffix(param, "]") || open == 0 || strings.Count(param, "[") != 1 || strings.Count(param, "]") != 1 {
+		return "", "", &QueryError{Param: param, Reason: "operators must be written as field[operator]"}
+	}
+	operator := param[open+1 : len(param)-1]
+	if operator == "" {
+		return "", "", &QueryError{Param: param, Reason: "the operator is missing between the brackets"}
+	}
+	return param[:open], Operator(operator), nil
+}
+
+// parseValue parses the value of a parameter according to its field.
+func parseValue(kind fieldKind, value string) (interface{}, error) {
+	switch kind {
+	case tagsField:
+		var tags []string
+		for _, tag := range strings.Split(value, TagSeparator) {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+		if len(tags) == 0 {
+			return nil, fmt.Errorf("%q has no tags", value)
+		}
+		return tags, nil
+	case numberField:
+		number, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q isn't a whole number", value)
+		}
+		return number, nil
+	case timeField:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+			if at, err := time.Parse(layout, value); err == nil {
+				return at.UTC(), nil
+			}
+		}
+		return nil, fmt.Errorf("%q isn't an RFC 3339 time nor a date", value)
+	case idField:
+		id, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q isn't a book id", value)
+		}
+		return id, nil
+	}
+	return value, nil
+}
+
+// hasOperator checks if an operator is within a list.
+func hasOperator(operators []Operator, operator Operator) bool {
+	for _, candidate := range operators {
+		if candidate == operator {
+			return true
+		}
+	}
+	return false
+}
+
+// knownFields lists the fields that can be filtered.
+func knownFields() []string {
+	fields := make([]string, 0, len(queryFields))
+	for name := range queryFields {
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// sortableFields lists the fields results can be sorted by.
+func sortableFields() []string {
+	var fields []string
+	for _, name := range knownFields() {
+		if queryFields[name].sortable {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// Validate checks the fields, operators and values of every condition, and the
+// sort, as ParseQuery would have. Both stores validate queries before searching.
+func (q Query) Validate() error {
+	for _, condition := range q.Conditions {
+		if err := condition.validate(); err != nil {
+			return err
+		}
+	}
+	if q.Sort != nil {
+		field, known := queryFields[q.Sort.Field]
+		if !known || !field.sortable {
+			return &QueryError{Param: SortParam, Reason: fmt.Sprintf("books can't be sorted by %q, use one of %v", q.Sort.Field, sortableFields())}
+		}
+	}
+	return nil
+}
+
+// validate checks a condition has a known field, one of its operators and a value of the field's type.
+func (c Condition) validate() error {
+	param := fmt.Sprintf("%v[%v]", c.Field, c.Operator)
+	field, err := lookupField(param, c.Field, c.Operator)
+	if err != nil {
+		return err
+	}
+	var valid bool
+	switch value := c.Value.(type) {
+	case string:
+		valid = field.kind == textField
+	case []string:
+		valid = field.kind == tagsField && len(value) != 0
+	case int64:
+		valid = field.kind == numberField
+	case time.Time:
+		valid = field.kind == timeField
+	case primitive.ObjectID:
+		valid = field.kind == idField
+	}
+	if !valid {
+		return &QueryError{Param: param, Reason: fmt.Sprintf("%#v isn't a value %v can be filtered by", c.Value, c.Field)}
+	}
+	return nil
+}
+
+// Filter compiles the query into a mongodb filter.
+func (q Query) Filter() bson.M {
+	filters := make(bson.A, len(q.Conditions))
+	for idx, condition := range q.Conditions {
+		filters[idx] = condition.filter()
+	}
+	switch len(filters) {
+	case 0:
+		return bson.M{}
+	case 1:
+		return filters[0].(bson.M)
+	}
+	return bson.M{"$and": filters}
+}
+
+// filter compiles a condition into a mongodb filter.
+func (c Condition) filter() bson.M {
+	field := queryFields[c.Field].bsonName
+	switch c.Operator {
+	case OpEq:
+		return bson.M{field: c.Value}
+	case OpPrefix:
+		return bson.M{field: bson.M{"$regex": "^" + regexp.QuoteMeta(c.Value.(string))}}
+	case OpAll:
+		return bson.M{field: bson.M{"$all": c.Value}}
+	case OpAny:
+		return bson.M{field: bson.M{"$in": c.Value}}
+	}
+	return bson.M{field: bson.M{"$" + string(c.Operator): c.Value}}
+}
+
+// Matches is the in-memory counterpart of Filter, it checks if a book matches every condition.
+func (q Query) Matches(book Book) bool {
+	for _, condition := range q.Conditions {
+		if !condition.matches(book) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches checks if a book matches a condition.
+func (c Condition) matches(book Book) bool {
+	switch c.Operator {
+	case OpPrefix:
+		return strings.HasPrefix(fieldValue(book, c.Field).(string), c.Value.(string))
+	case OpAll:
+		for _, tag := range c.Value.([]string) {
+			if !hasTag(book.Tags, tag) {
+				return false
+			}
+		}
+		return true
+	case OpAny:
+		for _, tag := range c.Value.([]string) {
+			if hasTag(book.Tags, tag) {
+				return true
+			}
+		}
+		return false
+	}
+
+	result := compareValues(fieldValue(book, c.Field), c.Value)
+	switch c.Operator {
+	case OpNe:
+		return result != 0
+	case OpGt:
+		return result > 0
+	case OpGte:
+		return result >= 0
+	case OpLt:
+		return result < 0
+	case OpLte:
+		return result <= 0
+	}
+	return result == 0
+}
+
+// fieldValue gets the value of a book's field by its name in query strings.
+func fieldValue(book Book, field string) interface{} {
+	switch field {
+	case "id":
+		return book.ID
+	case "title":
+		return book.Title
+	case "author":
+		return book.Author
+	case "tags":
+		return book.Tags
+	case "version":
+		return book.Version
+	case "createdAt":
+		return book.CreatedAt
+	case "updatedAt":
+		return book.UpdatedAt
+	}
+	return nil
+}
+
+// hasTag checks if a tag is within a list.
+func hasTag(tags []string, tag string) bool {
+	for _, candidate := range tags {
+		if candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// PageRequest applies the query's sort, if any, to a page request.
+func (q Query) PageRequest(request storage.PageRequest) storage.PageRequest {
+	if q.Sort != nil {
+		request.SortBy = queryFields[q.Sort.Field].bsonName
+		request.Descending = q.Sort.Descending
+	}
+	return request
+}
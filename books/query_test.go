@@ -0,0 +1,195 @@
+package books
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rodolphocastro/golanghello/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// givenALibrary creates a MemoryStore with books from a few authors.
+func givenALibrary(t *testing.T) *MemoryStore {
+	store := NewMemoryStore()
+	library := []Book{
+		{Title: "The Call of Cthulhu", Author: "H.P. Lovecraft", Tags: []string{"Horror", "Lovecraftian"}},
+		{Title: "The Dunwich Horror", Author: "H.P. Lovecraft", Tags: []string{"Horror", "Lovecraftian", "Rural"}},
+		{Title: "At the Mountains of Madness", Author: "H.P. Lovecraft", Tags: []string{"Horror", "Antarctica"}},
+		{Title: "The King in Yellow", Author: "Robert W. Chambers", Tags: []string{"Horror", "Lovecraftian"}},
+		{Title: "The Hound of the Baskervilles", Author: "Arthur Conan Doyle", Tags: []string{"Mystery"}},
+	}
+	for _, book := range library {
+		_, err := store.Insert(context.Background(), book)
+		require.Nil(t, err)
+	}
+	return store
+}
+
+// titlesOf lists the titles of some books.
+func titlesOf(books []Book) []string {
+	titles := make([]string, len(books))
+	for idx, book := range books {
+		titles[idx] = book.Title
+	}
+	return titles
+}
+
+// TestQueriesAreParsedFromQueryStrings verifies the example of the filter language.
+func TestQueriesAreParsedFromQueryStrings(t *testing.T) {
+	// Act
+	got, err := ParseQuery("?author=H.P. Lovecraft&tags=Horror,Lovecraftian&sort=-title")
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, Query{
+		Conditions: []Condition{
+			{Field: "author", Operator: OpEq, Value: "H.P. Lovecraft"},
+			{Field: "tags", Operator: OpAll, Value: []string{"Horror", "Lovecraftian"}},
+		},
+		Sort: &Sort{Field: "title", Descending: true},
+	}, got)
+	assert.Equal(t, bson.M{"$and": bson.A{
+		bson.M{"author": "H.P. Lovecraft"},
+		bson.M{"tags": bson.M{"$all": []string{"Horror", "Lovecraftian"}}},
+	}}, got.Filter())
+	assert.Equal(t, storage.PageRequest{Size: 5, SortBy: "title", Descending: true}, got.PageRequest(storage.PageRequest{Size: 5}))
+}
+
+// TestOperatorsCompileIntoMongoFilters verifies each operator's mongodb filter.
+func TestOperatorsCompileIntoMongoFilters(t *testing.T) {
+	id, _ := primitive.ObjectIDFromHex("62a5f00d0000000000000000")
+	scenarios := map[string]bson.M{
+		"title[prefix]=The (Old)":         {"title": bson.M{"$regex": `^The \(Old\)`}},
+		"author[ne]=H.P. Lovecraft":       {"author": bson.M{"$ne": "H.P. Lovecraft"}},
+		"tags[any]=Horror, Mystery":       {"tags": bson.M{"$in": []string{"Horror", "Mystery"}}},
+		"version[gte]=2":                  {"version": bson.M{"$gte": int64(2)}},
+		"createdAt[lt]=2022-06-12":        {"createdAt": bson.M{"$lt": time.Date(2022, 6, 12, 0, 0, 0, 0, time.UTC)}},
+		"updatedAt=2022-06-12T10:00:00Z":  {"updatedAt": time.Date(2022, 6, 12, 10, 0, 0, 0, time.UTC)},
+		"id[ne]=62a5f00d0000000000000000": {"_id": bson.M{"$ne": id}},
+		"":                                {},
+		"sort=createdAt":                  {},
+	}
+
+	for raw, expected := range scenarios {
+		// Act
+		got, err := ParseQuery(raw)
+
+		// Assert
+		require.Nil(t, err, raw)
+		assert.Equal(t, expected, got.Filter(), raw)
+	}
+}
+
+// TestBadQueriesHaveDescriptiveErrors verifies what's wrong is told back.
+func TestBadQueriesHaveDescriptiveErrors(t *testing.T) {
+	scenarios := map[string]string{
+		"isbn=123":                `invalid query parameter "isbn": unknown field "isbn", use one of [author createdAt id tags title updatedAt version]`,
+		"title[gt]=The":           `invalid query parameter "title[gt]": title can't be filtered with "gt", use one of [eq ne prefix]`,
+		"title[prefix=The":        `invalid query parameter "title[prefix": operators must be written as field[operator]`,
+		"title[]=The":             `invalid query parameter "title[]": the operator is missing between the brackets`,
+		"title]=The":              `invalid query parameter "title]": operators must be written as field[operator]`,
+		"author=":                 `invalid query parameter "author": a value is required`,
+		"tags=,,":                 `invalid query parameter "tags": ",," has no tags`,
+		"version=two":             `invalid query parameter "version": "two" isn't a whole number`,
+		"createdAt[gt]=yesterday": `invalid query parameter "createdAt[gt]": "yesterday" isn't an RFC 3339 time nor a date`,
+		"id=42":                   `invalid query parameter "id": "42" isn't a book id`,
+		"sort=-tags":              `invalid query parameter "sort": books can't be sorted by "tags", use one of [author createdAt id title updatedAt]`,
+		"sort=title&sort=author":  `invalid query parameter "sort": results can only be sorted once`,
+		"title=%zz":               `invalid query parameter "title=%zz": invalid URL escape "%zz"`,
+	}
+
+	for raw, expected := range scenarios {
+		// Act
+		_, err := ParseQuery(raw)
+
+		// Assert
+		var queryErr *QueryError
+		require.True(t, errors.As(err, &queryErr), raw)
+		assert.EqualError(t, err, expected)
+	}
+}
+
+// TestQueriesFilterTheMemoryStore verifies the in-memory predicate agrees with the mongodb filters.
+func TestQueriesFilterTheMemoryStore(t *testing.T) {
+	scenarios := map[string][]string{
+		"author=H.P. Lovecraft&tags=Horror,Lovecraftian&sort=-title": {"The Dunwich Horror", "The Call of Cthulhu"},
+		"tags[any]=Rural,Mystery&sort=title":                         {"The Dunwich Horror", "The Hound of the Baskervilles"},
+		"title[prefix]=The&author[ne]=H.P. Lovecraft&sort=author":    {"The Hound of the Baskervilles", "The King in Yellow"},
+		"tags=Horror&tags=Antarctica":                                {"At the Mountains of Madness"},
+		"version[gt]=1":                                              {},
+	}
+	store := givenALibrary(t)
+
+	for raw, expected := range scenarios {
+		// Arrange
+		query, err := ParseQuery(raw)
+		require.Nil(t, err, raw)
+
+		// Act
+		page, err := store.Search(context.Background(), query, storage.PageRequest{})
+
+		// Assert
+		require.Nil(t, err, raw)
+		assert.Equal(t, expected, titlesOf(page.Items), raw)
+	}
+}
+
+// TestHandBuiltQueriesAreValidated verifies the memory store refuses conditions and sorts ParseQuery would have refused.
+func TestHandBuiltQueriesAreValidated(t *testing.T) {
+	scenarios := map[string]Query{
+		`invalid query parameter "publisher[eq]": unknown field "publisher", use one of [author createdAt id tags title updatedAt version]`: {
+			Conditions: []Condition{{Field: "publisher", Operator: OpEq, Value: "Arkham House"}},
+		},
+		`invalid query parameter "title[all]": title can't be filtered with "all", use one of [eq ne prefix]`: {
+			Conditions: []Condition{{Field: "title", Operator: OpAll, Value: []string{"Dagon"}}},
+		},
+		`invalid query parameter "title[prefix]": 42 isn't a value title can be filtered by`: {
+			Conditions: []Condition{{Field: "title", Operator: OpPrefix, Value: 42}},
+		},
+		`invalid query parameter "tags[any]": []string(nil) isn't a value tags can be filtered by`: {
+			Conditions: []Condition{{Field: "tags", Operator: OpAny, Value: []string(nil)}},
+		},
+		`invalid query parameter "version[gt]": 1 isn't a value version can be filtered by`: {
+			Conditions: []Condition{{Field: "version", Operator: OpGt, Value: 1}},
+		},
+		`invalid query parameter "sort": books can't be sorted by "tags", use one of [author createdAt id title updatedAt]`: {
+			Sort: &Sort{Field: "tags"},
+		},
+	}
+	store := givenALibrary(t)
+
+	for expected, query := range scenarios {
+		// Act
+		_, err := store.Search(context.Background(), query, storage.PageRequest{})
+
+		// Assert
+		var queryErr *QueryError
+		require.True(t, errors.As(err, &queryErr), expected)
+		assert.EqualError(t, err, expected)
+	}
+}
+
+// TestQueriesCompareTimes verifies time conditions against the memory store's clock.
+func TestQueriesCompareTimes(t *testing.T) {
+	// Arrange
+	store, clock := givenAStoreWithAClock()
+	for _, title := range []string{"Dagon", "The Temple"} {
+		_, err := store.Insert(context.Background(), Book{Title: title})
+		require.Nil(t, err)
+		clock.now = clock.now.Add(48 * time.Hour)
+	}
+	query, err := ParseQuery("createdAt[gte]=" + clock.now.Add(-72*time.Hour).Format(time.RFC3339))
+	require.Nil(t, err)
+
+	// Act
+	page, err := store.Search(context.Background(), query, storage.PageRequest{})
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, []string{"The Temple"}, titlesOf(page.Items))
+}
@@ -0,0 +1,66 @@
+package reporting
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// Row is a report row that can be written as CSV.
+type Row interface {
+	// CSVHeader names the columns of the row.
+	CSVHeader() []string
+	// CSVRecord lists the row's values, in the same order as its header.
+	CSVRecord() []string
+}
+
+// CSVHeader names the columns of the row.
+func (c AuthorCount) CSVHeader() []string { return []string{"author", "books"} }
+
+// CSVRecord lists the row's values.
+func (c AuthorCount) CSVRecord() []string {
+	return []string{c.Author, strconv.FormatInt(c.Books, 10)}
+}
+
+// CSVHeader names the columns of the row.
+func (c TagCount) CSVHeader() []string { return []string{"tag", "books"} }
+
+// CSVRecord lists the row's values.
+func (c TagCount) CSVRecord() []string {
+	return []string{c.Tag, strconv.FormatInt(c.Books, 10)}
+}
+
+// CSVHeader names the columns of the row.
+func (c DailyCount) CSVHeader() []string { return []string{"day", "books"} }
+
+// CSVRecord lists the row's values.
+func (c DailyCount) CSVRecord() []string {
+	return []string{c.Day, strconv.FormatInt(c.Books, 10)}
+}
+
+// WriteCSV writes a report as CSV, with a header line even when it has no rows.
+func WriteCSV[T Row](w io.Writer, rows []T) error {
+	var zero T
+	writer := csv.NewWriter(w)
+	if err := writer.Write(zero.CSVHeader()); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := writer.Write(row.CSVRecord()); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteJSON writes a report as a JSON array, an empty report is an empty array.
+func WriteJSON[T any](w io.Writer, rows []T) error {
+	if rows == nil {
+		rows = []T{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(rows)
+}
@@ -0,0 +1,68 @@
+package reporting
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWriteCSVWritesAHeaderAndEveryRow verifies the CSV export.
+func TestWriteCSVWritesAHeaderAndEveryRow(t *testing.T) {
+	// Arrange
+	var got bytes.Buffer
+	rows := []AuthorCount{{Author: "H.P. Lovecraft", Books: 2}, {Author: "King, Stephen", Books: 1}}
+
+	// Act
+	err := WriteCSV(&got, rows)
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, "author,books\nH.P. Lovecraft,2\n\"King, Stephen\",1\n", got.String())
+}
+
+// TestWriteCSVOfAnEmptyReportStillHasAHeader verifies empty reports keep their columns.
+func TestWriteCSVOfAnEmptyReportStillHasAHeader(t *testing.T) {
+	// Arrange
+	var got bytes.Buffer
+
+	// Act
+	err := WriteCSV[DailyCount](&got, nil)
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, "day,books\n", got.String())
+}
+
+// TestWriteJSONRoundTrips verifies the JSON export can be read back.
+func TestWriteJSONRoundTrips(t *testing.T) {
+	// Arrange
+	var buffer bytes.Buffer
+	expected := []TagCount{{Tag: "Horror", Books: 4}, {Tag: "Fantasy", Books: 1}}
+	var got []TagCount
+
+	// Act
+	err := WriteJSON(&buffer, expected)
+	require.Nil(t, err)
+	err = json.Unmarshal(buffer.Bytes(), &got)
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, expected, got)
+	assert.Contains(t, buffer.String(), `"tag": "Horror"`)
+}
+
+// TestWriteJSONOfAnEmptyReportIsAnEmptyArray verifies empty reports aren't written as null.
+func TestWriteJSONOfAnEmptyReportIsAnEmptyArray(t *testing.T) {
+	// Arrange
+	var got bytes.Buffer
+
+	// Act
+	err := WriteJSON[TagCount](&got, nil)
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, "[]\n", got.String())
+}
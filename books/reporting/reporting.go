@@ -0,0 +1,216 @@
+// Package reporting builds reports about the books we store, either through
+// mongodb aggregation pipelines or in memory.
+package reporting
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/rodolphocastro/golanghello/books"
+	"github.com/rodolphocastro/golanghello/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DayLayout is how days are written in DailyCount.
+const DayLayout = "2006-01-02"
+
+// AuthorCount is how many books an author has.
+type AuthorCount struct {
+	Author string `bson:"_id" json:"author"`
+	Books  int64  `bson:"books" json:"books"`
+}
+
+// TagCount is how many books carry a tag.
+type TagCount struct {
+	Tag   string `bson:"_id" json:"tag"`
+	Books int64  `bson:"books" json:"books"`
+}
+
+// DailyCount is how many books were added on a day, days are in UTC.
+type DailyCount struct {
+	Day   string `bson:"_id" json:"day"`
+	Books int64  `bson:"books" json:"books"`
+}
+
+// Reporter builds reports about books, deleted books are never counted.
+type Reporter interface {
+	// BooksPerAuthor counts books by author, the most prolific authors first.
+	BooksPerAuthor(ctx context.Context) ([]AuthorCount, error)
+	// TagFrequency counts books by tag, the most used tags first.
+	TagFrequency(ctx context.Context) ([]TagCount, error)
+	// RecentlyAdded counts books added per day since a moment, oldest days first.
+	RecentlyAdded(ctx context.Context, since time.Time) ([]DailyCount, error)
+}
+
+// notDeleted matches books that weren't soft-deleted.
+var notDeleted = bson.D{{Key: "$match", Value: bson.M{"deletedAt": bson.M{"$exists": false}}}}
+
+// countAndSort groups documents by an expression and sorts them by how many there are, then by the expression.
+func countAndSort(groupBy interface{}) mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": groupBy, "books": bson.M{"$sum": 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "books", Value: -1}, {Key: "_id", Value: 1}}}},
+	}
+}
+
+// BooksPerAuthorPipeline is the pipeline behind BooksPerAuthor.
+func BooksPerAuthorPipeline() mongo.Pipeline {
+	return append(mongo.Pipeline{notDeleted}, countAndSort("$author")...)
+}
+
+// TagFrequencyPipeline is the pipeline behind TagFrequency.
+func TagFrequencyPipeline() mongo.Pipeline {
+	return append(mongo.Pipeline{
+		notDeleted,
+		{{Key: "$unwind", Value: "$tags"}},
+	}, countAndSort("$tags")...)
+}
+
+// RecentlyAddedPipeline is the pipeline behind RecentlyAdded.
+func RecentlyAddedPipeline(since time.Time) mongo.Pipeline {
+	return mongo.Pipeline{
+		notDeleted,
+		{{Key: "$match", Value: bson.M{"createdAt": bson.M{"$gte": since}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$createdAt", "timezone": "UTC"}},
+			"books": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+	}
+}
+
+// MongoReporter builds reports with aggregation pipelines over a books collection.
+type MongoReporter struct {
+	collection *mongo.Collection
+}
+
+// NewMongoReporter creates a Reporter over a books collection.
+func NewMongoReporter(collection *mongo.Collection) *MongoReporter {
+	return &MongoReporter{collection: collection}
+}
+
+// aggregate runs a pipeline and decodes every result.
+func aggregate[T any](ctx context.Context, collection *mongo.Collection, pipeline mongo.Pipeline) ([]T, error) {
+	results, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]T, 0)
+	err = results.All(ctx, &rows)
+	return rows, err
+}
+
+// BooksPerAuthor counts books by author, the most prolific authors first.
+func (r *MongoReporter) BooksPerAuthor(ctx context.Context) ([]AuthorCount, error) {
+	return aggregate[AuthorCount](ctx, r.collection, BooksPerAuthorPipeline())
+}
+
+// TagFrequency counts books by tag, the most used tags first.
+func (r *MongoReporter) TagFrequency(ctx context.Context) ([]TagCount, error) {
+	return aggregate[TagCount](ctx, r.collection, TagFrequencyPipeline())
+}
+
+// RecentlyAdded counts books added per day since a moment, oldest days first.
+func (r *MongoReporter) RecentlyAdded(ctx context.Context, since time.Time) ([]DailyCount, error) {
+	return aggregate[DailyCount](ctx, r.collection, RecentlyAddedPipeline(since))
+}
+
+// MemoryReporter builds the same reports as MongoReporter, but in memory, by
+// walking every page of a books.Store. It is the reporter for the MemoryStore.
+type MemoryReporter struct {
+	store books.Store
+}
+
+// NewMemoryReporter creates a Reporter over any books.Store.
+func NewMemoryReporter(store books.Store) *MemoryReporter {
+	return &MemoryReporter{store: store}
+}
+
+// all fetches every book in the store.
+func (r *MemoryReporter) all(ctx context.Context) ([]books.Book, error) {
+	var all []books.Book
+	request := storage.PageRequest{Size: storage.MaxPageSize}
+	for {
+		page, err := r.store.List(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page.Items...)
+		if page.NextToken == "" {
+			return all, nil
+		}
+		request.Token = page.NextToken
+	}
+}
+
+// sortedCounts turns a map of counts into rows sorted by count, then by key.
+func sortedCounts[T any](counts map[string]int64, row func(key string, count int64) T) []T {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	rows := make([]T, 0, len(keys))
+	for _, key := range keys {
+		rows = append(rows, row(key, counts[key]))
+	}
+	return rows
+}
+
+// BooksPerAuthor counts books by author, the most prolific authors first.
+func (r *MemoryReporter) BooksPerAuthor(ctx context.Context) ([]AuthorCount, error) {
+	all, err := r.all(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int64{}
+	for _, book := range all {
+		counts[book.Author]++
+	}
+	return sortedCounts(counts, func(author string, count int64) AuthorCount {
+		return AuthorCount{Author: author, Books: count}
+	}), nil
+}
+
+// TagFrequency counts books by tag, the most used tags first.
+func (r *MemoryReporter) TagFrequency(ctx context.Context) ([]TagCount, error) {
+	all, err := r.all(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int64{}
+	for _, book := range all {
+		for _, tag := range book.Tags {
+			counts[tag]++
+		}
+	}
+	return sortedCounts(counts, func(tag string, count int64) TagCount {
+		return TagCount{Tag: tag, Books: count}
+	}), nil
+}
+
+// RecentlyAdded counts books added per day since a moment, oldest days first.
+func (r *MemoryReporter) RecentlyAdded(ctx context.Context, since time.Time) ([]DailyCount, error) {
+	all, err := r.all(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int64{}
+	for _, book := range all {
+		if !book.CreatedAt.Before(since) {
+			counts[book.CreatedAt.UTC().Format(DayLayout)]++
+		}
+	}
+	rows := sortedCounts(counts, func(day string, count int64) DailyCount {
+		return DailyCount{Day: day, Books: count}
+	})
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Day < rows[j].Day })
+	return rows, nil
+}
@@ -0,0 +1,125 @@
+package reporting
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rodolphocastro/golanghello/books"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// givenAShelf creates a MemoryStore with a few books added over a couple of days, one of them deleted.
+func givenAShelf(t *testing.T) *books.MemoryStore {
+	start := time.Date(2022, time.June, 10, 23, 30, 0, 0, time.UTC)
+	now := start
+	addedAfterHours := []int{0, 1, 2, 24, 25}
+	store := books.NewMemoryStore(books.Options{Now: func() time.Time { return now }})
+	shelf := []books.Book{
+		{Title: "At the Mountains of Madness", Author: "H.P. Lovecraft", Tags: []string{"Horror", "Lovecraftian"}},
+		{Title: "The Call of Cthulhu", Author: "H.P. Lovecraft", Tags: []string{"Horror", "Lovecraftian"}},
+		{Title: "The Hobbit", Author: "J.R.R. Tolkien", Tags: []string{"Fantasy"}},
+		{Title: "Carrie", Author: "Stephen King", Tags: []string{"Horror"}},
+		{Title: "The Shining", Author: "Stephen King", Tags: []string{"Horror"}},
+	}
+	for idx, book := range shelf {
+		now = start.Add(time.Duration(addedAfterHours[idx]) * time.Hour)
+		_, err := store.Insert(context.Background(), book)
+		require.Nil(t, err)
+	}
+	deleted, _ := store.Insert(context.Background(), books.Book{Title: "Misery", Author: "Stephen King", Tags: []string{"Thriller"}})
+	require.Nil(t, store.Delete(context.Background(), deleted.ID, deleted.Version))
+	return store
+}
+
+// TestMemoryReporterCountsBooksPerAuthor verifies authors are ranked by how many books they have.
+func TestMemoryReporterCountsBooksPerAuthor(t *testing.T) {
+	// Arrange
+	reporter := NewMemoryReporter(givenAShelf(t))
+
+	// Act
+	got, err := reporter.BooksPerAuthor(context.Background())
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, []AuthorCount{
+		{Author: "H.P. Lovecraft", Books: 2},
+		{Author: "Stephen King", Books: 2},
+		{Author: "J.R.R. Tolkien", Books: 1},
+	}, got)
+}
+
+// TestMemoryReporterCountsTags verifies tags are ranked by how many books carry them.
+func TestMemoryReporterCountsTags(t *testing.T) {
+	// Arrange
+	reporter := NewMemoryReporter(givenAShelf(t))
+
+	// Act
+	got, err := reporter.TagFrequency(context.Background())
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, []TagCount{
+		{Tag: "Horror", Books: 4},
+		{Tag: "Lovecraftian", Books: 2},
+		{Tag: "Fantasy", Books: 1},
+	}, got, "deleted books shouldn't be counted")
+}
+
+// TestMemoryReporterCountsRecentlyAddedBooksPerDay verifies books are counted per UTC day.
+func TestMemoryReporterCountsRecentlyAddedBooksPerDay(t *testing.T) {
+	// Arrange
+	reporter := NewMemoryReporter(givenAShelf(t))
+	since := time.Date(2022, time.June, 11, 0, 0, 0, 0, time.UTC)
+
+	// Act
+	got, err := reporter.RecentlyAdded(context.Background(), since)
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, []DailyCount{
+		{Day: "2022-06-11", Books: 3},
+		{Day: "2022-06-12", Books: 1},
+	}, got)
+}
+
+// TestMemoryReporterOnAnEmptyStoreHasNoRows verifies empty stores produce empty reports.
+func TestMemoryReporterOnAnEmptyStoreHasNoRows(t *testing.T) {
+	// Arrange
+	reporter := NewMemoryReporter(books.NewMemoryStore())
+
+	// Act
+	got, err := reporter.BooksPerAuthor(context.Background())
+
+	// Assert
+	require.Nil(t, err)
+	assert.Empty(t, got)
+}
+
+// TestPipelinesSkipDeletedBooks verifies every pipeline starts by dropping deleted books.
+func TestPipelinesSkipDeletedBooks(t *testing.T) {
+	scenarios := map[string][]bson.D{
+		"booksPerAuthor": BooksPerAuthorPipeline(),
+		"tagFrequency":   TagFrequencyPipeline(),
+		"recentlyAdded":  RecentlyAddedPipeline(time.Now()),
+	}
+
+	for name, pipeline := range scenarios {
+		// Assert
+		assert.Equal(t, notDeleted, pipeline[0], "%v should skip deleted books", name)
+	}
+}
+
+// TestTagFrequencyPipelineUnwindsTags verifies tags are unwound before being grouped.
+func TestTagFrequencyPipelineUnwindsTags(t *testing.T) {
+	// Act
+	got := TagFrequencyPipeline()
+
+	// Assert
+	require.Len(t, got, 4)
+	assert.Equal(t, bson.D{{Key: "$unwind", Value: "$tags"}}, got[1])
+	assert.Equal(t, "$group", got[2][0].Key)
+	assert.Equal(t, "$sort", got[3][0].Key)
+}
@@ -0,0 +1,605 @@
+package books
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rodolphocastro/golanghello/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// DefaultRetention is how long a deleted book can still be retrieved.
+const DefaultRetention = 30 * 24 * time.Hour
+
+// AuditCollectionSuffix is appended to a collection's name to name its audit collection.
+const AuditCollectionSuffix = "Audit"
+
+// Store holds books somewhere.
+type Store interface {
+	// Insert stores a new book and returns it with its ID, version and timestamps set.
+	Insert(ctx context.Context, book Book) (Book, error)
+	// Restore stores a book exactly as it is, such as one read from a backup,
+	// keeping its ID, version, timestamps and deletion. Books that already exist
+	// aren't overwritten and nothing is added to the audit trail.
+	Restore(ctx context.Context, book Book) (Book, error)
+	// Get fetches a book that wasn't deleted.
+	Get(ctx context.Context, id primitive.ObjectID) (Book, error)
+	// Update replaces a book's data as long as its Version matches the stored one.
+	Update(ctx context.Context, book Book) (Book, error)
+	// Delete soft-deletes a book as long as the version matches the stored one.
+	Delete(ctx context.Context, id primitive.ObjectID, version int64) error
+	// GetDeleted fetches a deleted book that is still within the retention period.
+	GetDeleted(ctx context.Context, id primitive.ObjectID) (Book, error)
+	// Purge removes deleted books that are past the retention period and returns how many were removed.
+	Purge(ctx context.Context) (int64, error)
+	// History lists every audit entry of a book, oldest first.
+	History(ctx context.Context, id primitive.ObjectID) ([]AuditEntry, error)
+	// List fetches a single page of books that weren't deleted.
+	List(ctx context.Context, request storage.PageRequest) (storage.Page[Book], error)
+	// Search fetches a single page of books that weren't deleted and match a query, sorted as the query says.
+	Search(ctx context.Context, query Query, request storage.PageRequest) (storage.Page[Book], error)
+}
+
+// Options tunes the behavior of a Store.
+type Options struct {
+	// Retention is how long deleted books are kept around, defaults to DefaultRetention.
+	Retention time.Duration
+	// Now tells the time, defaults to time.Now.
+	Now func() time.Time
+	// Logger receives what happens within transactions, defaults to a no-op logger.
+	Logger *zap.Logger
+}
+
+// withDefaults fills in whatever wasn't set in the first of many Options.
+func withDefaults(opts []Options) Options {
+	var o Options
+	if len(opts) != 0 {
+		o = opts[0]
+	}
+	if o.Retention <= 0 {
+		o.Retention = DefaultRetention
+	}
+	if o.Now == nil {
+		o.Now = time.Now
+	}
+	if o.Logger == nil {
+		o.Logger = zap.NewNop()
+	}
+	return o
+}
+
+// now tells the time with the same precision mongodb stores it.
+func (o Options) now() time.Time {
+	return o.Now().UTC().Truncate(time.Millisecond)
+}
+
+// MongoStore is a Store backed by a mongodb collection. Audit entries go to a
+// sibling collection named after the books' collection plus AuditCollectionSuffix.
+type MongoStore struct {
+	collection *mongo.Collection
+	audit      *mongo.Collection
+	options    Options
+}
+
+// NewMongoStore creates a Store on top of a mongodb collection.
+func NewMongoStore(collection *mongo.Collection, opts ...Options) *MongoStore {
+	return &MongoStore{
+		collection: collection,
+		audit:      collection.Database().Collection(collection.Name() + AuditCollectionSuffix),
+		options:    withDefaults(opts),
+	}
+}
+
+// isNotDeleted is the deletedAt condition matching books that weren't soft-deleted.
+var isNotDeleted = bson.M{"$exists": false}
+
+// EnsureIndexes creates the TTL index that lets mongodb drop deleted books once
+// their retention is over and the index used to look up a book's history.
+func (s *MongoStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "deletedAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(s.options.Retention.Seconds())),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.audit.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "bookId", Value: 1}, {Key: "version", Value: 1}},
+	})
+	return err
+}
+
+// Insert stores a new book in the collection.
+func (s *MongoStore) Insert(ctx context.Context, book Book) (Book, error) {
+	now := s.options.now()
+	book.Version = 1
+	book.CreatedAt = now
+	book.UpdatedAt = now
+	book.DeletedAt = nil
+	result, err := s.collection.InsertOne(ctx, book)
+	if err != nil {
+		return book, err
+	}
+	book.ID = result.InsertedID.(primitive.ObjectID)
+	return book, s.record(ctx, newAuditEntry(ctx, ActionCreated, Book{}, book, now))
+}
+
+// Restore stores a book in the collection as it is.
+func (s *MongoStore) Restore(ctx context.Context, book Book) (Book, error) {
+	if book.ID.IsZero() {
+		return book, errors.New("only books with an id can be restored")
+	}
+	_, err := s.collection.InsertOne(ctx, book)
+	return book, err
+}
+
+// Get fetches a book that wasn't deleted.
+func (s *MongoStore) Get(ctx context.Context, id primitive.ObjectID) (Book, error) {
+	return s.findOne(ctx, bson.M{"_id": id, "deletedAt": isNotDeleted})
+}
+
+// Update replaces a book's title, author and tags if nobody changed it since it was read.
+func (s *MongoStore) Update(ctx context.Context, book Book) (Book, error) {
+	now := s.options.now()
+	before, err := s.findOneAndUpdate(ctx, book.ID, book.Version, bson.M{
+		"$set": bson.M{"title": book.Title, "author": book.Author, "tags": book.Tags, "updatedAt": now},
+		"$inc": bson.M{"version": 1},
+	})
+	if err != nil {
+		return book, err
+	}
+
+	after := before
+	after.Title, after.Author, after.Tags = book.Title, book.Author, book.Tags
+	after.Version = before.Version + 1
+	after.UpdatedAt = now
+	return after, s.record(ctx, newAuditEntry(ctx, ActionUpdated, before, after, now))
+}
+
+// Delete soft-deletes a book if nobody changed it since it was read.
+func (s *MongoStore) Delete(ctx context.Context, id primitive.ObjectID, version int64) error {
+	now := s.options.now()
+	before, err := s.findOneAndUpdate(ctx, id, version, bson.M{
+		"$set": bson.M{"deletedAt": now, "updatedAt": now},
+		"$inc": bson.M{"version": 1},
+	})
+	if err != nil {
+		return err
+	}
+
+	after := before
+	after.Version = before.Version + 1
+	return s.record(ctx, newAuditEntry(ctx, ActionDeleted, before, after, now))
+}
+
+// GetDeleted fetches a deleted book that is still within the retention period.
+func (s *MongoStore) GetDeleted(ctx context.Context, id primitive.ObjectID) (Book, error) {
+	return s.findOne(ctx, bson.M{"_id": id, "deletedAt": bson.M{"$gt": s.options.now().Add(-s.options.Retention)}})
+}
+
+// Purge removes deleted books past their retention, without waiting for mongodb's TTL monitor.
+func (s *MongoStore) Purge(ctx context.Context) (int64, error) {
+	result, err := s.collection.DeleteMany(ctx, bson.M{
+		"deletedAt": bson.M{"$lte": s.options.now().Add(-s.options.Retention)},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// History lists every audit entry of a book, oldest first.
+func (s *MongoStore) History(ctx context.Context, id primitive.ObjectID) ([]AuditEntry, error) {
+	results, err := s.audit.Find(ctx, bson.M{"bookId": id}, options.Find().SetSort(bson.D{
+		{Key: "version", Value: 1},
+		{Key: "_id", Value: 1},
+	}))
+	if err != nil {
+		return nil, err
+	}
+	var entries []AuditEntry
+	err = results.All(ctx, &entries)
+	return entries, err
+}
+
+// List fetches a page of books from the collection.
+func (s *MongoStore) List(ctx context.Context, request storage.PageRequest) (storage.Page[Book], error) {
+	return s.Search(ctx, Query{}, request)
+}
+
+// Search fetches a page of books from the collection, filtered by a query.
+func (s *MongoStore) Search(ctx context.Context, query Query, request storage.PageRequest) (storage.Page[Book], error) {
+	if err := query.Validate(); err != nil {
+		return storage.Page[Book]{}, err
+	}
+	filter := bson.M{"deletedAt": isNotDeleted}
+	if len(query.Conditions) != 0 {
+		filter = bson.M{"$and": bson.A{filter, query.Filter()}}
+	}
+	return storage.FindPage[Book](ctx, s.collection, filter, query.PageRequest(request))
+}
+
+// Archive moves a book, deleted or not, into another collection. Removing it,
+// storing it in the archive and recording the audit entry happen within a single
+// transaction, so the book is never lost nor duplicated.
+func (s *MongoStore) Archive(ctx context.Context, id primitive.ObjectID, archive *mongo.Collection) error {
+	client := s.collection.Database().Client()
+	return storage.WithTransaction(ctx, client, func(ctx context.Context) error {
+		var book Book
+		err := s.collection.FindOneAndDelete(ctx, bson.M{"_id": id}).Decode(&book)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if _, err = archive.InsertOne(ctx, book); err != nil {
+			return err
+		}
+		return s.record(ctx, AuditEntry{
+			BookID:  id,
+			Actor:   ActorFrom(ctx),
+			Action:  ActionArchived,
+			Version: book.Version,
+			At:      s.options.now(),
+		})
+	}, storage.TransactionOptions{Logger: s.options.Logger.With(zap.String("bookId", id.Hex()))})
+}
+
+// findOne fetches a single book, translating a missing document into ErrNotFound.
+func (s *MongoStore) findOne(ctx context.Context, filter bson.M) (Book, error) {
+	var book Book
+	err := s.collection.FindOne(ctx, filter).Decode(&book)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return book, ErrNotFound
+	}
+	return book, err
+}
+
+// findOneAndUpdate applies an update to a book at a specific version and returns
+// the book as it was before the update.
+func (s *MongoStore) findOneAndUpdate(ctx context.Context, id primitive.ObjectID, version int64, update bson.M) (Book, error) {
+	var before Book
+	err := s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": id, "version": version, "deletedAt": isNotDeleted},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.Before),
+	).Decode(&before)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		current, err := s.Get(ctx, id)
+		if err != nil {
+			return before, err
+		}
+		return before, &ConflictError{ID: id, Expected: version, Actual: current.Version}
+	}
+	return before, err
+}
+
+// record appends an entry to the audit collection.
+func (s *MongoStore) record(ctx context.Context, entry AuditEntry) error {
+	_, err := s.audit.InsertOne(ctx, entry)
+	return err
+}
+
+// MemoryStore is a Store that keeps everything in memory, handy for testing
+// things without a mongodb cluster.
+type MemoryStore struct {
+	mutex   sync.RWMutex
+	books   map[primitive.ObjectID]Book
+	audit   []AuditEntry
+	options Options
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore(opts ...Options) *MemoryStore {
+	return &MemoryStore{books: map[primitive.ObjectID]Book{}, options: withDefaults(opts)}
+}
+
+// Insert stores a new book in memory.
+func (s *MemoryStore) Insert(ctx context.Context, book Book) (Book, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if book.ID.IsZero() {
+		book.ID = primitive.NewObjectID()
+	}
+	if _, exists := s.books[book.ID]; exists {
+		return book, fmt.Errorf("a book with id %v already exists", book.ID.Hex())
+	}
+	now := s.options.now()
+	book = book.copied()
+	book.Version = 1
+	book.CreatedAt = now
+	book.UpdatedAt = now
+	book.DeletedAt = nil
+	s.books[book.ID] = book
+	s.audit = append(s.audit, newAuditEntry(ctx, ActionCreated, Book{}, book, now))
+	return book.copied(), nil
+}
+
+// Restore stores a book in memory as it is.
+func (s *MemoryStore) Restore(_ context.Context, book Book) (Book, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if book.ID.IsZero() {
+		return book, errors.New("only books with an id can be restored")
+	}
+	if _, exists := s.books[book.ID]; exists {
+		return book, fmt.Errorf("a book with id %v already exists", book.ID.Hex())
+	}
+	book = book.copied()
+	s.books[book.ID] = book
+	return book.copied(), nil
+}
+
+// Get fetches a book that wasn't deleted.
+func (s *MemoryStore) Get(_ context.Context, id primitive.ObjectID) (Book, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	book, exists := s.books[id]
+	if !exists || book.DeletedAt != nil {
+		return Book{}, ErrNotFound
+	}
+	return book.copied(), nil
+}
+
+// Update replaces a book's title, author and tags if nobody changed it since it was read.
+func (s *MemoryStore) Update(ctx context.Context, book Book) (Book, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	before, err := s.current(book.ID, book.Version)
+	if err != nil {
+		return book, err
+	}
+
+	now := s.options.now()
+	after := before
+	after.Title, after.Author = book.Title, book.Author
+	after.Tags = book.copied().Tags
+	after.Version++
+	after.UpdatedAt = now
+	s.books[after.ID] = after
+	s.audit = append(s.audit, newAuditEntry(ctx, ActionUpdated, before, after, now))
+	return after.copied(), nil
+}
+
+// Delete soft-deletes a book if nobody changed it since it was read.
+func (s *MemoryStore) Delete(ctx context.Context, id primitive.ObjectID, version int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	before, err := s.current(id, version)
+	if err != nil {
+		return err
+	}
+
+	now := s.options.now()
+	after := before
+	after.Version++
+	after.UpdatedAt = now
+	after.DeletedAt = &now
+	s.books[id] = after
+	s.audit = append(s.audit, newAuditEntry(ctx, ActionDeleted, before, after, now))
+	return nil
+}
+
+// GetDeleted fetches a deleted book that is still within the retention period.
+func (s *MemoryStore) GetDeleted(_ context.Context, id primitive.ObjectID) (Book, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	book, exists := s.books[id]
+	if !exists || book.DeletedAt == nil || s.isExpired(book) {
+		return Book{}, ErrNotFound
+	}
+	return book.copied(), nil
+}
+
+// Purge removes deleted books past their retention.
+func (s *MemoryStore) Purge(_ context.Context) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var purged int64
+	for id, book := range s.books {
+		if book.DeletedAt != nil && s.isExpired(book) {
+			delete(s.books, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// History lists every audit entry of a book, oldest first.
+func (s *MemoryStore) History(_ context.Context, id primitive.ObjectID) ([]AuditEntry, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var entries []AuditEntry
+	for _, entry := range s.audit {
+		if entry.BookID == id {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// List fetches a page of books following the same rules as storage.FindPage.
+func (s *MemoryStore) List(ctx context.Context, request storage.PageRequest) (storage.Page[Book], error) {
+	return s.Search(ctx, Query{}, request)
+}
+
+// Search fetches a page of the books matching a query, following the same rules as storage.FindPage.
+func (s *MemoryStore) Search(_ context.Context, query Query, request storage.PageRequest) (storage.Page[Book], error) {
+	var page storage.Page[Book]
+	if err := query.Validate(); err != nil {
+		return page, err
+	}
+	request = query.PageRequest(request).Normalize()
+	if _, err := sortValue(Book{}, request.SortBy); err != nil {
+		return page, err
+	}
+	cursor, err := request.Cursor()
+	if err != nil {
+		return page, err
+	}
+
+	s.mutex.RLock()
+	all := make([]Book, 0, len(s.books))
+	for _, book := range s.books {
+		if book.DeletedAt == nil && query.Matches(book) {
+			all = append(all, book.copied())
+		}
+	}
+	s.mutex.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		return compareBooks(all[i], all[j], request.SortBy, request.Descending) < 0
+	})
+	if request.IncludeTotal {
+		total := int64(len(all))
+		page.Total = &total
+	}
+
+	start := 0
+	if cursor != nil {
+		start = sort.Search(len(all), func(i int) bool {
+			return isAfterCursor(all[i], *cursor)
+		})
+	}
+	end := start + request.Size
+	if end >= len(all) {
+		end = len(all)
+	} else {
+		last := all[end-1]
+		value, _ := sortValue(last, request.SortBy)
+		page.NextToken, err = storage.EncodePageToken(storage.PageCursor{
+			SortBy:     request.SortBy,
+			Descending: request.Descending,
+			Value:      value,
+			ID:         last.ID,
+		})
+		if err != nil {
+			return page, err
+		}
+	}
+	page.Items = append(make([]Book, 0, end-start), all[start:end]...)
+	return page, nil
+}
+
+// copied copies a book along with its tags, so whoever gets it can't change
+// what the store holds without going through Update.
+func (b Book) copied() Book {
+	b.Tags = append([]string(nil), b.Tags...)
+	return b
+}
+
+// current fetches a book that wasn't deleted and checks it is at the expected version.
+func (s *MemoryStore) current(id primitive.ObjectID, version int64) (Book, error) {
+	book, exists := s.books[id]
+	if !exists || book.DeletedAt != nil {
+		return book, ErrNotFound
+	}
+	if book.Version != version {
+		return book, &ConflictError{ID: id, Expected: version, Actual: book.Version}
+	}
+	return book, nil
+}
+
+// isExpired checks if a deleted book is past its retention.
+func (s *MemoryStore) isExpired(book Book) bool {
+	return !book.DeletedAt.After(s.options.now().Add(-s.options.Retention))
+}
+
+// sortValue gets the value a book is sorted by, for the _id sorting the value is the ID itself.
+func sortValue(book Book, field string) (interface{}, error) {
+	switch field {
+	case storage.IDField:
+		return book.ID, nil
+	case "title":
+		return book.Title, nil
+	case "author":
+		return book.Author, nil
+	case "createdAt":
+		return book.CreatedAt, nil
+	case "updatedAt":
+		return book.UpdatedAt, nil
+	}
+	return nil, fmt.Errorf("books can't be sorted by %v", field)
+}
+
+// compareValues compares two values of the same kind, values that can't be compared are treated as equal.
+func compareValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case string:
+		b, _ := b.(string)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	case int64:
+		b, _ := b.(int64)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	case primitive.ObjectID:
+		b, _ := b.(primitive.ObjectID)
+		return bytes.Compare(a[:], b[:])
+	case time.Time:
+		var other time.Time
+		switch b := b.(type) {
+		case time.Time:
+			other = b
+		case primitive.DateTime: // times come back from page tokens as mongodb dates
+			other = b.Time()
+		}
+		switch {
+		case a.Before(other):
+			return -1
+		case a.After(other):
+			return 1
+		}
+	}
+	return 0
+}
+
+// compareBooks compares two books by a field, ties are broken by their IDs.
+func compareBooks(a, b Book, field string, descending bool) int {
+	valueA, _ := sortValue(a, field)
+	valueB, _ := sortValue(b, field)
+	result := compareValues(valueA, valueB)
+	if result == 0 {
+		result = compareValues(a.ID, b.ID)
+	}
+	if descending {
+		return -result
+	}
+	return result
+}
+
+// isAfterCursor checks if a book comes after the position of a cursor.
+func isAfterCursor(book Book, cursor storage.PageCursor) bool {
+	value, _ := sortValue(book, cursor.SortBy)
+	result := 0
+	if cursor.SortBy != storage.IDField {
+		result = compareValues(value, cursor.Value)
+	}
+	if result == 0 {
+		id, _ := cursor.ID.(primitive.ObjectID)
+		result = compareValues(book.ID, id)
+	}
+	if cursor.Descending {
+		return result < 0
+	}
+	return result > 0
+}
@@ -0,0 +1,303 @@
+package books
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rodolphocastro/golanghello/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// givenAStoreWithBooks creates a MemoryStore holding an amount of books, titles repeat every 3 books.
+func givenAStoreWithBooks(t *testing.T, amount int) *MemoryStore {
+	store := NewMemoryStore()
+	for i := 0; i < amount; i++ {
+		_, err := store.Insert(context.Background(), Book{
+			Title:  fmt.Sprintf("Book %v", i%3),
+			Author: "H.P. Lovecraft",
+			Tags:   []string{"Horror"},
+		})
+		require.Nil(t, err)
+	}
+	return store
+}
+
+// listEverything walks every page of a listing and returns all the books it found.
+func listEverything(t *testing.T, store Store, request storage.PageRequest) []Book {
+	var got []Book
+	for {
+		page, err := store.List(context.Background(), request)
+		require.Nil(t, err)
+		assert.LessOrEqual(t, len(page.Items), request.Normalize().Size)
+		got = append(got, page.Items...)
+		if page.NextToken == "" {
+			return got
+		}
+		request.Token = page.NextToken
+	}
+}
+
+// TestMemoryStoreListsEveryBookExactlyOnce verifies walking through pages visits every book once.
+func TestMemoryStoreListsEveryBookExactlyOnce(t *testing.T) {
+	scenarios := []storage.PageRequest{
+		{Size: 3},
+		{Size: 4, SortBy: "title"},
+		{Size: 5, SortBy: "title", Descending: true},
+		{Size: 50, SortBy: "author"},
+	}
+	store := givenAStoreWithBooks(t, 20)
+
+	for _, request := range scenarios {
+		// Act
+		got := listEverything(t, store, request)
+
+		// Assert
+		seen := map[primitive.ObjectID]bool{}
+		for idx, book := range got {
+			assert.False(t, seen[book.ID], "book %v was listed twice", book.ID.Hex())
+			seen[book.ID] = true
+			if idx > 0 {
+				assert.Negative(t, compareBooks(got[idx-1], book, request.Normalize().SortBy, request.Descending),
+					"books should be listed in order")
+			}
+		}
+		assert.Len(t, seen, 20)
+	}
+}
+
+// TestMemoryStoreOnlyCountsWhenAsked verifies totals are only computed on demand.
+func TestMemoryStoreOnlyCountsWhenAsked(t *testing.T) {
+	// Arrange
+	store := givenAStoreWithBooks(t, 7)
+
+	// Act
+	withoutTotal, _ := store.List(context.Background(), storage.PageRequest{Size: 2})
+	withTotal, _ := store.List(context.Background(), storage.PageRequest{Size: 2, IncludeTotal: true})
+
+	// Assert
+	assert.Nil(t, withoutTotal.Total)
+	require.NotNil(t, withTotal.Total)
+	assert.EqualValues(t, 7, *withTotal.Total)
+}
+
+// TestMemoryStoreRejectsBadRequests verifies unknown sortings and bogus tokens are errors.
+func TestMemoryStoreRejectsBadRequests(t *testing.T) {
+	// Arrange
+	store := givenAStoreWithBooks(t, 3)
+
+	// Act
+	_, errSort := store.List(context.Background(), storage.PageRequest{SortBy: "isbn"})
+	_, errToken := store.List(context.Background(), storage.PageRequest{Token: "bogus"})
+
+	// Assert
+	assert.Error(t, errSort)
+	assert.ErrorIs(t, errToken, storage.ErrInvalidPageToken)
+}
+
+// TestMemoryStoreRejectsDuplicatedIds verifies a book can't be inserted twice.
+func TestMemoryStoreRejectsDuplicatedIds(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	book, _ := store.Insert(context.Background(), Book{Title: "Dagon"})
+
+	// Act
+	_, err := store.Insert(context.Background(), book)
+
+	// Assert
+	assert.Error(t, err)
+}
+
+// fakeClock is a clock that only moves when told to.
+type fakeClock struct {
+	now time.Time
+}
+
+// Now tells the fake time.
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+// givenAStoreWithAClock creates a MemoryStore ruled by a fake clock and a day of retention.
+func givenAStoreWithAClock() (*MemoryStore, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2022, time.June, 12, 9, 4, 10, 0, time.UTC)}
+	return NewMemoryStore(Options{Retention: 24 * time.Hour, Now: clock.Now}), clock
+}
+
+// TestInsertSetsVersionAndTimestamps verifies new books start at version 1 with both timestamps set.
+func TestInsertSetsVersionAndTimestamps(t *testing.T) {
+	// Arrange
+	store, clock := givenAStoreWithAClock()
+
+	// Act
+	got, err := store.Insert(context.Background(), Book{Title: "Dagon", Version: 42})
+
+	// Assert
+	require.Nil(t, err)
+	assert.EqualValues(t, 1, got.Version)
+	assert.Equal(t, clock.now, got.CreatedAt)
+	assert.Equal(t, clock.now, got.UpdatedAt)
+	assert.Nil(t, got.DeletedAt)
+}
+
+// TestUpdateBumpsVersionAndUpdatedAt verifies updates go through when versions match.
+func TestUpdateBumpsVersionAndUpdatedAt(t *testing.T) {
+	// Arrange
+	store, clock := givenAStoreWithAClock()
+	book, _ := store.Insert(context.Background(), Book{Title: "Dagon"})
+	clock.now = clock.now.Add(time.Hour)
+	book.Title = "The Call of Cthulhu"
+
+	// Act
+	got, err := store.Update(context.Background(), book)
+	stored, _ := store.Get(context.Background(), book.ID)
+
+	// Assert
+	require.Nil(t, err)
+	assert.EqualValues(t, 2, got.Version)
+	assert.Equal(t, clock.now, got.UpdatedAt)
+	assert.NotEqual(t, got.CreatedAt, got.UpdatedAt)
+	assert.Equal(t, got, stored)
+}
+
+// TestConcurrentUpdatesConflict verifies the second of two writers holding the same version gets a ConflictError.
+func TestConcurrentUpdatesConflict(t *testing.T) {
+	// Arrange
+	store, _ := givenAStoreWithAClock()
+	book, _ := store.Insert(context.Background(), Book{Title: "Dagon"})
+	firstWriter, secondWriter := book, book
+	firstWriter.Title = "The Call of Cthulhu"
+	secondWriter.Title = "The Dunwich Horror"
+
+	// Act
+	_, firstErr := store.Update(context.Background(), firstWriter)
+	_, secondErr := store.Update(context.Background(), secondWriter)
+	deleteErr := store.Delete(context.Background(), book.ID, book.Version)
+
+	// Assert
+	assert.Nil(t, firstErr)
+	var conflict *ConflictError
+	require.ErrorAs(t, secondErr, &conflict)
+	assert.EqualValues(t, 1, conflict.Expected)
+	assert.EqualValues(t, 2, conflict.Actual)
+	assert.ErrorAs(t, deleteErr, &conflict, "deletes should be checked as well")
+	stored, _ := store.Get(context.Background(), book.ID)
+	assert.Equal(t, firstWriter.Title, stored.Title, "the first write should have been kept")
+}
+
+// TestUpdatingSomethingMissingIsNotFound verifies unknown books can't be updated.
+func TestUpdatingSomethingMissingIsNotFound(t *testing.T) {
+	// Arrange
+	store, _ := givenAStoreWithAClock()
+
+	// Act
+	_, err := store.Update(context.Background(), Book{ID: primitive.NewObjectID(), Version: 1})
+
+	// Assert
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// TestHistoryRecordsWhoChangedWhat verifies every change ends up in the audit trail.
+func TestHistoryRecordsWhoChangedWhat(t *testing.T) {
+	// Arrange
+	store, _ := givenAStoreWithAClock()
+	book, _ := store.Insert(WithActor(context.Background(), "lovecraft"), Book{Title: "Dagon"})
+	book.Tags = []string{"Horror"}
+	book, _ = store.Update(WithActor(context.Background(), "derleth"), book)
+	_ = store.Delete(context.Background(), book.ID, book.Version)
+
+	// Act
+	got, err := store.History(context.Background(), book.ID)
+
+	// Assert
+	require.Nil(t, err)
+	require.Len(t, got, 3)
+	assert.Equal(t, ActionCreated, got[0].Action)
+	assert.Equal(t, "lovecraft", got[0].Actor)
+	assert.Equal(t, []Change{{Field: "title", Before: "", After: "Dagon"}}, got[0].Changes)
+	assert.Equal(t, ActionUpdated, got[1].Action)
+	assert.Equal(t, "derleth", got[1].Actor)
+	assert.Equal(t, []Change{{Field: "tags", Before: []string(nil), After: []string{"Horror"}}}, got[1].Changes)
+	assert.Equal(t, ActionDeleted, got[2].Action)
+	assert.Equal(t, UnknownActor, got[2].Actor)
+	assert.EqualValues(t, 3, got[2].Version)
+}
+
+// TestDeletedBooksAreRetrievableUntilTheirRetentionIsOver verifies the soft-delete lifecycle.
+func TestDeletedBooksAreRetrievableUntilTheirRetentionIsOver(t *testing.T) {
+	// Arrange
+	store, clock := givenAStoreWithAClock()
+	book, _ := store.Insert(context.Background(), Book{Title: "Dagon"})
+	kept, _ := store.Insert(context.Background(), Book{Title: "The Call of Cthulhu"})
+
+	// Act
+	err := store.Delete(context.Background(), book.ID, book.Version)
+	_, getErr := store.Get(context.Background(), book.ID)
+	deleted, deletedErr := store.GetDeleted(context.Background(), book.ID)
+	page, _ := store.List(context.Background(), storage.PageRequest{IncludeTotal: true})
+	purgedEarly, _ := store.Purge(context.Background())
+	clock.now = clock.now.Add(25 * time.Hour)
+	_, expiredErr := store.GetDeleted(context.Background(), book.ID)
+	purged, _ := store.Purge(context.Background())
+
+	// Assert
+	require.Nil(t, err)
+	assert.ErrorIs(t, getErr, ErrNotFound, "deleted books shouldn't be found")
+	require.Nil(t, deletedErr, "deleted books should be retrievable within the retention")
+	assert.NotNil(t, deleted.DeletedAt)
+	assert.EqualValues(t, 1, *page.Total, "deleted books shouldn't be listed")
+	assert.Equal(t, kept.ID, page.Items[0].ID)
+	assert.Zero(t, purgedEarly)
+	assert.ErrorIs(t, expiredErr, ErrNotFound, "deleted books shouldn't be retrievable after the retention")
+	assert.EqualValues(t, 1, purged)
+}
+
+// TestListingByTimestampsSurvivesTheTokens verifies tokens sorted by dates resume where they stopped.
+func TestListingByTimestampsSurvivesTheTokens(t *testing.T) {
+	// Arrange
+	store, clock := givenAStoreWithAClock()
+	for i := 0; i < 5; i++ {
+		clock.now = clock.now.Add(time.Minute)
+		_, _ = store.Insert(context.Background(), Book{Title: fmt.Sprintf("Book %v", i)})
+	}
+
+	// Act
+	got := listEverything(t, store, storage.PageRequest{Size: 2, SortBy: "createdAt", Descending: true})
+
+	// Assert
+	require.Len(t, got, 5)
+	for idx, book := range got {
+		assert.Equal(t, fmt.Sprintf("Book %v", 4-idx), book.Title)
+	}
+}
+
+// TestMemoryStoreHandsOutItsOwnTags verifies changing the tags of a book read from the store leaves the store untouched.
+func TestMemoryStoreHandsOutItsOwnTags(t *testing.T) {
+	// Arrange
+	store, _ := givenAStoreWithAClock()
+	kept, _ := store.Insert(context.Background(), Book{Title: "Dagon", Tags: []string{"Horror"}})
+	deleted, _ := store.Insert(context.Background(), Book{Title: "The Call of Cthulhu", Tags: []string{"Horror"}})
+	_ = store.Delete(context.Background(), deleted.ID, deleted.Version)
+
+	// Act
+	kept.Tags[0] = "Inserted"
+	got, _ := store.Get(context.Background(), kept.ID)
+	got.Tags[0] = "Got"
+	page, _ := store.List(context.Background(), storage.PageRequest{})
+	page.Items[0].Tags[0] = "Listed"
+	updated, _ := store.Update(context.Background(), Book{ID: kept.ID, Title: "Dagon", Tags: []string{"Horror"}, Version: kept.Version})
+	updated.Tags[0] = "Updated"
+	gone, _ := store.GetDeleted(context.Background(), deleted.ID)
+	gone.Tags[0] = "Got deleted"
+
+	// Assert
+	stored, err := store.Get(context.Background(), kept.ID)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"Horror"}, stored.Tags)
+	stored, err = store.GetDeleted(context.Background(), deleted.ID)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"Horror"}, stored.Tags)
+}
@@ -0,0 +1,64 @@
+// Command gpx2geojson converts a GPX file into a GeoJSON FeatureCollection.
+//
+// Usage:
+//
+//	gpx2geojson [-extensions] [-indent] [file.gpx]
+//
+// The GPX is read from the file, or from the standard input when no file is
+// given, and the GeoJSON is written to the standard output.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rodolphocastro/golanghello/activity/geojson"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "gpx2geojson:", err)
+		os.Exit(1)
+	}
+}
+
+// run parses the arguments, converts the GPX and writes the GeoJSON.
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("gpx2geojson", flag.ContinueOnError)
+	extensions := flags.Bool("extensions", false, "include heart rate, cadence and temperature arrays")
+	indent := flags.Bool("indent", false, "indent the GeoJSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		return fmt.Errorf("expected a single GPX file, got %v", flags.NArg())
+	}
+
+	input := stdin
+	if flags.NArg() == 1 {
+		file, err := os.Open(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+	content, err := io.ReadAll(input)
+	if err != nil {
+		return err
+	}
+	g, err := gpx.ParseBytes(content)
+	if err != nil {
+		return fmt.Errorf("parsing GPX: %w", err)
+	}
+
+	encoder := json.NewEncoder(stdout)
+	if *indent {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder.Encode(geojson.Convert(g, geojson.Options{Extensions: *extensions}))
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const pathToGuineaPig = "../../14-test-subject.gpx"
+
+// TestRunConvertsAFile verifies the command writes a FeatureCollection for a file.
+func TestRunConvertsAFile(t *testing.T) {
+	// Arrange
+	var stdout bytes.Buffer
+
+	// Act
+	err := run([]string{"-extensions", pathToGuineaPig}, strings.NewReader(""), &stdout)
+
+	// Assert
+	require.Nil(t, err)
+	var decoded map[string]interface{}
+	require.Nil(t, json.Unmarshal(stdout.Bytes(), &decoded))
+	assert.Equal(t, "FeatureCollection", decoded["type"])
+	assert.Len(t, decoded["features"], 1)
+}
+
+// TestRunReadsTheStandardInput verifies the command reads the GPX from stdin when no file is given.
+func TestRunReadsTheStandardInput(t *testing.T) {
+	// Arrange
+	var stdout bytes.Buffer
+	stdin := strings.NewReader(`<?xml version="1.0"?><gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1"><wpt lat="1" lon="2"><name>Start</name></wpt></gpx>`)
+
+	// Act
+	err := run(nil, stdin, &stdout)
+
+	// Assert
+	require.Nil(t, err)
+	assert.JSONEq(t, `{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"Point","coordinates":[2,1]},"properties":{"name":"Start"}}]}`, stdout.String())
+}
+
+// TestRunRejectsBadInput verifies garbage and extra arguments are errors.
+func TestRunRejectsBadInput(t *testing.T) {
+	assert.Error(t, run(nil, strings.NewReader("not a gpx"), &bytes.Buffer{}))
+	assert.Error(t, run([]string{"a.gpx", "b.gpx"}, strings.NewReader(""), &bytes.Buffer{}))
+	assert.Error(t, run([]string{"missing.gpx"}, strings.NewReader(""), &bytes.Buffer{}))
+}
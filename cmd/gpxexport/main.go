@@ -0,0 +1,73 @@
+// Command gpxexport converts a GPX file into TCX or CSV, for the tools that
+// read neither GPX nor GeoJSON.
+//
+// Usage:
+//
+//	gpxexport -format tcx|csv [-lap meters] [file.gpx]
+//
+// TCX activities are split into laps of -lap meters, a kilometer by default.
+// CSV has a row per track point. The GPX is read from the file, or from the
+// standard input when no file is given, and the conversion is written to the
+// standard output.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rodolphocastro/golanghello/activity"
+	"github.com/rodolphocastro/golanghello/activity/csv"
+	"github.com/rodolphocastro/golanghello/activity/tcx"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "gpxexport:", err)
+		os.Exit(1)
+	}
+}
+
+// run parses the arguments, converts the GPX and writes the conversion.
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("gpxexport", flag.ContinueOnError)
+	format := flags.String("format", "", "tcx or csv")
+	lap := flags.Float64("lap", activity.Kilometer, "how long TCX laps are, in meters")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *format != "tcx" && *format != "csv" {
+		return fmt.Errorf("expected -format tcx or csv, got %q", *format)
+	}
+	if *lap <= 0 {
+		return fmt.Errorf("laps must be longer than %v meters", *lap)
+	}
+	if flags.NArg() > 1 {
+		return fmt.Errorf("expected a single GPX file, got %v", flags.NArg())
+	}
+
+	input := stdin
+	if flags.NArg() == 1 {
+		file, err := os.Open(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+	content, err := io.ReadAll(input)
+	if err != nil {
+		return err
+	}
+	g, err := gpx.ParseBytes(content)
+	if err != nil {
+		return fmt.Errorf("parsing GPX: %w", err)
+	}
+
+	if *format == "csv" {
+		return csv.Write(stdout, g)
+	}
+	return tcx.Write(stdout, g, tcx.Options{LapLength: *lap})
+}
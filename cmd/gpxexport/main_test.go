@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const pathToGuineaPig = "../../14-test-subject.gpx"
+
+// TestRunWritesTCX verifies the command writes a TCX document with laps of the given length.
+func TestRunWritesTCX(t *testing.T) {
+	// Arrange
+	var stdout bytes.Buffer
+
+	// Act
+	err := run([]string{"-format", "tcx", "-lap", "1609.344", pathToGuineaPig}, strings.NewReader(""), &stdout)
+
+	// Assert
+	require.Nil(t, err)
+	assert.Contains(t, stdout.String(), `<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2">`)
+	assert.Contains(t, stdout.String(), "<DistanceMeters>1609.344</DistanceMeters>")
+	assert.Equal(t, 1657, strings.Count(stdout.String(), "<Trackpoint>"))
+}
+
+// TestRunWritesCSVFromTheStandardInput verifies the command reads the GPX from stdin and writes a row per point.
+func TestRunWritesCSVFromTheStandardInput(t *testing.T) {
+	// Arrange
+	var stdout bytes.Buffer
+	stdin := strings.NewReader(`<?xml version="1.0"?><gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+<trk><trkseg><trkpt lat="1" lon="2"><ele>10</ele><time>2022-06-12T09:04:10Z</time></trkpt></trkseg></trk></gpx>`)
+
+	// Act
+	err := run([]string{"-format", "csv"}, stdin, &stdout)
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, "time,lat,lon,ele,hr,cad,atemp,distance,speed\n2022-06-12T09:04:10Z,1,2,10,,,,0.00,\n", stdout.String())
+}
+
+// TestRunRejectsBadInput verifies unknown formats, bad laps, garbage and extra arguments are errors.
+func TestRunRejectsBadInput(t *testing.T) {
+	assert.Error(t, run([]string{pathToGuineaPig}, strings.NewReader(""), &bytes.Buffer{}))
+	assert.Error(t, run([]string{"-format", "fit", pathToGuineaPig}, strings.NewReader(""), &bytes.Buffer{}))
+	assert.Error(t, run([]string{"-format", "tcx", "-lap", "0", pathToGuineaPig}, strings.NewReader(""), &bytes.Buffer{}))
+	assert.Error(t, run([]string{"-format", "csv"}, strings.NewReader("not a gpx"), &bytes.Buffer{}))
+	assert.Error(t, run([]string{"-format", "csv", "a.gpx", "b.gpx"}, strings.NewReader(""), &bytes.Buffer{}))
+	assert.Error(t, run([]string{"-format", "csv", "missing.gpx"}, strings.NewReader(""), &bytes.Buffer{}))
+}
@@ -0,0 +1,114 @@
+// Command gpxprivacy hides the parts of a GPX file within privacy zones, so it
+// can be shared without giving away where home is.
+//
+// Usage:
+//
+//	gpxprivacy -zone lat,lon,radius [-zone ...] [-clip] [-geojson] [file.gpx]
+//
+// Every point within a zone, radius being in meters, is dropped. With -clip
+// tracks end and start at the edge of the zones rather than at the last and
+// first point outside them. The GPX is read from the file, or from the
+// standard input when no file is given, and the sanitized GPX, or GeoJSON
+// with -geojson, is written to the standard output.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rodolphocastro/golanghello/activity"
+	"github.com/rodolphocastro/golanghello/activity/geojson"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "gpxprivacy:", err)
+		os.Exit(1)
+	}
+}
+
+// zones collects the -zone flags.
+type zones []activity.PrivacyZone
+
+// String lists the zones as they're given.
+func (z *zones) String() string {
+	var flags []string
+	for _, zone := range *z {
+		flags = append(flags, fmt.Sprintf("%v,%v,%v", zone.Center.Latitude, zone.Center.Longitude, zone.Radius))
+	}
+	return strings.Join(flags, " ")
+}
+
+// Set parses a zone given as lat,lon,radius.
+func (z *zones) Set(value string) error {
+	parts := strings.Split(value, ",")
+	if len(parts) != 3 {
+		return fmt.Errorf("zones are lat,lon,radius, not %q", value)
+	}
+	var numbers [3]float64
+	for idx, part := range parts {
+		number, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return fmt.Errorf("zones are lat,lon,radius, not %q", value)
+		}
+		numbers[idx] = number
+	}
+	*z = append(*z, activity.PrivacyZone{Center: activity.Coordinate{Latitude: numbers[0], Longitude: numbers[1]}, Radius: numbers[2]})
+	return nil
+}
+
+// run parses the arguments, hides the zones and writes what's left.
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("gpxprivacy", flag.ContinueOnError)
+	var privacyZones zones
+	flags.Var(&privacyZones, "zone", "a privacy zone as lat,lon,radius in meters, may be repeated")
+	clip := flags.Bool("clip", false, "end and start tracks at the edge of the zones")
+	asGeoJSON := flags.Bool("geojson", false, "write GeoJSON rather than GPX")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if len(privacyZones) == 0 {
+		return errors.New("expected at least one -zone")
+	}
+	if flags.NArg() > 1 {
+		return fmt.Errorf("expected a single GPX file, got %v", flags.NArg())
+	}
+
+	input := stdin
+	if flags.NArg() == 1 {
+		file, err := os.Open(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+	content, err := io.ReadAll(input)
+	if err != nil {
+		return err
+	}
+	g, err := gpx.ParseBytes(content)
+	if err != nil {
+		return fmt.Errorf("parsing GPX: %w", err)
+	}
+
+	mode := activity.Strip
+	if *clip {
+		mode = activity.Clip
+	}
+	hidden, _, err := activity.HidePrivacyZones(g, mode, privacyZones...)
+	if err != nil {
+		return err
+	}
+	if *asGeoJSON {
+		return json.NewEncoder(stdout).Encode(geojson.Convert(hidden))
+	}
+	return activity.WriteGPX(stdout, hidden)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+const pathToGuineaPig = "../../14-test-subject.gpx"
+
+// aroundTheStart is a zone around where the guinea pig starts.
+const aroundTheStart = "-25.4148,-49.2679,300"
+
+// TestRunHidesTheStart verifies the command writes a GPX without the points within the zones, starting later.
+func TestRunHidesTheStart(t *testing.T) {
+	// Arrange
+	var stdout bytes.Buffer
+	original, err := gpx.ParseFile(pathToGuineaPig)
+	require.Nil(t, err)
+
+	// Act
+	err = run([]string{"-zone", aroundTheStart, "-clip", pathToGuineaPig}, strings.NewReader(""), &stdout)
+
+	// Assert
+	require.Nil(t, err)
+	got, err := gpx.ParseBytes(stdout.Bytes())
+	require.Nil(t, err)
+	assert.Less(t, got.GetTrackPointsNo(), original.GetTrackPointsNo())
+	assert.Greater(t, got.GetTrackPointsNo(), 0)
+	require.NotNil(t, got.Time)
+	assert.Equal(t, got.Tracks[0].Segments[0].Points[0].Timestamp, *got.Time, "starting where the track does")
+	assert.True(t, got.Time.After(*original.Time))
+}
+
+// TestRunWritesGeoJSON verifies the command writes GeoJSON when asked to.
+func TestRunWritesGeoJSON(t *testing.T) {
+	// Arrange
+	var stdout bytes.Buffer
+
+	// Act
+	err := run([]string{"-zone", aroundTheStart, "-geojson", pathToGuineaPig}, strings.NewReader(""), &stdout)
+
+	// Assert
+	require.Nil(t, err)
+	var decoded map[string]interface{}
+	require.Nil(t, json.Unmarshal(stdout.Bytes(), &decoded))
+	assert.Equal(t, "FeatureCollection", decoded["type"])
+}
+
+// TestRunRejectsBadInput verifies missing or malformed zones and bad files are errors.
+func TestRunRejectsBadInput(t *testing.T) {
+	assert.Error(t, run([]string{pathToGuineaPig}, strings.NewReader(""), &bytes.Buffer{}))
+	assert.Error(t, run([]string{"-zone", "1,2", pathToGuineaPig}, strings.NewReader(""), &bytes.Buffer{}))
+	assert.Error(t, run([]string{"-zone", "a,b,c", pathToGuineaPig}, strings.NewReader(""), &bytes.Buffer{}))
+	assert.Error(t, run([]string{"-zone", "1,2,3"}, strings.NewReader("not a gpx"), &bytes.Buffer{}))
+	assert.Error(t, run([]string{"-zone", "-25.4148,-49.2679,100000", pathToGuineaPig}, strings.NewReader(""), &bytes.Buffer{}))
+}
@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/description"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultConnectTimeout is how long connecting to a server may take.
+	DefaultConnectTimeout = 10 * time.Second
+	// DefaultServerSelectionTimeout is how long an operation waits for a suitable server.
+	DefaultServerSelectionTimeout = 5 * time.Second
+	// DefaultHealthTimeout is how long a health check waits for the ping.
+	DefaultHealthTimeout = 2 * time.Second
+)
+
+// ErrClientClosed is returned when a ClientManager is used after being closed.
+var ErrClientClosed = errors.New("mongo client manager is closed")
+
+// ClientOptions configures a ClientManager.
+type ClientOptions struct {
+	// URI is the mongodb connection string.
+	URI string
+	// ConnectTimeout defaults to DefaultConnectTimeout.
+	ConnectTimeout time.Duration
+	// ServerSelectionTimeout defaults to DefaultServerSelectionTimeout.
+	ServerSelectionTimeout time.Duration
+	// HealthTimeout defaults to DefaultHealthTimeout.
+	HealthTimeout time.Duration
+	// MinPoolSize and MaxPoolSize size the connection pool, zero keeps the driver's defaults.
+	MinPoolSize uint64
+	MaxPoolSize uint64
+	// Logger receives connection events, defaults to a no-op logger.
+	Logger *zap.Logger
+}
+
+// withDefaults fills in whatever wasn't set.
+func (o ClientOptions) withDefaults() ClientOptions {
+	if o.ConnectTimeout <= 0 {
+		o.ConnectTimeout = DefaultConnectTimeout
+	}
+	if o.ServerSelectionTimeout <= 0 {
+		o.ServerSelectionTimeout = DefaultServerSelectionTimeout
+	}
+	if o.HealthTimeout <= 0 {
+		o.HealthTimeout = DefaultHealthTimeout
+	}
+	if o.Logger == nil {
+		o.Logger = zap.NewNop()
+	}
+	return o
+}
+
+// ClientManager owns a single mongodb client: it connects lazily, checks its
+// health and closes it. It is safe for concurrent use.
+type ClientManager struct {
+	options ClientOptions
+	logger  *zap.Logger
+	mutex   sync.Mutex
+	client  *mongo.Client
+	closed  bool
+}
+
+// NewClientManager creates a ClientManager, no connection is made until Client is called.
+func NewClientManager(options ClientOptions) *ClientManager {
+	options = options.withDefaults()
+	return &ClientManager{options: options, logger: options.Logger}
+}
+
+// driverOptions translates the manager's options into the driver's.
+func (m *ClientManager) driverOptions() *options.ClientOptions {
+	clientOptions := options.Client().
+		ApplyURI(m.options.URI).
+		SetConnectTimeout(m.options.ConnectTimeout).
+		SetServerSelectionTimeout(m.options.ServerSelectionTimeout).
+		SetServerMonitor(newServerMonitor(m.logger))
+	if m.options.MinPoolSize != 0 {
+		clientOptions.SetMinPoolSize(m.options.MinPoolSize)
+	}
+	if m.options.MaxPoolSize != 0 {
+		clientOptions.SetMaxPoolSize(m.options.MaxPoolSize)
+	}
+	return clientOptions
+}
+
+// Client returns the managed client, connecting on the first call. A client is
+// only kept once a server answered its ping, so failed attempts can be retried.
+func (m *ClientManager) Client(ctx context.Context) (*mongo.Client, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.closed {
+		return nil, ErrClientClosed
+	}
+	if m.client != nil {
+		return m.client, nil
+	}
+	if m.options.URI == "" {
+		return nil, errors.New("a mongodb uri is required")
+	}
+
+	m.logger.Debug("a client isn't available, creating a new one")
+	client, err := mongo.Connect(ctx, m.driverOptions())
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to mongodb: %w", err)
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, m.options.ConnectTimeout)
+	defer cancel()
+	if err = client.Ping(pingCtx, readpref.Primary()); err != nil {
+		m.logger.Warn("mongodb didn't answer the first ping", zap.Error(err))
+		_ = client.Disconnect(context.Background())
+		return nil, fmt.Errorf("unable to reach mongodb: %w", err)
+	}
+
+	m.logger.Info("connected to mongodb")
+	m.client = client
+	return client, nil
+}
+
+// Health pings the primary server, it fails if the manager isn't connected.
+func (m *ClientManager) Health(ctx context.Context) error {
+	m.mutex.Lock()
+	client, closed := m.client, m.closed
+	m.mutex.Unlock()
+	if closed {
+		return ErrClientClosed
+	}
+	if client == nil {
+		return errors.New("mongo client manager isn't connected")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.options.HealthTimeout)
+	defer cancel()
+	return client.Ping(ctx, readpref.Primary())
+}
+
+// Close disconnects the client, if any. Closing twice is fine.
+func (m *ClientManager) Close(ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	if m.client == nil {
+		return nil
+	}
+
+	m.logger.Info("disconnecting from mongodb")
+	err := m.client.Disconnect(ctx)
+	m.client = nil
+	return err
+}
+
+// newServerMonitor creates a monitor that logs whenever a server is lost or (re)connected.
+func newServerMonitor(logger *zap.Logger) *event.ServerMonitor {
+	return &event.ServerMonitor{
+		ServerDescriptionChanged: func(changed *event.ServerDescriptionChangedEvent) {
+			wasKnown := changed.PreviousDescription.Kind != description.Unknown
+			isKnown := changed.NewDescription.Kind != description.Unknown
+			serverLogger := logger.With(
+				zap.String("address", changed.Address.String()),
+				zap.String("kind", changed.NewDescription.Kind.String()),
+			)
+			switch {
+			case wasKnown && !isKnown:
+				serverLogger.Warn("lost connection to a mongodb server", zap.Error(changed.NewDescription.LastError))
+			case !wasKnown && isKnown:
+				serverLogger.Info("connected to a mongodb server")
+			}
+		},
+		ServerHeartbeatFailed: func(failed *event.ServerHeartbeatFailedEvent) {
+			logger.Debug("mongodb heartbeat failed",
+				zap.String("connectionId", failed.ConnectionID),
+				zap.Error(failed.Failure),
+			)
+		},
+	}
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/address"
+	"go.mongodb.org/mongo-driver/mongo/description"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// unreachableUri points to a port nobody listens to.
+const unreachableUri = "mongodb://127.0.0.1:1"
+
+// givenAnUnreachableManager creates a ClientManager that can never connect, and fails fast doing so.
+func givenAnUnreachableManager() *ClientManager {
+	return NewClientManager(ClientOptions{
+		URI:                    unreachableUri,
+		ConnectTimeout:         100 * time.Millisecond,
+		ServerSelectionTimeout: 100 * time.Millisecond,
+	})
+}
+
+// TestClientOptionsDefaults verifies unset options fall back to their defaults.
+func TestClientOptionsDefaults(t *testing.T) {
+	// Act
+	got := ClientOptions{URI: unreachableUri, MaxPoolSize: 7}.withDefaults()
+
+	// Assert
+	assert.Equal(t, DefaultConnectTimeout, got.ConnectTimeout)
+	assert.Equal(t, DefaultServerSelectionTimeout, got.ServerSelectionTimeout)
+	assert.Equal(t, DefaultHealthTimeout, got.HealthTimeout)
+	assert.EqualValues(t, 7, got.MaxPoolSize)
+	assert.NotNil(t, got.Logger)
+}
+
+// TestDriverOptionsCarryTimeoutsAndPoolSizes verifies the manager's options reach the driver.
+func TestDriverOptionsCarryTimeoutsAndPoolSizes(t *testing.T) {
+	// Arrange
+	manager := NewClientManager(ClientOptions{
+		URI:                    unreachableUri,
+		ConnectTimeout:         time.Second,
+		ServerSelectionTimeout: 2 * time.Second,
+		MinPoolSize:            2,
+		MaxPoolSize:            8,
+	})
+
+	// Act
+	got := manager.driverOptions()
+
+	// Assert
+	assert.Equal(t, time.Second, *got.ConnectTimeout)
+	assert.Equal(t, 2*time.Second, *got.ServerSelectionTimeout)
+	assert.EqualValues(t, 2, *got.MinPoolSize)
+	assert.EqualValues(t, 8, *got.MaxPoolSize)
+	assert.NotNil(t, got.ServerMonitor)
+}
+
+// TestClientFailsFastWhenMongoIsUnreachable verifies the configured timeouts are honored instead of handing out a dead client.
+func TestClientFailsFastWhenMongoIsUnreachable(t *testing.T) {
+	// Arrange
+	manager := givenAnUnreachableManager()
+	started := time.Now()
+
+	// Act
+	client, err := manager.Client(context.Background())
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, client)
+	assert.Less(t, time.Since(started), 5*time.Second, "the connect timeout should have been honored")
+	assert.Error(t, manager.Health(context.Background()), "an unconnected manager isn't healthy")
+}
+
+// TestClientIsSafeForConcurrentUse verifies many goroutines can ask for a client at once.
+func TestClientIsSafeForConcurrentUse(t *testing.T) {
+	// Arrange
+	manager := givenAnUnreachableManager()
+	var wg sync.WaitGroup
+
+	// Act
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := manager.Client(context.Background())
+			assert.Error(t, err)
+		}()
+	}
+	wg.Wait()
+	closeErr := manager.Close(context.Background())
+
+	// Assert
+	assert.Nil(t, closeErr)
+}
+
+// TestClosedManagersCantBeUsed verifies a closed manager refuses to hand out clients.
+func TestClosedManagersCantBeUsed(t *testing.T) {
+	// Arrange
+	manager := givenAnUnreachableManager()
+
+	// Act
+	firstClose := manager.Close(context.Background())
+	secondClose := manager.Close(context.Background())
+	_, clientErr := manager.Client(context.Background())
+	healthErr := manager.Health(context.Background())
+
+	// Assert
+	assert.Nil(t, firstClose)
+	assert.Nil(t, secondClose)
+	assert.ErrorIs(t, clientErr, ErrClientClosed)
+	assert.ErrorIs(t, healthErr, ErrClientClosed)
+}
+
+// TestServerMonitorLogsLostAndRecoveredConnections verifies reconnections show up in the logs.
+func TestServerMonitorLogsLostAndRecoveredConnections(t *testing.T) {
+	// Arrange
+	core, logs := observer.New(zapcore.DebugLevel)
+	monitor := newServerMonitor(zap.New(core))
+	server := address.Address("mongo:27017")
+	standalone := description.Server{Kind: description.Standalone}
+	unknown := description.Server{Kind: description.Unknown, LastError: errors.New("connection refused")}
+
+	// Act
+	monitor.ServerDescriptionChanged(&event.ServerDescriptionChangedEvent{Address: server, PreviousDescription: unknown, NewDescription: standalone})
+	monitor.ServerDescriptionChanged(&event.ServerDescriptionChangedEvent{Address: server, PreviousDescription: standalone, NewDescription: standalone})
+	monitor.ServerDescriptionChanged(&event.ServerDescriptionChangedEvent{Address: server, PreviousDescription: standalone, NewDescription: unknown})
+	monitor.ServerHeartbeatFailed(&event.ServerHeartbeatFailedEvent{ConnectionID: "mongo:27017[-1]", Failure: unknown.LastError})
+	monitor.ServerDescriptionChanged(&event.ServerDescriptionChangedEvent{Address: server, PreviousDescription: unknown, NewDescription: standalone})
+
+	// Assert
+	got := logs.AllUntimed()
+	if assert.Len(t, got, 4) {
+		assert.Equal(t, "connected to a mongodb server", got[0].Message)
+		assert.Equal(t, "lost connection to a mongodb server", got[1].Message)
+		assert.Equal(t, zapcore.WarnLevel, got[1].Level)
+		assert.Equal(t, "mongodb heartbeat failed", got[2].Message)
+		assert.Equal(t, "connected to a mongodb server", got[3].Message)
+	}
+}
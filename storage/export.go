@@ -0,0 +1,275 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Format is a file format documents can be exported to.
+type Format string
+
+const (
+	// FormatJSONL writes one canonical extended JSON document per line.
+	FormatJSONL Format = "jsonl"
+	// FormatCSV writes one row per document, following a column mapping.
+	FormatCSV Format = "csv"
+	// FormatBSON writes documents back to back, just like mongodump's .bson files.
+	FormatBSON Format = "bson"
+)
+
+// CSVListSeparator joins the values of arrays within a CSV cell.
+const CSVListSeparator = ";"
+
+// maxJSONLineSize is the biggest line a JSONL file may have, mongodb documents are capped at 16MB.
+const maxJSONLineSize = 16 * 1024 * 1024
+
+// Column maps a document's field into a CSV column.
+type Column struct {
+	// Header is the column's name.
+	Header string
+	// Field is the document's field, nested fields are separated by dots.
+	Field string
+}
+
+// ExportOptions configures Export.
+type ExportOptions struct {
+	// Format defaults to FormatJSONL.
+	Format Format
+	// Filter selects which documents are exported, nil exports everything.
+	Filter interface{}
+	// After resumes an export, only documents whose _id comes after it are exported.
+	After interface{}
+	// Columns is the mapping used by FormatCSV.
+	Columns []Column
+	// BatchSize is how many documents are fetched at a time, zero keeps the driver's default.
+	BatchSize int32
+}
+
+// ExportResult tells how an export went.
+type ExportResult struct {
+	// Exported is how many documents were written.
+	Exported int64
+	// LastID is the _id of the last document written, feed it to ExportOptions.After to resume.
+	LastID interface{}
+}
+
+// Export streams the documents of a collection, sorted by _id, into a writer.
+// Resumed exports (those with After set) don't repeat the CSV header.
+func Export(ctx context.Context, collection *mongo.Collection, w io.Writer, opts ExportOptions) (ExportResult, error) {
+	writer, err := NewDocumentWriter(w, opts.Format, opts.Columns, opts.After == nil)
+	if err != nil {
+		return ExportResult{}, err
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: IDField, Value: 1}})
+	if opts.BatchSize > 0 {
+		findOptions.SetBatchSize(opts.BatchSize)
+	}
+	cursor, err := collection.Find(ctx, exportFilter(opts), findOptions)
+	if err != nil {
+		return ExportResult{}, err
+	}
+	return exportCursor(ctx, cursor, writer)
+}
+
+// exportFilter combines the filter of an export with where it should resume from.
+func exportFilter(opts ExportOptions) interface{} {
+	filter := opts.Filter
+	if filter == nil {
+		filter = bson.M{}
+	}
+	if opts.After == nil {
+		return filter
+	}
+	return bson.M{"$and": bson.A{filter, bson.M{IDField: bson.M{"$gt": opts.After}}}}
+}
+
+// exportCursor writes every document of a cursor. The result is accurate even
+// when something fails midway, so the export can be resumed.
+func exportCursor(ctx context.Context, cursor *mongo.Cursor, writer DocumentWriter) (ExportResult, error) {
+	var result ExportResult
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		if err := writer.Write(cursor.Current); err != nil {
+			return result, firstError(err, writer.Flush())
+		}
+		id, err := idOf(cursor.Current)
+		if err != nil {
+			return result, firstError(err, writer.Flush())
+		}
+		result.Exported++
+		result.LastID = id
+	}
+	return result, firstError(cursor.Err(), writer.Flush())
+}
+
+// firstError returns the first error that isn't nil.
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// idOf gets the _id of a document.
+func idOf(document bson.Raw) (interface{}, error) {
+	raw, err := document.LookupErr(IDField)
+	if err != nil {
+		return nil, fmt.Errorf("document has no %v: %w", IDField, err)
+	}
+	var id interface{}
+	err = raw.Unmarshal(&id)
+	return id, err
+}
+
+// DocumentWriter writes documents into a file format.
+type DocumentWriter interface {
+	// Write writes a single document.
+	Write(document bson.Raw) error
+	// Flush makes sure everything written reached the underlying writer.
+	Flush() error
+}
+
+// NewDocumentWriter creates a DocumentWriter for a format, columns are only used
+// (and required) by FormatCSV and header tells if the CSV header should be written.
+func NewDocumentWriter(w io.Writer, format Format, columns []Column, header bool) (DocumentWriter, error) {
+	switch format {
+	case FormatJSONL, "":
+		return &jsonlWriter{w: bufio.NewWriter(w)}, nil
+	case FormatBSON:
+		return &bsonWriter{w: bufio.NewWriter(w)}, nil
+	case FormatCSV:
+		if len(columns) == 0 {
+			return nil, errors.New("csv exports need a column mapping")
+		}
+		return &csvWriter{w: csv.NewWriter(w), columns: columns, pendingHeader: header}, nil
+	}
+	return nil, fmt.Errorf("unknown export format %v", format)
+}
+
+// jsonlWriter writes documents as canonical extended JSON, one per line.
+type jsonlWriter struct {
+	w *bufio.Writer
+}
+
+// Write writes a document as a line of canonical extended JSON.
+func (j *jsonlWriter) Write(document bson.Raw) error {
+	line, err := bson.MarshalExtJSON(document, true, false)
+	if err != nil {
+		return err
+	}
+	if _, err = j.w.Write(line); err != nil {
+		return err
+	}
+	return j.w.WriteByte('\n')
+}
+
+// Flush flushes the buffered lines.
+func (j *jsonlWriter) Flush() error {
+	return j.w.Flush()
+}
+
+// bsonWriter writes documents back to back.
+type bsonWriter struct {
+	w *bufio.Writer
+}
+
+// Write writes the document's bytes as they are.
+func (b *bsonWriter) Write(document bson.Raw) error {
+	_, err := b.w.Write(document)
+	return err
+}
+
+// Flush flushes the buffered documents.
+func (b *bsonWriter) Flush() error {
+	return b.w.Flush()
+}
+
+// csvWriter writes one row per document, following a column mapping.
+type csvWriter struct {
+	w             *csv.Writer
+	columns       []Column
+	pendingHeader bool
+}
+
+// writeHeader writes the header, unless it was already written.
+func (c *csvWriter) writeHeader() error {
+	if !c.pendingHeader {
+		return nil
+	}
+	c.pendingHeader = false
+	header := make([]string, len(c.columns))
+	for idx, column := range c.columns {
+		header[idx] = column.Header
+	}
+	return c.w.Write(header)
+}
+
+// Write writes a document as a row, writing the header first if needed.
+func (c *csvWriter) Write(document bson.Raw) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	row := make([]string, len(c.columns))
+	for idx, column := range c.columns {
+		value, err := document.LookupErr(strings.Split(column.Field, ".")...)
+		if err != nil {
+			continue // missing fields are empty cells
+		}
+		row[idx] = csvCell(value)
+	}
+	return c.w.Write(row)
+}
+
+// Flush flushes the buffered rows, an export without documents still gets its header.
+func (c *csvWriter) Flush() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// csvCell renders a bson value as a CSV cell.
+func csvCell(value bson.RawValue) string {
+	switch value.Type {
+	case bsontype.String:
+		return value.StringValue()
+	case bsontype.ObjectID:
+		return value.ObjectID().Hex()
+	case bsontype.DateTime:
+		return value.Time().UTC().Format(time.RFC3339Nano)
+	case bsontype.Int32:
+		return strconv.FormatInt(int64(value.Int32()), 10)
+	case bsontype.Int64:
+		return strconv.FormatInt(value.Int64(), 10)
+	case bsontype.Double:
+		return strconv.FormatFloat(value.Double(), 'f', -1, 64)
+	case bsontype.Boolean:
+		return strconv.FormatBool(value.Boolean())
+	case bsontype.Null, bsontype.Undefined:
+		return ""
+	case bsontype.Array:
+		values, _ := value.Array().Values()
+		cells := make([]string, len(values))
+		for idx, item := range values {
+			cells[idx] = csvCell(item)
+		}
+		return strings.Join(cells, CSVListSeparator)
+	}
+	return value.String()
+}
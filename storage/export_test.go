@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// givenSomeDocuments creates documents with increasing ids.
+func givenSomeDocuments(amount int) []interface{} {
+	documents := make([]interface{}, amount)
+	for i := range documents {
+		documents[i] = bson.D{
+			{Key: IDField, Value: int32(i + 1)},
+			{Key: "title", Value: "Necronomicon"},
+			{Key: "meta", Value: bson.D{{Key: "pages", Value: 666}, {Key: "addedAt", Value: time.Date(2022, 6, 12, 9, 4, 10, 0, time.UTC)}}},
+			{Key: "tags", Value: bson.A{"Horror", "Forbidden"}},
+		}
+	}
+	return documents
+}
+
+// exportDocuments exports documents into a buffer through a cursor, just like Export does.
+func exportDocuments(t *testing.T, documents []interface{}, format Format, columns ...Column) (*bytes.Buffer, ExportResult) {
+	var buffer bytes.Buffer
+	cursor, err := mongo.NewCursorFromDocuments(documents, nil, nil)
+	require.Nil(t, err)
+	writer, err := NewDocumentWriter(&buffer, format, columns, true)
+	require.Nil(t, err)
+	result, err := exportCursor(context.Background(), cursor, writer)
+	require.Nil(t, err)
+	return &buffer, result
+}
+
+// TestExportsRoundTripThroughTheReader verifies JSONL and BSON exports can be read back unchanged.
+func TestExportsRoundTripThroughTheReader(t *testing.T) {
+	scenarios := []Format{FormatJSONL, FormatBSON}
+	documents := givenSomeDocuments(3)
+
+	for _, format := range scenarios {
+		// Arrange
+		buffer, result := exportDocuments(t, documents, format)
+		reader, err := NewDocumentReader(buffer, format)
+		require.Nil(t, err)
+
+		// Act
+		var got []bson.Raw
+		for {
+			document, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			require.Nil(t, err, "reading %v should work", format)
+			got = append(got, document)
+		}
+
+		// Assert
+		assert.EqualValues(t, 3, result.Exported)
+		assert.EqualValues(t, 3, result.LastID)
+		require.Len(t, got, 3)
+		for idx, document := range documents {
+			expected, _ := bson.Marshal(document)
+			assert.Equal(t, bson.Raw(expected), got[idx], "%v should keep documents intact", format)
+		}
+	}
+}
+
+// TestCSVExportsFollowTheColumnMapping verifies the CSV writer.
+func TestCSVExportsFollowTheColumnMapping(t *testing.T) {
+	// Arrange
+	columns := []Column{
+		{Header: "id", Field: IDField},
+		{Header: "pages", Field: "meta.pages"},
+		{Header: "added", Field: "meta.addedAt"},
+		{Header: "tags", Field: "tags"},
+		{Header: "missing", Field: "isbn"},
+	}
+
+	// Act
+	got, _ := exportDocuments(t, givenSomeDocuments(2), FormatCSV, columns...)
+
+	// Assert
+	assert.Equal(t, "id,pages,added,tags,missing\n"+
+		"1,666,2022-06-12T09:04:10Z,Horror;Forbidden,\n"+
+		"2,666,2022-06-12T09:04:10Z,Horror;Forbidden,\n", got.String())
+}
+
+// TestCSVExportsWithoutDocumentsStillHaveAHeader verifies empty exports keep their columns.
+func TestCSVExportsWithoutDocumentsStillHaveAHeader(t *testing.T) {
+	// Act
+	got, result := exportDocuments(t, nil, FormatCSV, Column{Header: "id", Field: IDField})
+
+	// Assert
+	assert.Equal(t, "id\n", got.String())
+	assert.Zero(t, result.Exported)
+	assert.Nil(t, result.LastID)
+}
+
+// TestWritersRejectBadSettings verifies unknown formats and CSVs without columns are errors.
+func TestWritersRejectBadSettings(t *testing.T) {
+	// Act
+	_, unknownErr := NewDocumentWriter(io.Discard, "xml", nil, true)
+	_, csvErr := NewDocumentWriter(io.Discard, FormatCSV, nil, true)
+	_, readerErr := NewDocumentReader(bytes.NewReader(nil), FormatCSV)
+
+	// Assert
+	assert.Error(t, unknownErr)
+	assert.Error(t, csvErr)
+	assert.Error(t, readerErr, "csv exports can't be read back")
+}
+
+// TestLastExportedIdTellsWhereToResume verifies an interrupted export can be resumed.
+func TestLastExportedIdTellsWhereToResume(t *testing.T) {
+	// Arrange
+	id := primitive.NewObjectID()
+	documents := append(givenSomeDocuments(2), bson.M{IDField: id})
+	buffer, _ := exportDocuments(t, documents, FormatJSONL)
+
+	// Act
+	got, err := LastExportedID(buffer, FormatJSONL)
+	empty, emptyErr := LastExportedID(bytes.NewReader(nil), FormatBSON)
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, id, got)
+	assert.Nil(t, emptyErr)
+	assert.Nil(t, empty)
+}
+
+// TestTruncatedBsonFilesAreErrors verifies half written documents aren't silently dropped.
+func TestTruncatedBsonFilesAreErrors(t *testing.T) {
+	// Arrange
+	buffer, _ := exportDocuments(t, givenSomeDocuments(1), FormatBSON)
+	truncated := buffer.Bytes()[:buffer.Len()-3]
+
+	// Act
+	_, err := LastExportedID(bytes.NewReader(truncated), FormatBSON)
+
+	// Assert
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+// TestExportFilterResumesAfterTheLastId verifies the filter used by Export.
+func TestExportFilterResumesAfterTheLastId(t *testing.T) {
+	// Arrange
+	filter := bson.M{"author": "H.P. Lovecraft"}
+
+	// Act
+	fresh := exportFilter(ExportOptions{Filter: filter})
+	everything := exportFilter(ExportOptions{})
+	resumed := exportFilter(ExportOptions{Filter: filter, After: int32(42)})
+
+	// Assert
+	assert.Equal(t, filter, fresh)
+	assert.Equal(t, bson.M{}, everything)
+	assert.Equal(t, bson.M{"$and": bson.A{filter, bson.M{IDField: bson.M{"$gt": int32(42)}}}}, resumed)
+}
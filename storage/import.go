@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DefaultImportBatchSize is how many documents Import inserts at a time.
+const DefaultImportBatchSize = 500
+
+// DocumentReader reads back the documents written by a DocumentWriter. Only
+// FormatJSONL and FormatBSON can be read, CSV exports are lossy.
+type DocumentReader struct {
+	format Format
+	bsonIn io.Reader
+	lines  *bufio.Scanner
+}
+
+// NewDocumentReader creates a DocumentReader for a format.
+func NewDocumentReader(r io.Reader, format Format) (*DocumentReader, error) {
+	switch format {
+	case FormatJSONL, "":
+		lines := bufio.NewScanner(r)
+		lines.Buffer(make([]byte, 0, 64*1024), maxJSONLineSize)
+		return &DocumentReader{format: FormatJSONL, lines: lines}, nil
+	case FormatBSON:
+		return &DocumentReader{format: FormatBSON, bsonIn: bufio.NewReader(r)}, nil
+	}
+	return nil, fmt.Errorf("documents can't be read from %v files", format)
+}
+
+// Next reads the next document, io.EOF is returned once there are no more documents.
+func (r *DocumentReader) Next() (bson.Raw, error) {
+	if r.format == FormatBSON {
+		document, err := bson.NewFromIOReader(r.bsonIn)
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("truncated bson file: %w", err)
+		}
+		return document, err
+	}
+
+	for r.lines.Scan() {
+		line := r.lines.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var document bson.Raw
+		err := bson.UnmarshalExtJSON(line, true, &document)
+		return document, err
+	}
+	if err := r.lines.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// LastExportedID reads an export and tells the _id of its last document, so an
+// interrupted export can be resumed through ExportOptions.After. It is nil for
+// empty exports.
+func LastExportedID(r io.Reader, format Format) (interface{}, error) {
+	reader, err := NewDocumentReader(r, format)
+	if err != nil {
+		return nil, err
+	}
+	var last bson.Raw
+	for {
+		document, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		last = document
+	}
+	if last == nil {
+		return nil, nil
+	}
+	return idOf(last)
+}
+
+// Import inserts every document of an export into a collection, as they are,
+// and returns how many were inserted. It is meant to restore snapshots.
+func Import(ctx context.Context, collection *mongo.Collection, reader *DocumentReader) (int64, error) {
+	var imported int64
+	batch := make([]interface{}, 0, DefaultImportBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		result, err := collection.InsertMany(ctx, batch)
+		if result != nil {
+			imported += int64(len(result.InsertedIDs))
+		}
+		batch = batch[:0]
+		return err
+	}
+
+	for {
+		document, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return imported, flush()
+		}
+		if err != nil {
+			return imported, firstError(flush(), err)
+		}
+		batch = append(batch, document)
+		if len(batch) == DefaultImportBatchSize {
+			if err = flush(); err != nil {
+				return imported, err
+			}
+		}
+	}
+}
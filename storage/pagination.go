@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// DefaultPageSize is used whenever a PageRequest doesn't ask for a specific size.
+	DefaultPageSize = 20
+	// MaxPageSize caps how many documents a single page may hold.
+	MaxPageSize = 100
+	// IDField is the field every document has and that breaks ties between equal sort keys.
+	IDField = "_id"
+)
+
+// ErrInvalidPageToken is returned when a page token can't be decoded or doesn't
+// belong to the listing it was sent to.
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// PageRequest describes which page of a listing should be fetched.
+type PageRequest struct {
+	// Size is how many items the page should hold, it defaults to DefaultPageSize and is capped at MaxPageSize.
+	Size int
+	// Token is the opaque token returned by the previous page, empty for the first page.
+	Token string
+	// SortBy is the field the listing is sorted by, it defaults to IDField.
+	SortBy string
+	// Descending flips the listing's order.
+	Descending bool
+	// IncludeTotal asks for the total amount of documents matching the listing, it costs an extra query.
+	IncludeTotal bool
+}
+
+// Page is a single page of a listing.
+type Page[T any] struct {
+	Items []T
+	// NextToken fetches the following page, it is empty when this is the last page.
+	NextToken string
+	// Total is only filled when the PageRequest asked for it.
+	Total *int64
+}
+
+// PageCursor is what a page token carries: the position of the last item of a page.
+type PageCursor struct {
+	SortBy     string      `bson:"s"`
+	Descending bool        `bson:"d,omitempty"`
+	Value      interface{} `bson:"v,omitempty"`
+	ID         interface{} `bson:"i"`
+}
+
+// Normalize fills in the defaults of a PageRequest and clamps its size.
+func (r PageRequest) Normalize() PageRequest {
+	if r.Size <= 0 {
+		r.Size = DefaultPageSize
+	}
+	if r.Size > MaxPageSize {
+		r.Size = MaxPageSize
+	}
+	if r.SortBy == "" {
+		r.SortBy = IDField
+	}
+	return r
+}
+
+// Cursor decodes the request's token, nil is returned for the first page.
+// Tokens created for a different sorting are rejected.
+func (r PageRequest) Cursor() (*PageCursor, error) {
+	if r.Token == "" {
+		return nil, nil
+	}
+	r = r.Normalize()
+	cursor, err := DecodePageToken(r.Token)
+	if err != nil {
+		return nil, err
+	}
+	if cursor.SortBy != r.SortBy || cursor.Descending != r.Descending {
+		return nil, fmt.Errorf("%w: token was created for a listing sorted by %v", ErrInvalidPageToken, cursor.SortBy)
+	}
+	return &cursor, nil
+}
+
+// EncodePageToken turns a cursor into an opaque, url safe, token.
+func EncodePageToken(cursor PageCursor) (string, error) {
+	raw, err := bson.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// DecodePageToken recovers the cursor within a token created by EncodePageToken.
+func DecodePageToken(token string) (PageCursor, error) {
+	var cursor PageCursor
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor, fmt.Errorf("%w: %v", ErrInvalidPageToken, err)
+	}
+	if err = bson.Unmarshal(raw, &cursor); err != nil {
+		return cursor, fmt.Errorf("%w: %v", ErrInvalidPageToken, err)
+	}
+	if cursor.SortBy == "" || cursor.ID == nil {
+		return cursor, fmt.Errorf("%w: token is missing its position", ErrInvalidPageToken)
+	}
+	return cursor, nil
+}
+
+// CursorFilter builds the filter that skips everything up to, and including, the cursor's position.
+// A nil Value stands for documents without the sort field, which mongodb sorts
+// as null, before every other value. Comparing with null only ever matches
+// nulls, so nulls are looked for with an equality instead.
+func CursorFilter(cursor PageCursor) bson.M {
+	operator := "$gt"
+	if cursor.Descending {
+		operator = "$lt"
+	}
+	if cursor.SortBy == IDField {
+		return bson.M{IDField: bson.M{operator: cursor.ID}}
+	}
+	sameValue := bson.M{cursor.SortBy: cursor.Value, IDField: bson.M{operator: cursor.ID}}
+	switch {
+	case cursor.Value == nil && cursor.Descending:
+		return sameValue
+	case cursor.Value == nil:
+		return bson.M{"$or": bson.A{bson.M{cursor.SortBy: bson.M{"$ne": nil}}, sameValue}}
+	case cursor.Descending:
+		return bson.M{"$or": bson.A{bson.M{cursor.SortBy: bson.M{operator: cursor.Value}}, sameValue, bson.M{cursor.SortBy: nil}}}
+	default:
+		return bson.M{"$or": bson.A{bson.M{cursor.SortBy: bson.M{operator: cursor.Value}}, sameValue}}
+	}
+}
+
+// sortFor builds the sort document for a request, ties are always broken by the IDField.
+func sortFor(request PageRequest) bson.D {
+	direction := 1
+	if request.Descending {
+		direction = -1
+	}
+	if request.SortBy == IDField {
+		return bson.D{{Key: IDField, Value: direction}}
+	}
+	return bson.D{{Key: request.SortBy, Value: direction}, {Key: IDField, Value: direction}}
+}
+
+// cursorFor builds the cursor pointing at a raw document, with a nil Value when
+// the document doesn't have the sort field.
+func cursorFor(request PageRequest, document bson.Raw) (PageCursor, error) {
+	cursor := PageCursor{SortBy: request.SortBy, Descending: request.Descending}
+	id, err := document.LookupErr(IDField)
+	if err != nil {
+		return cursor, fmt.Errorf("document has no %v: %w", IDField, err)
+	}
+	cursor.ID = id
+	if request.SortBy != IDField {
+		value, err := document.LookupErr(strings.Split(request.SortBy, ".")...)
+		if err == nil && value.Type != bsontype.Null {
+			cursor.Value = value
+		}
+	}
+	return cursor, nil
+}
+
+// FindPage fetches a single page of the documents matching a filter. The filter
+// may be nil to list the whole collection.
+func FindPage[T any](ctx context.Context, collection *mongo.Collection, filter interface{}, request PageRequest) (Page[T], error) {
+	var page Page[T]
+	request = request.Normalize()
+	if filter == nil {
+		filter = bson.M{}
+	}
+
+	if request.IncludeTotal {
+		total, err := collection.CountDocuments(ctx, filter)
+		if err != nil {
+			return page, err
+		}
+		page.Total = &total
+	}
+
+	pageFilter := filter
+	cursor, err := request.Cursor()
+	if err != nil {
+		return page, err
+	}
+	if cursor != nil {
+		pageFilter = bson.M{"$and": bson.A{filter, CursorFilter(*cursor)}}
+	}
+
+	findOptions := options.Find().
+		SetSort(sortFor(request)).
+		SetLimit(int64(request.Size + 1)) // one extra document tells us if there's a next page
+	results, err := collection.Find(ctx, pageFilter, findOptions)
+	if err != nil {
+		return page, err
+	}
+	defer results.Close(ctx)
+
+	var last bson.Raw
+	page.Items = make([]T, 0, request.Size)
+	for results.Next(ctx) {
+		if len(page.Items) == request.Size {
+			token, err := nextToken(request, last)
+			if err != nil {
+				return page, err
+			}
+			page.NextToken = token
+			break
+		}
+		var item T
+		if err = results.Decode(&item); err != nil {
+			return page, err
+		}
+		page.Items = append(page.Items, item)
+		last = append(last[:0], results.Current...)
+	}
+	return page, results.Err()
+}
+
+// nextToken creates the token that continues a listing after a document.
+func nextToken(request PageRequest, last bson.Raw) (string, error) {
+	cursor, err := cursorFor(request, last)
+	if err != nil {
+		return "", err
+	}
+	return EncodePageToken(cursor)
+}
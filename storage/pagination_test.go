@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TestNormalizeFillsDefaultsAndClampsSize verifies the defaults of a PageRequest.
+func TestNormalizeFillsDefaultsAndClampsSize(t *testing.T) {
+	scenarios := map[int]int{
+		-1:               DefaultPageSize,
+		0:                DefaultPageSize,
+		5:                5,
+		MaxPageSize:      MaxPageSize,
+		MaxPageSize * 10: MaxPageSize,
+	}
+
+	for size, expected := range scenarios {
+		// Act
+		got := PageRequest{Size: size}.Normalize()
+
+		// Assert
+		assert.Equal(t, expected, got.Size, "size %v should be normalized", size)
+		assert.Equal(t, IDField, got.SortBy, "listings should be sorted by id by default")
+	}
+}
+
+// TestPageTokensRoundTrip verifies a cursor survives being turned into a token and back.
+func TestPageTokensRoundTrip(t *testing.T) {
+	// Arrange
+	expected := PageCursor{SortBy: "title", Descending: true, Value: "Dagon", ID: primitive.NewObjectID()}
+
+	// Act
+	token, err := EncodePageToken(expected)
+	require.Nil(t, err)
+	got, err := DecodePageToken(token)
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, expected, got)
+	assert.NotContains(t, token, "Dagon", "tokens should be opaque")
+}
+
+// TestDecodingGarbageIsAnInvalidToken verifies garbage tokens are rejected.
+func TestDecodingGarbageIsAnInvalidToken(t *testing.T) {
+	scenarios := []string{"not a token", "bm9wZQ", "AAAA"}
+
+	for _, token := range scenarios {
+		// Act
+		_, err := DecodePageToken(token)
+
+		// Assert
+		assert.ErrorIs(t, err, ErrInvalidPageToken, "%v should be rejected", token)
+	}
+}
+
+// TestTokensFromAnotherSortingAreRejected verifies a token can't be reused with a different sorting.
+func TestTokensFromAnotherSortingAreRejected(t *testing.T) {
+	// Arrange
+	token, _ := EncodePageToken(PageCursor{SortBy: "title", Value: "Dagon", ID: primitive.NewObjectID()})
+
+	// Act
+	_, err := PageRequest{Token: token, SortBy: "author"}.Cursor()
+	_, errDescending := PageRequest{Token: token, SortBy: "title", Descending: true}.Cursor()
+	cursor, errSameSorting := PageRequest{Token: token, SortBy: "title"}.Cursor()
+
+	// Assert
+	assert.ErrorIs(t, err, ErrInvalidPageToken)
+	assert.ErrorIs(t, errDescending, ErrInvalidPageToken)
+	assert.Nil(t, errSameSorting)
+	assert.Equal(t, "Dagon", cursor.Value)
+}
+
+// TestCursorFilterSkipsEverythingUpToTheCursor verifies the filters used to resume a listing.
+func TestCursorFilterSkipsEverythingUpToTheCursor(t *testing.T) {
+	// Arrange
+	id := primitive.NewObjectID()
+
+	// Act
+	byId := CursorFilter(PageCursor{SortBy: IDField, ID: id})
+	byTitle := CursorFilter(PageCursor{SortBy: "title", Descending: true, Value: "Dagon", ID: id})
+
+	// Assert
+	assert.Equal(t, bson.M{IDField: bson.M{"$gt": id}}, byId)
+	assert.Equal(t, bson.M{"$or": bson.A{
+		bson.M{"title": bson.M{"$lt": "Dagon"}},
+		bson.M{"title": "Dagon", IDField: bson.M{"$lt": id}},
+		bson.M{"title": nil},
+	}}, byTitle, "nulls come last when descending")
+}
+
+// TestCursorFilterResumesAfterNulls verifies listings resume after documents without the sort field.
+func TestCursorFilterResumesAfterNulls(t *testing.T) {
+	// Arrange
+	id := primitive.NewObjectID()
+
+	// Act
+	ascending := CursorFilter(PageCursor{SortBy: "title", ID: id})
+	descending := CursorFilter(PageCursor{SortBy: "title", Descending: true, ID: id})
+
+	// Assert
+	assert.Equal(t, bson.M{"$or": bson.A{
+		bson.M{"title": bson.M{"$ne": nil}},
+		bson.M{"title": nil, IDField: bson.M{"$gt": id}},
+	}}, ascending, "nulls come first when ascending")
+	assert.Equal(t, bson.M{"title": nil, IDField: bson.M{"$lt": id}}, descending)
+}
+
+// TestSortAlwaysBreaksTiesById verifies listings are stable even when sort keys repeat.
+func TestSortAlwaysBreaksTiesById(t *testing.T) {
+	// Act
+	got := sortFor(PageRequest{SortBy: "author", Descending: true})
+
+	// Assert
+	assert.Equal(t, bson.D{{Key: "author", Value: -1}, {Key: IDField, Value: -1}}, got)
+}
+
+// TestCursorForRawDocumentsSurvivesTheToken verifies tokens built from raw documents keep their values.
+func TestCursorForRawDocumentsSurvivesTheToken(t *testing.T) {
+	// Arrange
+	id := primitive.NewObjectID()
+	document, _ := bson.Marshal(bson.M{IDField: id, "meta": bson.M{"author": "H.P. Lovecraft"}})
+	request := PageRequest{SortBy: "meta.author"}.Normalize()
+
+	// Act
+	token, err := nextToken(request, document)
+	require.Nil(t, err)
+	request.Token = token
+	got, err := request.Cursor()
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, id, got.ID)
+	assert.Equal(t, "H.P. Lovecraft", got.Value)
+}
+
+// TestCursorForDocumentsWithoutTheSortFieldIsNull verifies documents missing the sort key, or holding a null, point at null.
+func TestCursorForDocumentsWithoutTheSortFieldIsNull(t *testing.T) {
+	scenarios := []bson.M{
+		{IDField: primitive.NewObjectID()},
+		{IDField: primitive.NewObjectID(), "title": nil},
+	}
+	for _, scenario := range scenarios {
+		// Arrange
+		document, _ := bson.Marshal(scenario)
+		request := PageRequest{SortBy: "title"}.Normalize()
+
+		// Act
+		token, err := nextToken(request, document)
+		require.Nil(t, err)
+		request.Token = token
+		got, err := request.Cursor()
+
+		// Assert
+		require.Nil(t, err)
+		assert.Equal(t, scenario[IDField], got.ID)
+		assert.Nil(t, got.Value)
+	}
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultTransactionRetries is how many times a transaction is retried on
+	// transient errors after its first attempt, and a commit whose outcome is unknown.
+	DefaultTransactionRetries = 3
+	// transientTransactionError labels errors after which the whole transaction can be retried.
+	transientTransactionError = "TransientTransactionError"
+	// unknownTransactionCommitResult labels commit errors after which the commit alone can be retried.
+	unknownTransactionCommitResult = "UnknownTransactionCommitResult"
+	// illegalOperationCode is what standalone servers answer when asked to start a transaction.
+	illegalOperationCode = 20
+)
+
+// ErrTransactionsUnsupported is returned when the server is a standalone instance,
+// transactions need a replica set or a sharded cluster.
+var ErrTransactionsUnsupported = errors.New("transactions require a replica set or a sharded cluster")
+
+// TransactionOptions tunes WithTransaction.
+type TransactionOptions struct {
+	// MaxRetries is how many times a transaction is retried after its first
+	// attempt, defaults to DefaultTransactionRetries.
+	MaxRetries int
+	// Logger receives commit and abort outcomes, defaults to a no-op logger.
+	Logger *zap.Logger
+}
+
+// transactionDefaults fills in whatever wasn't set in the first of many TransactionOptions.
+func transactionDefaults(opts []TransactionOptions) TransactionOptions {
+	var o TransactionOptions
+	if len(opts) != 0 {
+		o = opts[0]
+	}
+	if o.MaxRetries <= 0 {
+		o.MaxRetries = DefaultTransactionRetries
+	}
+	if o.Logger == nil {
+		o.Logger = zap.NewNop()
+	}
+	return o
+}
+
+// transactionSession is the part of a mongo.Session that drives a transaction.
+type transactionSession interface {
+	StartTransaction(...*options.TransactionOptions) error
+	AbortTransaction(context.Context) error
+	CommitTransaction(context.Context) error
+}
+
+// WithTransaction runs fn within a multi-document transaction. Every operation
+// within fn must use the context it receives to be part of the transaction.
+// Transient errors retry the whole transaction, unknown commit results retry
+// the commit, and the caller's context deadline is honored between attempts.
+func WithTransaction(ctx context.Context, client *mongo.Client, fn func(ctx context.Context) error, opts ...TransactionOptions) error {
+	session, err := client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+
+	sessionCtx := func(ctx context.Context) context.Context {
+		return mongo.NewSessionContext(ctx, session)
+	}
+	return runTransaction(ctx, session, sessionCtx, fn, transactionDefaults(opts))
+}
+
+// runTransaction is the retry loop behind WithTransaction.
+func runTransaction(ctx context.Context, session transactionSession, sessionCtx func(context.Context) context.Context, fn func(ctx context.Context) error, opts TransactionOptions) error {
+	var err error
+	for attempt := 1; attempt <= 1+opts.MaxRetries; attempt++ {
+		attemptLogger := opts.Logger.With(zap.Int("attempt", attempt))
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return lastErrorOr(err, ctxErr)
+		}
+		if err = session.StartTransaction(); err != nil {
+			return err
+		}
+
+		txCtx := sessionCtx(ctx)
+		if err = fn(txCtx); err != nil {
+			abortErr := session.AbortTransaction(context.Background())
+			attemptLogger.Info("transaction aborted", zap.Error(err), zap.NamedError("abortError", abortErr))
+			if isUnsupported(err) {
+				return fmt.Errorf("%w: %v", ErrTransactionsUnsupported, err)
+			}
+			if hasErrorLabel(err, transientTransactionError) {
+				continue
+			}
+			return err
+		}
+
+		err = commit(txCtx, session, opts.MaxRetries, attemptLogger)
+		if err == nil {
+			attemptLogger.Info("transaction committed")
+			return nil
+		}
+		if isUnsupported(err) {
+			return fmt.Errorf("%w: %v", ErrTransactionsUnsupported, err)
+		}
+		if !hasErrorLabel(err, transientTransactionError) {
+			attemptLogger.Warn("transaction commit failed", zap.Error(err))
+			return err
+		}
+		attemptLogger.Info("transaction commit failed with a transient error", zap.Error(err))
+	}
+	return fmt.Errorf("transaction gave up after %v retries: %w", opts.MaxRetries, err)
+}
+
+// commit commits a transaction, retrying while the commit's outcome is unknown.
+func commit(ctx context.Context, session transactionSession, maxRetries int, logger *zap.Logger) error {
+	err := session.CommitTransaction(ctx)
+	for retry := 1; retry <= maxRetries && hasErrorLabel(err, unknownTransactionCommitResult) && ctx.Err() == nil; retry++ {
+		logger.Info("transaction commit result unknown, retrying the commit", zap.Error(err))
+		err = session.CommitTransaction(ctx)
+	}
+	return err
+}
+
+// hasErrorLabel checks if an error coming from mongodb carries a label.
+func hasErrorLabel(err error, label string) bool {
+	var serverErr mongo.ServerError
+	return errors.As(err, &serverErr) && serverErr.HasErrorLabel(label)
+}
+
+// isUnsupported checks if an error means the server can't do transactions at all.
+func isUnsupported(err error) bool {
+	var serverErr mongo.ServerError
+	return errors.As(err, &serverErr) && serverErr.HasErrorCode(illegalOperationCode)
+}
+
+// lastErrorOr prefers reporting the last error seen, as long as it is wrapped with the context's error.
+func lastErrorOr(last error, ctxErr error) error {
+	if last == nil {
+		return ctxErr
+	}
+	return fmt.Errorf("%w: last attempt failed with %v", ctxErr, last)
+}
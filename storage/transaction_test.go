@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// fakeSession is a transactionSession whose commits fail with a scripted list of errors.
+type fakeSession struct {
+	started       int
+	aborted       int
+	commits       int
+	commitResults []error
+}
+
+// StartTransaction counts the transactions started.
+func (s *fakeSession) StartTransaction(...*options.TransactionOptions) error {
+	s.started++
+	return nil
+}
+
+// AbortTransaction counts the transactions aborted.
+func (s *fakeSession) AbortTransaction(context.Context) error {
+	s.aborted++
+	return nil
+}
+
+// CommitTransaction pops the next scripted result.
+func (s *fakeSession) CommitTransaction(context.Context) error {
+	s.commits++
+	if len(s.commitResults) == 0 {
+		return nil
+	}
+	result := s.commitResults[0]
+	s.commitResults = s.commitResults[1:]
+	return result
+}
+
+// sameContext is the sessionCtx of the fake session, there is no session to attach.
+func sameContext(ctx context.Context) context.Context {
+	return ctx
+}
+
+// labelled creates a mongodb error with a label.
+func labelled(label string) error {
+	return mongo.CommandError{Message: label, Labels: []string{label}}
+}
+
+// TestTransactionsRetryTransientErrors verifies a transaction is rerun after a transient error.
+func TestTransactionsRetryTransientErrors(t *testing.T) {
+	// Arrange
+	core, logs := observer.New(zapcore.InfoLevel)
+	session := &fakeSession{}
+	calls := 0
+	fn := func(ctx context.Context) error {
+		calls++
+		if calls == 1 {
+			return labelled(transientTransactionError)
+		}
+		return nil
+	}
+
+	// Act
+	err := runTransaction(context.Background(), session, sameContext, fn, transactionDefaults([]TransactionOptions{{Logger: zap.New(core)}}))
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 2, session.started)
+	assert.Equal(t, 1, session.aborted)
+	assert.Equal(t, 1, logs.FilterMessage("transaction aborted").Len())
+	assert.Equal(t, 1, logs.FilterMessage("transaction committed").Len())
+}
+
+// TestTransactionsRetryUnknownCommitResults verifies only the commit is retried when its outcome is unknown.
+func TestTransactionsRetryUnknownCommitResults(t *testing.T) {
+	// Arrange
+	session := &fakeSession{commitResults: []error{labelled(unknownTransactionCommitResult), labelled(unknownTransactionCommitResult)}}
+	calls := 0
+
+	// Act
+	err := runTransaction(context.Background(), session, sameContext, func(ctx context.Context) error {
+		calls++
+		return nil
+	}, transactionDefaults(nil))
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 3, session.commits)
+}
+
+// TestTransactionsGiveUpAfterTheirRetries verifies transient errors are retried MaxRetries times after the first attempt, not forever.
+func TestTransactionsGiveUpAfterTheirRetries(t *testing.T) {
+	// Arrange
+	session := &fakeSession{}
+
+	// Act
+	err := runTransaction(context.Background(), session, sameContext, func(ctx context.Context) error {
+		return labelled(transientTransactionError)
+	}, transactionDefaults([]TransactionOptions{{MaxRetries: 1}}))
+
+	// Assert
+	assert.True(t, hasErrorLabel(err, transientTransactionError), "the last error should be reported")
+	assert.Equal(t, 2, session.started, "the first attempt and a retry")
+}
+
+// TestTransactionsDontRetryRegularErrors verifies errors from fn abort the transaction and come back as they are.
+func TestTransactionsDontRetryRegularErrors(t *testing.T) {
+	// Arrange
+	session := &fakeSession{}
+	expected := errors.New("the book is cursed")
+
+	// Act
+	err := runTransaction(context.Background(), session, sameContext, func(ctx context.Context) error {
+		return expected
+	}, transactionDefaults(nil))
+
+	// Assert
+	assert.ErrorIs(t, err, expected)
+	assert.Equal(t, 1, session.started)
+	assert.Equal(t, 1, session.aborted)
+	assert.Zero(t, session.commits)
+}
+
+// TestTransactionsHonorTheContext verifies an expired context stops any further attempt.
+func TestTransactionsHonorTheContext(t *testing.T) {
+	// Arrange
+	session := &fakeSession{}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Act
+	err := runTransaction(ctx, session, sameContext, func(ctx context.Context) error {
+		cancel()
+		return labelled(transientTransactionError)
+	}, transactionDefaults(nil))
+
+	// Assert
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, session.started)
+}
+
+// TestTransactionsOnStandaloneServersAreUnsupported verifies standalone servers produce a clear error.
+func TestTransactionsOnStandaloneServersAreUnsupported(t *testing.T) {
+	// Arrange
+	session := &fakeSession{}
+	standaloneErr := mongo.CommandError{
+		Code:    illegalOperationCode,
+		Message: "Transaction numbers are only allowed on a replica set member or mongos",
+	}
+
+	// Act
+	err := runTransaction(context.Background(), session, sameContext, func(ctx context.Context) error {
+		return standaloneErr
+	}, transactionDefaults(nil))
+
+	// Assert
+	assert.ErrorIs(t, err, ErrTransactionsUnsupported)
+	assert.Equal(t, 1, session.started)
+}